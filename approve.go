@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"temporal-workflow/pipeline"
+
+	tclient "go.temporal.io/sdk/client"
+)
+
+// RunApprove approves the deploy of the pipeline given as argument, e.g.
+// `approve <WorkflowID> [RunID]`, when it waits for an approval because of RequireApproval.
+func RunApprove(pctx context.Context) error {
+	ctx, cancel := signal.NotifyContext(pctx, os.Interrupt, os.Kill)
+	defer cancel()
+
+	flags := flag.NewFlagSet("approve", flag.ContinueOnError)
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		return err
+	}
+	workflowID, runID, err := workflowArgs(flags, "approve")
+	if err != nil {
+		return err
+	}
+
+	tc, closeClient, err := newCommandClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	return approveWorkflow(ctx, tc, os.Stdout, workflowID, runID)
+}
+
+// approveWorkflow sends the approve signal to a pipeline. Only a pipeline already waiting for an
+// approval takes it: one signalled earlier ignores it once it starts waiting.
+func approveWorkflow(ctx context.Context, tc tclient.Client, w io.Writer, workflowID, runID string) error {
+	if err := tc.SignalWorkflow(ctx, workflowID, runID, pipeline.DeployApprovalSignalName, nil); err != nil {
+		return fmt.Errorf("failed to approve workflow %q: %w", workflowID, err)
+	}
+	fmt.Fprintf(w, "Approved the deploy of workflow %s\n", workflowID)
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"temporal-workflow/pipeline"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/mocks"
+)
+
+func TestApproveWorkflow(t *testing.T) {
+	tc := &mocks.Client{}
+	tc.On("SignalWorkflow", mock.Anything, "PipelineWorkflow-1", "", pipeline.DeployApprovalSignalName, nil).Return(nil)
+
+	var out bytes.Buffer
+	assert.NoError(t, approveWorkflow(context.Background(), tc, &out, "PipelineWorkflow-1", ""))
+	assert.Equal(t, "Approved the deploy of workflow PipelineWorkflow-1\n", out.String())
+	tc.AssertExpectations(t)
+
+	tc = &mocks.Client{}
+	tc.On("SignalWorkflow", mock.Anything, "PipelineWorkflow-2", "", pipeline.DeployApprovalSignalName, nil).Return(errors.New("workflow not found"))
+	assert.ErrorContains(t, approveWorkflow(context.Background(), tc, &out, "PipelineWorkflow-2", ""), "workflow not found")
+}
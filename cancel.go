@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/kelseyhightower/envconfig"
+	tclient "go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// RunCancel cancels the workflow given as argument, e.g. `cancel <WorkflowID> [RunID]`, and
+// waits for it to close. A cancelled pipeline still deletes its workdir.
+func RunCancel(pctx context.Context) error {
+	ctx, cancel := signal.NotifyContext(pctx, os.Interrupt, os.Kill)
+	defer cancel()
+
+	flags := flag.NewFlagSet("cancel", flag.ContinueOnError)
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		return err
+	}
+	workflowID, runID, err := workflowArgs(flags, "cancel")
+	if err != nil {
+		return err
+	}
+
+	tc, closeClient, err := newCommandClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	return cancelWorkflow(ctx, tc, os.Stdout, workflowID, runID)
+}
+
+// RunTerminate terminates the workflow given as argument, e.g.
+// `terminate --reason "worker lost" <WorkflowID> [RunID]`. Unlike cancel, the workflow doesn't
+// get to clean up, so it is for workflows that don't respond to a cancellation.
+func RunTerminate(pctx context.Context) error {
+	ctx, cancel := signal.NotifyContext(pctx, os.Interrupt, os.Kill)
+	defer cancel()
+
+	flags := flag.NewFlagSet("terminate", flag.ContinueOnError)
+	reason := flags.String("reason", "terminated from the command line", "reason recorded in the workflow's history")
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		return err
+	}
+	workflowID, runID, err := workflowArgs(flags, "terminate")
+	if err != nil {
+		return err
+	}
+
+	tc, closeClient, err := newCommandClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	return terminateWorkflow(ctx, tc, os.Stdout, workflowID, runID, *reason)
+}
+
+// workflowArgs returns the workflow ID and optional run ID arguments of command.
+func workflowArgs(flags *flag.FlagSet, command string) (workflowID, runID string, err error) {
+	switch flags.NArg() {
+	case 1:
+		return flags.Arg(0), "", nil
+	case 2:
+		return flags.Arg(0), flags.Arg(1), nil
+	}
+	return "", "", fmt.Errorf("usage: %s %s <WorkflowID> [RunID]", os.Args[0], command)
+}
+
+// newCommandClient connects to the Temporal server configured by the environment, see
+// NewTemporalClient.
+func newCommandClient(ctx context.Context) (tclient.Client, func(), error) {
+	var tOpts TemporalOptions
+	if err := envconfig.Process("temporal", &tOpts); err != nil {
+		return nil, nil, fmt.Errorf("failed to process Temporal environment variables: %w", err)
+	}
+
+	tc, closeClient, err := NewTemporalClient(ctx, tOpts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to Temporal server %q: %w", tOpts.HostPort, err)
+	}
+	return tc, closeClient, nil
+}
+
+// cancelWorkflow requests the cancellation of a workflow, waits for it to close, and prints
+// how it closed.
+func cancelWorkflow(ctx context.Context, tc tclient.Client, w io.Writer, workflowID, runID string) error {
+	if err := tc.CancelWorkflow(ctx, workflowID, runID); err != nil {
+		return fmt.Errorf("failed to cancel workflow %q: %w", workflowID, err)
+	}
+	fmt.Fprintf(w, "Requested cancellation of workflow %s, waiting for it to close\n", workflowID)
+
+	var canceledErr *temporal.CanceledError
+	switch err := tc.GetWorkflow(ctx, workflowID, runID).Get(ctx, nil); {
+	case errors.As(err, &canceledErr):
+		fmt.Fprintf(w, "Workflow %s cancelled\n", workflowID)
+	case err != nil:
+		// The workflow may have failed or timed out before it got to handle the cancellation.
+		fmt.Fprintf(w, "Workflow %s closed: %v\n", workflowID, err)
+	default:
+		fmt.Fprintf(w, "Workflow %s completed before it was cancelled\n", workflowID)
+	}
+	return nil
+}
+
+// terminateWorkflow terminates a workflow with reason and prints the outcome.
+func terminateWorkflow(ctx context.Context, tc tclient.Client, w io.Writer, workflowID, runID, reason string) error {
+	if err := tc.TerminateWorkflow(ctx, workflowID, runID, reason); err != nil {
+		return fmt.Errorf("failed to terminate workflow %q: %w", workflowID, err)
+	}
+	fmt.Fprintf(w, "Workflow %s terminated: %s\n", workflowID, reason)
+	return nil
+}
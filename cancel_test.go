@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/mocks"
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestCancelWorkflow(t *testing.T) {
+	tc := &mocks.Client{}
+	tc.On("CancelWorkflow", mock.Anything, "PipelineWorkflow-1", "").Return(nil)
+	run := &mocks.WorkflowRun{}
+	run.On("Get", mock.Anything, nil).Return(temporal.NewCanceledError())
+	tc.On("GetWorkflow", mock.Anything, "PipelineWorkflow-1", "").Return(run)
+
+	var out bytes.Buffer
+	assert.NoError(t, cancelWorkflow(context.Background(), tc, &out, "PipelineWorkflow-1", ""))
+	assert.Equal(t, "Requested cancellation of workflow PipelineWorkflow-1, waiting for it to close\nWorkflow PipelineWorkflow-1 cancelled\n", out.String())
+	tc.AssertExpectations(t)
+
+	tc = &mocks.Client{}
+	tc.On("CancelWorkflow", mock.Anything, "PipelineWorkflow-2", "").Return(errors.New("workflow not found"))
+	err := cancelWorkflow(context.Background(), tc, &out, "PipelineWorkflow-2", "")
+	assert.ErrorContains(t, err, "workflow not found")
+}
+
+func TestTerminateWorkflow(t *testing.T) {
+	tc := &mocks.Client{}
+	tc.On("TerminateWorkflow", mock.Anything, "PipelineWorkflow-1", "run-1", "worker lost").Return(nil)
+
+	var out bytes.Buffer
+	assert.NoError(t, terminateWorkflow(context.Background(), tc, &out, "PipelineWorkflow-1", "run-1", "worker lost"))
+	assert.Equal(t, "Workflow PipelineWorkflow-1 terminated: worker lost\n", out.String())
+	tc.AssertExpectations(t)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"temporal-workflow/pipeline"
+
+	tclient "go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// PipelineConcurrencyGroupSearchAttribute is the concurrency group of a pipeline, set when
+// PipelineParams.ConcurrencyGroup is. Like PipelineRepo, it must be registered on the server as a
+// Keyword search attribute.
+var PipelineConcurrencyGroupSearchAttribute = temporal.NewSearchAttributeKeyKeyword("PipelineConcurrencyGroup")
+
+// enterConcurrencyGroup makes room for a new pipeline in params.ConcurrencyGroup: it cancels the
+// running pipelines of the group with params.CancelInProgress, and otherwise waits for them to
+// finish. Visibility is eventually consistent, so two pipelines starting at the same moment may
+// still overlap.
+func enterConcurrencyGroup(ctx context.Context, tc tclient.Client, params pipeline.PipelineParams) error {
+	condition := fmt.Sprintf("%s = '%s'", PipelineConcurrencyGroupSearchAttribute.GetName(), escapeQueryValue(params.ConcurrencyGroup))
+	if params.CancelInProgress {
+		return cancelRunningPipelines(ctx, tc, condition)
+	}
+
+	// Runs already waited for have closed, even if visibility still lists them as running.
+	waited := map[string]bool{}
+	for {
+		executions, err := runningPipelines(ctx, tc, condition)
+		if err != nil {
+			return err
+		}
+		queued := false
+		for _, execution := range executions {
+			workflowID, runID := execution.GetWorkflowId(), execution.GetRunId()
+			if waited[runID] {
+				continue
+			}
+			queued = true
+			slog.Info("Waiting for PipelineWorkflow of the same concurrency group", "group", params.ConcurrencyGroup, "WorkflowID", workflowID, "RunID", runID)
+			// The outcome of the other pipeline doesn't matter, only that it closed.
+			_ = tc.GetWorkflow(ctx, workflowID, runID).Get(ctx, nil)
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("waiting for concurrency group %q: %w", params.ConcurrencyGroup, err)
+			}
+			waited[runID] = true
+		}
+		// Another pipeline of the group may have started meanwhile, so list again until none is left.
+		if !queued {
+			return nil
+		}
+	}
+}
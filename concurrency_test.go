@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"temporal-workflow/pipeline"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	commonpb "go.temporal.io/api/common/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	tclient "go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestConcurrencyGroup(t *testing.T) {
+	groupQuery := &workflowservice.ListWorkflowExecutionsRequest{
+		Query: "WorkflowType = 'PipelineWorkflow' AND ExecutionStatus = 'Running' AND PipelineConcurrencyGroup = 'deploy-prod'",
+	}
+	running := &workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{
+			{Execution: &commonpb.WorkflowExecution{WorkflowId: "PipelineWorkflow-other", RunId: "run-1"}},
+		},
+	}
+	inGroup := mock.MatchedBy(func(options tclient.StartWorkflowOptions) bool {
+		group, _ := options.TypedSearchAttributes.GetKeyword(PipelineConcurrencyGroupSearchAttribute)
+		return group == "deploy-prod"
+	})
+
+	t.Run("Cancel in progress", func(t *testing.T) {
+		params := pipeline.PipelineParams{
+			GitURL:           "https://github.com/afanwang/go-sample.git",
+			ConcurrencyGroup: "deploy-prod",
+			CancelInProgress: true,
+		}
+
+		tc := &mocks.Client{}
+		tc.On("ListWorkflow", mock.Anything, groupQuery).Return(running, nil)
+		tc.On("CancelWorkflow", mock.Anything, "PipelineWorkflow-other", "run-1").Return(nil)
+		otherRun := &mocks.WorkflowRun{}
+		otherRun.On("Get", mock.Anything, nil).Return(temporal.NewCanceledError())
+		tc.On("GetWorkflow", mock.Anything, "PipelineWorkflow-other", "run-1").Return(otherRun)
+		newRun := &mocks.WorkflowRun{}
+		tc.On("ExecuteWorkflow", mock.Anything, inGroup, "PipelineWorkflow", params).Return(newRun, nil)
+
+		run, err := ExecutePipeline(context.Background(), tc, "pipeline", params)
+		assert.NoError(t, err)
+		assert.Equal(t, newRun, run)
+		tc.AssertExpectations(t)
+	})
+
+	t.Run("Queue", func(t *testing.T) {
+		params := pipeline.PipelineParams{
+			GitURL:           "https://github.com/afanwang/go-sample.git",
+			ConcurrencyGroup: "deploy-prod",
+		}
+
+		tc := &mocks.Client{}
+		tc.On("ListWorkflow", mock.Anything, groupQuery).Return(running, nil).Once()
+		tc.On("ListWorkflow", mock.Anything, groupQuery).Return(&workflowservice.ListWorkflowExecutionsResponse{}, nil).Once()
+		otherRun := &mocks.WorkflowRun{}
+		otherRun.On("Get", mock.Anything, nil).Return(nil)
+		tc.On("GetWorkflow", mock.Anything, "PipelineWorkflow-other", "run-1").Return(otherRun)
+		newRun := &mocks.WorkflowRun{}
+		tc.On("ExecuteWorkflow", mock.Anything, inGroup, "PipelineWorkflow", params).Return(newRun, nil)
+
+		run, err := ExecutePipeline(context.Background(), tc, "pipeline", params)
+		assert.NoError(t, err)
+		assert.Equal(t, newRun, run)
+		tc.AssertExpectations(t)
+		otherRun.AssertExpectations(t)
+		tc.AssertNotCalled(t, "CancelWorkflow", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Queue ignores runs that already closed", func(t *testing.T) {
+		params := pipeline.PipelineParams{
+			GitURL:           "https://github.com/afanwang/go-sample.git",
+			ConcurrencyGroup: "deploy-prod",
+		}
+
+		// Visibility lags behind and keeps listing the closed run.
+		tc := &mocks.Client{}
+		tc.On("ListWorkflow", mock.Anything, groupQuery).Return(running, nil).Twice()
+		otherRun := &mocks.WorkflowRun{}
+		otherRun.On("Get", mock.Anything, nil).Return(nil).Once()
+		tc.On("GetWorkflow", mock.Anything, "PipelineWorkflow-other", "run-1").Return(otherRun)
+		newRun := &mocks.WorkflowRun{}
+		tc.On("ExecuteWorkflow", mock.Anything, inGroup, "PipelineWorkflow", params).Return(newRun, nil)
+
+		_, err := ExecutePipeline(context.Background(), tc, "pipeline", params)
+		assert.NoError(t, err)
+		tc.AssertExpectations(t)
+		otherRun.AssertExpectations(t)
+	})
+}
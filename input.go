@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// inputFetchTimeout bounds fetching a pipeline input from a URL, including reading its body.
+var inputFetchTimeout = 30 * time.Second
+
+// stdin is where an input of "-" is read from.
+var stdin io.Reader = os.Stdin
+
+// openInput opens the pipeline input: "-" for stdin, an http:// or https:// URL, or otherwise a
+// file path.
+func openInput(ctx context.Context, input string) (io.ReadCloser, error) {
+	switch {
+	case input == "-":
+		return io.NopCloser(stdin), nil
+	case strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://"):
+		return fetchInput(ctx, input)
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file %q: %w", input, err)
+	}
+	return f, nil
+}
+
+// fetchInput GETs the input at url. The returned body must be read within inputFetchTimeout.
+func fetchInput(ctx context.Context, url string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(ctx, inputFetchTimeout)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("invalid input URL %q: %w", url, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to fetch input %q: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("failed to fetch input %q: status %s", url, resp.Status)
+	}
+	return cancelOnClose{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// cancelOnClose releases the context of a fetch once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
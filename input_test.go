@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testInput = "git_url: https://github.com/afanwang/go-sample.git\nref: main\n"
+
+func TestReadPipelineParamsSources(t *testing.T) {
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "input.yaml")
+		assert.NoError(t, os.WriteFile(path, []byte(testInput), 0o644))
+		params, err := readPipelineParams(context.Background(), path, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "main", params.Ref)
+
+		_, err = readPipelineParams(context.Background(), filepath.Join(t.TempDir(), "missing.yaml"), "")
+		assert.ErrorContains(t, err, "failed to read input file")
+	})
+
+	t.Run("Stdin", func(t *testing.T) {
+		defer func(r io.Reader) { stdin = r }(stdin)
+		stdin = strings.NewReader(testInput)
+
+		params, err := readPipelineParams(context.Background(), "-", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "main", params.Ref)
+	})
+
+	t.Run("URL", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/pipeline.yaml" {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(testInput))
+		}))
+		defer server.Close()
+
+		params, err := readPipelineParams(context.Background(), server.URL+"/pipeline.yaml", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "main", params.Ref)
+
+		_, err = readPipelineParams(context.Background(), server.URL+"/missing.yaml", "")
+		assert.ErrorContains(t, err, "status 404 Not Found")
+	})
+
+	t.Run("URL timeout", func(t *testing.T) {
+		defer func(timeout time.Duration) { inputFetchTimeout = timeout }(inputFetchTimeout)
+		inputFetchTimeout = 50 * time.Millisecond
+
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+
+		_, err := readPipelineParams(context.Background(), server.URL, "")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"temporal-workflow/pipeline"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	tclient "go.temporal.io/sdk/client"
+)
+
+// listStatuses maps the --status values of the list command to the ExecutionStatus of
+// visibility queries.
+var listStatuses = map[string]string{
+	"running":          "Running",
+	"completed":        "Completed",
+	"failed":           "Failed",
+	"canceled":         "Canceled",
+	"terminated":       "Terminated",
+	"continued_as_new": "ContinuedAsNew",
+	"timed_out":        "TimedOut",
+}
+
+// listPageSize caps the executions requested per page of ListWorkflow.
+const listPageSize = 100
+
+// listedWorkflow is a row of the list command.
+type listedWorkflow struct {
+	WorkflowID string
+	Status     enumspb.WorkflowExecutionStatus
+	StartTime  time.Time
+	// Failures is the number of failures of a closed pipeline, or -1 when it isn't known.
+	Failures int
+}
+
+// RunList prints the most recent workflows of a type, newest first, e.g.
+// `list --status failed --limit 50`.
+func RunList(pctx context.Context) error {
+	ctx, cancel := signal.NotifyContext(pctx, os.Interrupt, os.Kill)
+	defer cancel()
+
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	workflowType := flags.String("type", "PipelineWorkflow", "type of the workflows to list")
+	status := flags.String("status", "", "only list workflows with this status, e.g. running, completed or failed")
+	limit := flags.Int("limit", 20, "maximum number of workflows to list")
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		return err
+	}
+	if *limit <= 0 {
+		return fmt.Errorf("limit must be positive, got %d", *limit)
+	}
+	query, err := listQuery(*workflowType, *status)
+	if err != nil {
+		return err
+	}
+
+	tc, closeClient, err := newCommandClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	workflows, err := listWorkflows(ctx, tc, query, *limit)
+	if err != nil {
+		return err
+	}
+	return writeWorkflowList(os.Stdout, workflows)
+}
+
+// listQuery returns the visibility query of the workflows of workflowType, with status if set.
+func listQuery(workflowType, status string) (string, error) {
+	query := fmt.Sprintf("WorkflowType = '%s'", escapeQueryValue(workflowType))
+	if status == "" {
+		return query, nil
+	}
+	executionStatus, ok := listStatuses[strings.ToLower(status)]
+	if !ok {
+		return "", fmt.Errorf("unknown status %q", status)
+	}
+	return query + fmt.Sprintf(" AND ExecutionStatus = '%s'", executionStatus), nil
+}
+
+// listWorkflows lists up to limit workflows matching query, following the pages of results, and
+// counts the failures of the closed pipelines.
+func listWorkflows(ctx context.Context, tc tclient.Client, query string, limit int) ([]listedWorkflow, error) {
+	var workflows []listedWorkflow
+	var token []byte
+	for len(workflows) < limit {
+		resp, err := tc.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			PageSize:      int32(min(limit-len(workflows), listPageSize)),
+			NextPageToken: token,
+			Query:         query,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list workflows: %w", err)
+		}
+		for _, info := range resp.GetExecutions() {
+			if len(workflows) == limit {
+				break
+			}
+			workflows = append(workflows, listedWorkflow{
+				WorkflowID: info.GetExecution().GetWorkflowId(),
+				Status:     info.GetStatus(),
+				StartTime:  info.GetStartTime().AsTime(),
+				Failures:   pipelineFailures(ctx, tc, info),
+			})
+		}
+		token = resp.GetNextPageToken()
+		if len(token) == 0 {
+			break
+		}
+	}
+	return workflows, nil
+}
+
+// pipelineFailures returns the number of failures in the result of a closed pipeline, or -1
+// for other workflows and pipelines without a result, e.g. canceled ones.
+func pipelineFailures(ctx context.Context, tc tclient.Client, info *workflowpb.WorkflowExecutionInfo) int {
+	if info.GetType().GetName() != "PipelineWorkflow" {
+		return -1
+	}
+	switch info.GetStatus() {
+	case enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED, enumspb.WORKFLOW_EXECUTION_STATUS_FAILED:
+	default:
+		return -1
+	}
+
+	execution := info.GetExecution()
+	var result pipeline.PipelineResult
+	if err := getPipelineResult(ctx, tc.GetWorkflow(ctx, execution.GetWorkflowId(), execution.GetRunId()), &result); err != nil {
+		slog.Debug("No result for PipelineWorkflow", "WorkflowID", execution.GetWorkflowId(), "error", err)
+		return -1
+	}
+	return len(result.Failures)
+}
+
+// writeWorkflowList prints workflows as a table, with the statuses named as for --status.
+func writeWorkflowList(w io.Writer, workflows []listedWorkflow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKFLOW ID\tSTATUS\tSTART TIME\tFAILURES")
+	for _, wf := range workflows {
+		status := wf.Status.String()
+		for name, executionStatus := range listStatuses {
+			if executionStatus == status {
+				status = name
+			}
+		}
+		failures := "-"
+		if wf.Failures >= 0 {
+			failures = strconv.Itoa(wf.Failures)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", wf.WorkflowID, status, wf.StartTime.Format(time.RFC3339), failures)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"temporal-workflow/pipeline"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/mocks"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestListQuery(t *testing.T) {
+	query, err := listQuery("PipelineWorkflow", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "WorkflowType = 'PipelineWorkflow'", query)
+
+	query, err = listQuery("PipelineWorkflow", "Timed_Out")
+	assert.NoError(t, err)
+	assert.Equal(t, "WorkflowType = 'PipelineWorkflow' AND ExecutionStatus = 'TimedOut'", query)
+
+	_, err = listQuery("PipelineWorkflow", "done")
+	assert.ErrorContains(t, err, `unknown status "done"`)
+}
+
+func TestListWorkflows(t *testing.T) {
+	started := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	execution := func(id string, status enumspb.WorkflowExecutionStatus) *workflowpb.WorkflowExecutionInfo {
+		return &workflowpb.WorkflowExecutionInfo{
+			Execution: &commonpb.WorkflowExecution{WorkflowId: id, RunId: id + "-run"},
+			Type:      &commonpb.WorkflowType{Name: "PipelineWorkflow"},
+			StartTime: timestamppb.New(started),
+			Status:    status,
+		}
+	}
+
+	tc := &mocks.Client{}
+	query := "WorkflowType = 'PipelineWorkflow'"
+	tc.On("ListWorkflow", mock.Anything, &workflowservice.ListWorkflowExecutionsRequest{PageSize: 3, Query: query}).
+		Return(&workflowservice.ListWorkflowExecutionsResponse{
+			Executions: []*workflowpb.WorkflowExecutionInfo{
+				execution("running", enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING),
+				execution("completed", enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED),
+			},
+			NextPageToken: []byte("page-2"),
+		}, nil)
+	tc.On("ListWorkflow", mock.Anything, &workflowservice.ListWorkflowExecutionsRequest{PageSize: 1, NextPageToken: []byte("page-2"), Query: query}).
+		Return(&workflowservice.ListWorkflowExecutionsResponse{
+			Executions: []*workflowpb.WorkflowExecutionInfo{
+				execution("canceled", enumspb.WORKFLOW_EXECUTION_STATUS_CANCELED),
+			},
+			NextPageToken: []byte("page-3"),
+		}, nil)
+	run := &mocks.WorkflowRun{}
+	run.On("Get", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		result := args.Get(1).(*pipeline.PipelineResult)
+		result.Failures = []pipeline.PipelineFailure{{Activity: "GoTest"}, {Activity: "GoVet"}}
+	})
+	tc.On("GetWorkflow", mock.Anything, "completed", "completed-run").Return(run)
+
+	workflows, err := listWorkflows(context.Background(), tc, query, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []listedWorkflow{
+		{WorkflowID: "running", Status: enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING, StartTime: started, Failures: -1},
+		{WorkflowID: "completed", Status: enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED, StartTime: started, Failures: 2},
+		{WorkflowID: "canceled", Status: enumspb.WORKFLOW_EXECUTION_STATUS_CANCELED, StartTime: started, Failures: -1},
+	}, workflows)
+	tc.AssertExpectations(t)
+
+	var out bytes.Buffer
+	assert.NoError(t, writeWorkflowList(&out, workflows))
+	assert.Equal(t, `WORKFLOW ID  STATUS     START TIME            FAILURES
+running      running    2024-07-01T12:00:00Z  -
+completed    completed  2024-07-01T12:00:00Z  2
+canceled     canceled   2024-07-01T12:00:00Z  -
+`, out.String())
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+// LogOptions configures the logs of every command, e.g. LOG_FORMAT=json for a log aggregator
+// and LOG_LEVEL=debug to troubleshoot.
+type LogOptions struct {
+	// Format is text or json.
+	Format string `default:"text"`
+	// Level is debug, info, warn or error.
+	Level string `default:"info"`
+}
+
+// setupLogging makes the handler configured by the environment the default of slog, which the
+// Temporal clients log to as well.
+func setupLogging(w io.Writer) error {
+	var opts LogOptions
+	if err := envconfig.Process("log", &opts); err != nil {
+		return fmt.Errorf("failed to process log environment variables: %w", err)
+	}
+	handler, err := newLogHandler(w, opts)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// newLogHandler returns the handler writing logs to w in the format and from the level of opts.
+func newLogHandler(w io.Writer, opts LogOptions) (slog.Handler, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL %q, must be debug, info, warn or error", opts.Level)
+	}
+	hOpts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(opts.Format) {
+	case "text":
+		return slog.NewTextHandler(w, hOpts), nil
+	case "json":
+		return slog.NewJSONHandler(w, hOpts), nil
+	}
+	return nil, fmt.Errorf("invalid LOG_FORMAT %q, must be text or json", opts.Format)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLogHandler(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		handler, err := newLogHandler(&out, LogOptions{Format: "json", Level: "warn"})
+		assert.NoError(t, err)
+		assert.IsType(t, &slog.JSONHandler{}, handler)
+		assert.False(t, handler.Enabled(context.Background(), slog.LevelInfo))
+
+		slog.New(handler).Warn("Disk almost full", "free", 42)
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &record))
+		assert.Equal(t, "Disk almost full", record["msg"])
+		assert.Equal(t, "WARN", record["level"])
+		assert.Equal(t, float64(42), record["free"])
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		handler, err := newLogHandler(&bytes.Buffer{}, LogOptions{Format: "TEXT", Level: "debug"})
+		assert.NoError(t, err)
+		assert.IsType(t, &slog.TextHandler{}, handler)
+		assert.True(t, handler.Enabled(context.Background(), slog.LevelDebug))
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		_, err := newLogHandler(&bytes.Buffer{}, LogOptions{Format: "xml", Level: "info"})
+		assert.ErrorContains(t, err, "invalid LOG_FORMAT")
+		_, err = newLogHandler(&bytes.Buffer{}, LogOptions{Format: "json", Level: "verbose"})
+		assert.ErrorContains(t, err, "invalid LOG_LEVEL")
+	})
+}
+
+func TestSetupLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	t.Setenv("LOG_FORMAT", "json")
+
+	var out bytes.Buffer
+	assert.NoError(t, setupLogging(&out))
+	slog.Info("Worker started")
+	assert.Contains(t, out.String(), `"msg":"Worker started"`)
+}
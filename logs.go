@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+)
+
+// RunLogs follows the history of the workflow given as argument, e.g. `logs <WorkflowID> [RunID]`,
+// and prints its activity events as they happen until the workflow closes.
+func RunLogs(pctx context.Context) error {
+	ctx, cancel := signal.NotifyContext(pctx, os.Interrupt, os.Kill)
+	defer cancel()
+
+	if len(os.Args) < 3 {
+		return fmt.Errorf("usage: %s logs <WorkflowID> [RunID]", os.Args[0])
+	}
+	workflowID := os.Args[2]
+	var runID string
+	if len(os.Args) > 3 {
+		runID = os.Args[3]
+	}
+
+	tc, closeClient, err := newCommandClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	// A long poll blocks for new events instead of returning at the end of the current history.
+	iter := tc.GetWorkflowHistory(ctx, workflowID, runID, true, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	if err := writeHistoryEvents(os.Stdout, iter); err != nil {
+		return fmt.Errorf("failed to follow history of workflow %q: %w", workflowID, err)
+	}
+	return nil
+}
+
+// historyIterator is the subset of client.HistoryEventIterator used by writeHistoryEvents.
+type historyIterator interface {
+	HasNext() bool
+	Next() (*historypb.HistoryEvent, error)
+}
+
+// writeHistoryEvents prints a line per activity start, completion, or failure of the events of
+// iter, and the closing event of the workflow.
+func writeHistoryEvents(w io.Writer, iter historyIterator) error {
+	// Activity events after the scheduled one only refer to it by ID.
+	activities := map[int64]string{}
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return err
+		}
+
+		var what, name, detail string
+		switch event.GetEventType() {
+		case enumspb.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED:
+			activities[event.GetEventId()] = event.GetActivityTaskScheduledEventAttributes().GetActivityType().GetName()
+			continue
+		case enumspb.EVENT_TYPE_ACTIVITY_TASK_STARTED:
+			attrs := event.GetActivityTaskStartedEventAttributes()
+			what, name = "started", activities[attrs.GetScheduledEventId()]
+			if attrs.GetAttempt() > 1 {
+				detail = fmt.Sprintf("attempt %d", attrs.GetAttempt())
+			}
+		case enumspb.EVENT_TYPE_ACTIVITY_TASK_COMPLETED:
+			attrs := event.GetActivityTaskCompletedEventAttributes()
+			what, name = "completed", activities[attrs.GetScheduledEventId()]
+		case enumspb.EVENT_TYPE_ACTIVITY_TASK_FAILED:
+			attrs := event.GetActivityTaskFailedEventAttributes()
+			what, name, detail = "failed", activities[attrs.GetScheduledEventId()], attrs.GetFailure().GetMessage()
+		case enumspb.EVENT_TYPE_ACTIVITY_TASK_TIMED_OUT:
+			attrs := event.GetActivityTaskTimedOutEventAttributes()
+			what, name, detail = "timed out", activities[attrs.GetScheduledEventId()], attrs.GetFailure().GetMessage()
+		case enumspb.EVENT_TYPE_ACTIVITY_TASK_CANCELED:
+			attrs := event.GetActivityTaskCanceledEventAttributes()
+			what, name = "canceled", activities[attrs.GetScheduledEventId()]
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED:
+			what, name = "completed", "workflow"
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED:
+			what, name, detail = "failed", "workflow", event.GetWorkflowExecutionFailedEventAttributes().GetFailure().GetMessage()
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TIMED_OUT:
+			what, name = "timed out", "workflow"
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CANCELED:
+			what, name = "canceled", "workflow"
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TERMINATED:
+			what, name, detail = "terminated", "workflow", event.GetWorkflowExecutionTerminatedEventAttributes().GetReason()
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW:
+			what, name = "continued as new", "workflow"
+		default:
+			continue
+		}
+
+		line := fmt.Sprintf("%s  %-10s %s", event.GetEventTime().AsTime().Format(time.RFC3339), what, name)
+		if detail != "" {
+			line += ": " + detail
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
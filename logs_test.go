@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	failurepb "go.temporal.io/api/failure/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type sliceHistoryIterator []*historypb.HistoryEvent
+
+func (it *sliceHistoryIterator) HasNext() bool { return len(*it) > 0 }
+
+func (it *sliceHistoryIterator) Next() (*historypb.HistoryEvent, error) {
+	event := (*it)[0]
+	*it = (*it)[1:]
+	return event, nil
+}
+
+func TestWriteHistoryEvents(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) *timestamppb.Timestamp {
+		return timestamppb.New(start.Add(time.Duration(seconds) * time.Second))
+	}
+	iter := sliceHistoryIterator{
+		{EventId: 1, EventTime: at(0), EventType: enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED},
+		{EventId: 5, EventTime: at(1), EventType: enumspb.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED, Attributes: &historypb.HistoryEvent_ActivityTaskScheduledEventAttributes{
+			ActivityTaskScheduledEventAttributes: &historypb.ActivityTaskScheduledEventAttributes{ActivityType: &commonpb.ActivityType{Name: "GitClone"}},
+		}},
+		{EventId: 6, EventTime: at(1), EventType: enumspb.EVENT_TYPE_ACTIVITY_TASK_STARTED, Attributes: &historypb.HistoryEvent_ActivityTaskStartedEventAttributes{
+			ActivityTaskStartedEventAttributes: &historypb.ActivityTaskStartedEventAttributes{ScheduledEventId: 5, Attempt: 1},
+		}},
+		{EventId: 7, EventTime: at(3), EventType: enumspb.EVENT_TYPE_ACTIVITY_TASK_COMPLETED, Attributes: &historypb.HistoryEvent_ActivityTaskCompletedEventAttributes{
+			ActivityTaskCompletedEventAttributes: &historypb.ActivityTaskCompletedEventAttributes{ScheduledEventId: 5},
+		}},
+		{EventId: 11, EventTime: at(4), EventType: enumspb.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED, Attributes: &historypb.HistoryEvent_ActivityTaskScheduledEventAttributes{
+			ActivityTaskScheduledEventAttributes: &historypb.ActivityTaskScheduledEventAttributes{ActivityType: &commonpb.ActivityType{Name: "GoTest"}},
+		}},
+		{EventId: 12, EventTime: at(6), EventType: enumspb.EVENT_TYPE_ACTIVITY_TASK_STARTED, Attributes: &historypb.HistoryEvent_ActivityTaskStartedEventAttributes{
+			ActivityTaskStartedEventAttributes: &historypb.ActivityTaskStartedEventAttributes{ScheduledEventId: 11, Attempt: 2},
+		}},
+		{EventId: 13, EventTime: at(9), EventType: enumspb.EVENT_TYPE_ACTIVITY_TASK_FAILED, Attributes: &historypb.HistoryEvent_ActivityTaskFailedEventAttributes{
+			ActivityTaskFailedEventAttributes: &historypb.ActivityTaskFailedEventAttributes{ScheduledEventId: 11, Failure: &failurepb.Failure{Message: "exit status 2"}},
+		}},
+		{EventId: 20, EventTime: at(10), EventType: enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeHistoryEvents(&buf, &iter))
+	assert.Equal(t, `2024-06-01T12:00:01Z  started    GitClone
+2024-06-01T12:00:03Z  completed  GitClone
+2024-06-01T12:00:06Z  started    GoTest: attempt 2
+2024-06-01T12:00:09Z  failed     GoTest: exit status 2
+2024-06-01T12:00:10Z  completed  workflow
+`, buf.String())
+}
@@ -9,21 +9,30 @@ import (
 	"go.uber.org/automaxprocs/maxprocs"
 )
 
-func init() {
-	maxprocslog := func(format string, args ...any) {
-		slog.Info(fmt.Sprintf(format, args...))
-	}
-	_, _ = maxprocs.Set(maxprocs.Logger(maxprocslog))
-}
-
 type command func(context.Context) error
 
 var commands = map[string]command{
-	"worker":   RunWorker,
-	"pipeline": RunPipeline,
+	"worker":    RunWorker,
+	"pipeline":  RunPipeline,
+	"serve":     RunServe,
+	"schedule":  RunSchedule,
+	"logs":      RunLogs,
+	"list":      RunList,
+	"cancel":    RunCancel,
+	"terminate": RunTerminate,
+	"approve":   RunApprove,
 }
 
 func main() {
+	if err := setupLogging(os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	maxprocslog := func(format string, args ...any) {
+		slog.Info(fmt.Sprintf(format, args...))
+	}
+	_, _ = maxprocs.Set(maxprocs.Logger(maxprocslog))
+
 	if len(os.Args) < 2 {
 		help()
 		os.Exit(1)
@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// migrations create the tables ArchiveResult writes to. Each is safe to apply again; a worker
+// applies them to a database before its first insert into it.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// ArchiveDriver is the database/sql driver ArchiveResult opens PipelineParams.ArchiveDSN with.
+// The worker registers it by importing github.com/lib/pq.
+const ArchiveDriver = "postgres"
+
+// ArchiveResult params
+type ArchiveResultParams struct {
+	DSN        string
+	WorkflowID string
+	RunID      string
+	GitURL     string
+	CommitSHA  string
+	// StartedAt is when the pipeline run started, which tells apart the runs of a Repeat
+	// pipeline within one workflow run.
+	StartedAt time.Time
+	Duration  time.Duration
+	Result    PipelineResult
+}
+
+const archiveInsert = `INSERT INTO pipeline_results
+	(workflow_id, run_id, git_url, commit_sha, started_at, duration_ms, success, result)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (workflow_id, run_id, started_at) DO NOTHING`
+
+// ArchiveResult inserts the pipeline result into the pipeline_results table of the database at
+// params.DSN, for trend dashboards. A retried insert of the same pipeline run is a no-op.
+func (pa *PipelineActivity) ArchiveResult(ctx context.Context, params ArchiveResultParams) error {
+	openDB := pa.OpenDB
+	if openDB == nil {
+		openDB = sql.Open
+	}
+	db, err := openDB(ArchiveDriver, params.DSN)
+	if err != nil {
+		return fmt.Errorf("opening archive database: %w", err)
+	}
+	defer db.Close()
+
+	if _, done := pa.migrated.Load(params.DSN); !done {
+		if err := migrate(ctx, db); err != nil {
+			return err
+		}
+		pa.migrated.Store(params.DSN, true)
+	}
+
+	result, err := json.Marshal(params.Result)
+	if err != nil {
+		return fmt.Errorf("marshalling pipeline result: %w", err)
+	}
+	activityLogger(ctx).Info("Archiving pipeline result", "workflow_id", params.WorkflowID, "run_id", params.RunID)
+	_, err = db.ExecContext(ctx, archiveInsert,
+		params.WorkflowID, params.RunID, params.GitURL, params.CommitSHA, params.StartedAt,
+		params.Duration.Milliseconds(), !hasErrors(&params.Result), string(result))
+	if err != nil {
+		return fmt.Errorf("archiving pipeline result: %w", err)
+	}
+	return nil
+}
+
+// migrate applies the migrations in name order.
+func migrate(ctx context.Context, db *sql.DB) error {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		migration, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, string(migration)); err != nil {
+			return fmt.Errorf("applying migration %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// archiveResult archives the final result of the pipeline with ArchiveResult.
+func archiveResult(ctx workflow.Context, params PipelineParams, result *PipelineResult) {
+	info := workflow.GetInfo(ctx)
+	err := workflow.ExecuteActivity(ctx, pa.ArchiveResult, ArchiveResultParams{
+		DSN:        params.ArchiveDSN,
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		GitURL:     params.RepoSpecs()[0].URL,
+		CommitSHA:  result.CommitSHA,
+		StartedAt:  result.StartedAt,
+		Duration:   workflow.Now(ctx).Sub(result.StartedAt),
+		Result:     *result,
+	}).Get(ctx, nil)
+	if err != nil {
+		// Like a notification, a missing archive row shouldn't fail a finished pipeline.
+		workflow.GetLogger(ctx).Warn("ArchiveResult failed", "error", err)
+	}
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// recordingConnector is a database/sql connector whose connections record the statements
+// executed on them, in place of a PostgreSQL server.
+type recordingConnector struct {
+	execs []recordedExec
+}
+
+type recordedExec struct {
+	query string
+	args  []any
+}
+
+func (c *recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	return recordingConn{c}, nil
+}
+
+func (c *recordingConnector) Driver() driver.Driver { return nil }
+
+type recordingConn struct{ connector *recordingConnector }
+
+func (c recordingConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	exec := recordedExec{query: query}
+	for _, arg := range args {
+		exec.args = append(exec.args, arg.Value)
+	}
+	c.connector.execs = append(c.connector.execs, exec)
+	return driver.RowsAffected(1), nil
+}
+
+func (c recordingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c recordingConn) Close() error                        { return nil }
+func (c recordingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func TestArchiveResultActivity(t *testing.T) {
+	connector := &recordingConnector{}
+	activities := &PipelineActivity{
+		OpenDB: func(driverName, dataSourceName string) (*sql.DB, error) {
+			assert.Equal(t, "postgres", driverName)
+			assert.Equal(t, "postgres://ci@db/pipelines", dataSourceName)
+			return sql.OpenDB(connector), nil
+		},
+	}
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(activities)
+
+	startedAt := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	_, err := env.ExecuteActivity(activities.ArchiveResult, ArchiveResultParams{
+		DSN:        "postgres://ci@db/pipelines",
+		WorkflowID: "pipeline-1",
+		RunID:      "run-1",
+		GitURL:     gitUrl,
+		CommitSHA:  "abc123",
+		StartedAt:  startedAt,
+		Duration:   90 * time.Second,
+		Result:     PipelineResult{Failures: []PipelineFailure{{Activity: "GoTest", Details: "TestFoo failed"}}},
+	})
+	assert.NoError(t, err)
+
+	if assert.Len(t, connector.execs, 2) {
+		assert.Contains(t, connector.execs[0].query, "CREATE TABLE IF NOT EXISTS pipeline_results")
+		insert := connector.execs[1]
+		assert.Contains(t, insert.query, "ON CONFLICT (workflow_id, run_id, started_at) DO NOTHING")
+		assert.Equal(t, []any{"pipeline-1", "run-1", gitUrl, "abc123", startedAt, int64(90000), false}, insert.args[:7])
+		assert.Contains(t, insert.args[7], `"activity":"GoTest"`)
+	}
+
+	// The migrations are applied once per database.
+	_, err = env.ExecuteActivity(activities.ArchiveResult, ArchiveResultParams{
+		DSN:        "postgres://ci@db/pipelines",
+		WorkflowID: "pipeline-1",
+		RunID:      "run-1",
+		StartedAt:  startedAt.Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, connector.execs, 3) {
+		assert.Contains(t, connector.execs[2].query, "INSERT INTO pipeline_results")
+	}
+}
+
+func TestArchiveResult(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	var archived ArchiveResultParams
+	env.OnActivity(pa.ArchiveResult, mock.Anything, mock.MatchedBy(func(p ArchiveResultParams) bool {
+		archived = p
+		return true
+	})).Return(nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, ArchiveDSN: "postgres://ci@db/pipelines"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	assert.Equal(t, "postgres://ci@db/pipelines", archived.DSN)
+	assert.Equal(t, gitUrl, archived.GitURL)
+	assert.NotEmpty(t, archived.RunID)
+	assert.NotEmpty(t, archived.Result.Steps)
+}
+
+func TestArchiveResultRepeat(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	var archived []ArchiveResultParams
+	env.OnActivity(pa.ArchiveResult, mock.Anything, mock.MatchedBy(func(p ArchiveResultParams) bool {
+		archived = append(archived, p)
+		return true
+	})).Return(nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{
+		GitURL: gitUrl, ArchiveDSN: "postgres://ci@db/pipelines", Repeat: true, Interval: time.Hour, ContinueAsNewEvery: 2,
+	})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	if assert.Len(t, archived, 2) {
+		assert.Equal(t, archived[0].RunID, archived[1].RunID)
+		assert.Equal(t, archived[0].StartedAt, archived[0].Result.StartedAt)
+		// Each run of the pipeline is its own row, keyed by when it started.
+		assert.True(t, archived[1].StartedAt.After(archived[0].StartedAt.Add(time.Hour-time.Second)))
+	}
+}
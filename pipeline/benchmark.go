@@ -0,0 +1,222 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// GoBenchmark params and results
+type GoBenchmarkParams struct {
+	Metadata PipelineActivityMetadata
+	// Pattern is the -bench regular expression, e.g. "." for every benchmark.
+	Pattern string
+	Flags   []string
+	Tags    []string
+	// Baseline is a path (relative to the workdir) or http(s) URL of a JSON array of
+	// BenchmarkResult to compare against. Empty skips the comparison.
+	Baseline string
+}
+
+type GoBenchmarkResult struct {
+	Metadata   PipelineActivityMetadata
+	Benchmarks []BenchmarkResult
+	// Baseline holds the benchmarks loaded from GoBenchmarkParams.Baseline, if any.
+	Baseline []BenchmarkResult
+	// Diagnostics holds the output of a benchmark run that failed.
+	Diagnostics string
+	Logs        string
+	ElapsedMs   int64
+}
+
+type BenchmarkResult struct {
+	Package string `json:"package"`
+	// Name is the benchmark name without the GOMAXPROCS suffix, so results compare across hosts.
+	Name        string  `json:"name"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+}
+
+func (pa *PipelineActivity) GoBenchmark(ctx context.Context, params GoBenchmarkParams) (*GoBenchmarkResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &GoBenchmarkResult{Metadata: params.Metadata}
+
+	args := []string{"test", "-run=^$", "-bench=" + params.Pattern, "-benchmem"}
+	args = append(args, tagsArgs(params.Tags)...)
+	args = append(args, params.Flags...)
+	args = append(args, "./...")
+	logs := &logTail{}
+	start := time.Now()
+	stdout, stderr, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: goEnv(params.Metadata), Logs: logs}, "go", args...)
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	result.Logs = logs.String()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("running go benchmark command: %w", err)
+		}
+		// A benchmark that fails or doesn't compile is reported, not retried.
+		result.Diagnostics = strings.TrimSpace(stdout + "\n" + stderr)
+	}
+
+	benchmarks, err := parseBenchmarkOutput(strings.NewReader(stdout))
+	if err != nil {
+		return nil, fmt.Errorf("parsing go benchmark output: %w", err)
+	}
+	result.Benchmarks = benchmarks
+
+	if params.Baseline != "" {
+		baseline, err := loadBenchmarkBaseline(ctx, params.Metadata.Workdir, params.Baseline)
+		if err != nil {
+			return nil, err
+		}
+		result.Baseline = baseline
+	}
+
+	logger.Info("Go benchmark ran", "benchmarks", len(result.Benchmarks))
+	return result, nil
+}
+
+var benchmarkProcsSuffix = regexp.MustCompile(`-\d+$`)
+
+// parseBenchmarkOutput parses the text output of `go test -bench -benchmem`. Lines other than
+// "pkg:" headers and benchmark results are ignored.
+func parseBenchmarkOutput(r io.Reader) ([]BenchmarkResult, error) {
+	benchmarks := []BenchmarkResult{}
+	pkg := ""
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if p, ok := strings.CutPrefix(line, "pkg: "); ok {
+			pkg = strings.TrimSpace(p)
+			continue
+		}
+		fields := strings.Fields(line)
+		// BenchmarkName-8  <iterations>  <value> <unit>  [<value> <unit>]...
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") || len(fields)%2 != 0 {
+			continue
+		}
+		if _, err := strconv.ParseInt(fields[1], 10, 64); err != nil {
+			continue
+		}
+
+		benchmark := BenchmarkResult{
+			Package: pkg,
+			Name:    benchmarkProcsSuffix.ReplaceAllString(fields[0], ""),
+		}
+		for i := 2; i < len(fields); i += 2 {
+			value, unit := fields[i], fields[i+1]
+			var err error
+			switch unit {
+			case "ns/op":
+				benchmark.NsPerOp, err = strconv.ParseFloat(value, 64)
+			case "B/op":
+				benchmark.BytesPerOp, err = strconv.ParseInt(value, 10, 64)
+			case "allocs/op":
+				benchmark.AllocsPerOp, err = strconv.ParseInt(value, 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s of %s: %w", unit, fields[0], err)
+			}
+		}
+		benchmarks = append(benchmarks, benchmark)
+	}
+	return benchmarks, scanner.Err()
+}
+
+// loadBenchmarkBaseline reads a JSON array of BenchmarkResult from an http(s) URL or a file path.
+// Relative paths are resolved against the workdir, so a repository can commit its own baseline.
+func loadBenchmarkBaseline(ctx context.Context, workdir, location string) ([]BenchmarkResult, error) {
+	var data []byte
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating benchmark baseline request: %w", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetching benchmark baseline: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching benchmark baseline: unexpected status %s", resp.Status)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, fmt.Errorf("reading benchmark baseline: %w", err)
+		}
+	} else {
+		path := location
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workdir, path)
+		}
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("reading benchmark baseline: %w", err)
+		}
+	}
+
+	var baseline []BenchmarkResult
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("unmarshalling benchmark baseline: %w", err)
+	}
+	return baseline, nil
+}
+
+// DefaultBenchmarkRegressionPercent is the ns/op slowdown flagged when
+// PipelineParams.BenchmarkRegressionPercent is zero.
+const DefaultBenchmarkRegressionPercent = 10
+
+// BenchmarkRegression is a benchmark that got slower than the baseline by more than the threshold.
+type BenchmarkRegression struct {
+	Package    string  `json:"package"`
+	Name       string  `json:"name"`
+	OldNsPerOp float64 `json:"old_ns_per_op"`
+	NewNsPerOp float64 `json:"new_ns_per_op"`
+	// DeltaPercent is the ns/op increase relative to the baseline.
+	DeltaPercent float64 `json:"delta_percent"`
+}
+
+// compareBenchmarks returns the benchmarks of current whose ns/op exceeds the matching baseline
+// benchmark by more than thresholdPercent, in the order of current. Benchmarks missing from
+// the baseline are not compared.
+func compareBenchmarks(baseline, current []BenchmarkResult, thresholdPercent float64) []BenchmarkRegression {
+	type key struct{ pkg, name string }
+	old := make(map[key]float64, len(baseline))
+	for _, benchmark := range baseline {
+		old[key{benchmark.Package, benchmark.Name}] = benchmark.NsPerOp
+	}
+
+	regressions := []BenchmarkRegression{}
+	for _, benchmark := range current {
+		oldNsPerOp, ok := old[key{benchmark.Package, benchmark.Name}]
+		if !ok || oldNsPerOp <= 0 {
+			continue
+		}
+		delta := (benchmark.NsPerOp - oldNsPerOp) / oldNsPerOp * 100
+		if delta > thresholdPercent {
+			regressions = append(regressions, BenchmarkRegression{
+				Package:      benchmark.Package,
+				Name:         benchmark.Name,
+				OldNsPerOp:   oldNsPerOp,
+				NewNsPerOp:   benchmark.NsPerOp,
+				DeltaPercent: delta,
+			})
+		}
+	}
+	return regressions
+}
@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBenchmarkOutput(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "benchmark", "output.txt"))
+	assert.NoError(t, err)
+	defer f.Close()
+
+	benchmarks, err := parseBenchmarkOutput(f)
+	assert.NoError(t, err)
+	assert.Equal(t, []BenchmarkResult{
+		{Package: "example.com/app", Name: "BenchmarkEncode", NsPerOp: 1052, BytesPerOp: 256, AllocsPerOp: 4},
+		{Package: "example.com/app", Name: "BenchmarkDecode", NsPerOp: 2104.5, BytesPerOp: 512, AllocsPerOp: 8},
+		{Package: "example.com/app/util", Name: "BenchmarkHash", NsPerOp: 118},
+	}, benchmarks)
+}
+
+func TestCompareBenchmarks(t *testing.T) {
+	baseline := []BenchmarkResult{
+		{Package: "example.com/app", Name: "BenchmarkEncode", NsPerOp: 1000},
+		{Package: "example.com/app", Name: "BenchmarkDecode", NsPerOp: 2000},
+		{Package: "example.com/app/util", Name: "BenchmarkHash", NsPerOp: 100},
+	}
+	current := []BenchmarkResult{
+		{Package: "example.com/app/util", Name: "BenchmarkHash", NsPerOp: 150},
+		{Package: "example.com/app", Name: "BenchmarkEncode", NsPerOp: 1100},
+		{Package: "example.com/app", Name: "BenchmarkDecode", NsPerOp: 1500},
+		{Package: "example.com/app", Name: "BenchmarkNew", NsPerOp: 99999},
+	}
+
+	t.Run("Flags regressions above the threshold in current order", func(t *testing.T) {
+		assert.Equal(t, []BenchmarkRegression{
+			{Package: "example.com/app/util", Name: "BenchmarkHash", OldNsPerOp: 100, NewNsPerOp: 150, DeltaPercent: 50},
+		}, compareBenchmarks(baseline, current, 10))
+	})
+
+	t.Run("Threshold is exclusive", func(t *testing.T) {
+		regressions := compareBenchmarks(baseline, current, 5)
+		if assert.Len(t, regressions, 2) {
+			assert.Equal(t, "BenchmarkHash", regressions[0].Name)
+			assert.Equal(t, "BenchmarkEncode", regressions[1].Name)
+			assert.InDelta(t, 10, regressions[1].DeltaPercent, 1e-9)
+		}
+		assert.Len(t, compareBenchmarks(baseline, current, 50), 0)
+	})
+}
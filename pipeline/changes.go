@@ -0,0 +1,166 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// DetectChanges params and results
+type DetectChangesParams struct {
+	Metadata PipelineActivityMetadata
+	// Base is the ref or commit the checked out HEAD is compared with, e.g. origin/main.
+	Base string
+	// CACertPath and Insecure verify the remote's certificate like those of GitCloneParams, for
+	// fetching a Base that isn't in the clone.
+	CACertPath string
+	Insecure   bool
+}
+
+type DetectChangesResult struct {
+	// Files lists the files changed since the merge base of Base and HEAD, relative to the workdir.
+	Files []string
+	// Packages lists the packages affected by Files, as patterns relative to the workdir: the
+	// packages of the changed files and the packages importing them. It is empty when the
+	// changes can't be narrowed down to packages, e.g. because go.mod changed, or affect none.
+	Packages []string
+}
+
+// DetectChanges lists the files changed between params.Base and HEAD, like a pull request's diff,
+// and resolves the packages they affect.
+func (pa *PipelineActivity) DetectChanges(ctx context.Context, params DetectChangesParams) (*DetectChangesResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &DetectChangesResult{
+		Files:    []string{},
+		Packages: []string{},
+	}
+
+	workdir := params.Metadata.Workdir
+	env := gitEnv(params.CACertPath, params.Insecure)
+	if fullSHA.MatchString(params.Base) {
+		// A commit that isn't on a branch of the clone, e.g. the base of a pull request from a fork.
+		if _, err := runGitEnv(ctx, workdir, env, "fetch", "origin", params.Base); err != nil {
+			return nil, fmt.Errorf("fetching diff base %q: %w", params.Base, err)
+		}
+	}
+	diff, err := runGitEnv(ctx, workdir, env, "diff", "--name-only", "--relative", params.Base+"...HEAD")
+	if err != nil {
+		// The clone and base don't change between attempts.
+		return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("diffing against %q: %s", params.Base, err), "InvalidDiffBase", err)
+	}
+	for _, file := range strings.Split(diff, "\n") {
+		if file != "" {
+			result.Files = append(result.Files, file)
+		}
+	}
+
+	packages, err := affectedPackages(ctx, params.Metadata, result.Files)
+	if err != nil {
+		return nil, err
+	}
+	result.Packages = append(result.Packages, packages...)
+
+	logger.Info("Detected changes", "files", len(result.Files), "packages", result.Packages)
+	return result, nil
+}
+
+// goPackage is a package of the workdir as `go list` reports it.
+type goPackage struct {
+	importPath string
+	// dir is the directory of the package, relative to the workdir.
+	dir  string
+	deps []string
+}
+
+// affectedPackages returns the patterns of the packages of the workdir that contain one of
+// files, or import such a package. It returns nil when files change the module itself.
+func affectedPackages(ctx context.Context, metadata PipelineActivityMetadata, files []string) ([]string, error) {
+	for _, file := range files {
+		switch path.Base(file) {
+		case "go.mod", "go.sum", "go.work", "go.work.sum":
+			return nil, nil
+		}
+	}
+
+	packages, err := listPackages(ctx, metadata)
+	if err != nil {
+		return nil, err
+	}
+	byDir := map[string]goPackage{}
+	for _, pkg := range packages {
+		byDir[pkg.dir] = pkg
+	}
+
+	// A file belongs to the package of its closest directory that is one, which covers
+	// testdata and embedded files as well as Go files.
+	changed := map[string]bool{}
+	for _, file := range files {
+		for dir := path.Dir(file); ; dir = path.Dir(dir) {
+			if pkg, ok := byDir[dir]; ok {
+				changed[pkg.importPath] = true
+				break
+			}
+			if dir == "." {
+				break
+			}
+		}
+	}
+
+	var patterns []string
+	for _, pkg := range packages {
+		affected := changed[pkg.importPath]
+		for _, dep := range pkg.deps {
+			affected = affected || changed[dep]
+		}
+		if affected {
+			patterns = append(patterns, packagePattern(pkg.dir))
+		}
+	}
+	sort.Strings(patterns)
+	return patterns, nil
+}
+
+// listPackages lists the packages of the workdir with their dependencies.
+func listPackages(ctx context.Context, metadata PipelineActivityMetadata) ([]goPackage, error) {
+	// go list reports directories with symlinks resolved, e.g. under a /tmp linked to /private/tmp.
+	workdir, err := filepath.EvalSymlinks(metadata.Workdir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The dependencies of every package easily exceed MaxCommandOutput in a large module, so
+	// they are parsed as go list prints them.
+	var packages []goPackage
+	lines := &lineWriter{fn: func(line string) {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return
+		}
+		dir, err := filepath.Rel(workdir, fields[1])
+		if err != nil || !filepath.IsLocal(dir) {
+			return
+		}
+		packages = append(packages, goPackage{importPath: fields[0], dir: filepath.ToSlash(dir), deps: strings.Fields(fields[2])})
+	}}
+	_, stderr, err := runCommand(ctx, metadata.Workdir, commandOptions{Env: goEnv(metadata), Stdout: lines},
+		"go", "list", "-e", "-f", `{{.ImportPath}}{{"\t"}}{{.Dir}}{{"\t"}}{{join .Deps " "}}`, "./...")
+	lines.Flush()
+	if err != nil {
+		return nil, fmt.Errorf("running go list command: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return packages, nil
+}
+
+// packagePattern is the go command pattern of the package in dir, relative to the workdir.
+func packagePattern(dir string) string {
+	if dir == "." {
+		return "."
+	}
+	return "./" + dir
+}
@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestDetectChangesActivity(t *testing.T) {
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+
+	// A module where b imports a and c is independent, with main as the base branch.
+	repo := func(t *testing.T) string {
+		workdir := gitRepo(t, map[string]string{
+			"go.mod":    "module example.com/changes\n\ngo 1.22\n",
+			"a/a.go":    "package a\n\nconst A = 1\n",
+			"b/b.go":    "package b\n\nimport \"example.com/changes/a\"\n\nconst B = a.A\n",
+			"c/c.go":    "package c\n\nconst C = 3\n",
+			"README.md": "# changes\n",
+		})
+		git(t, workdir, "checkout", "-b", "feature")
+		return workdir
+	}
+	detect := func(t *testing.T, workdir string) DetectChangesResult {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.DetectChanges)
+
+		val, err := env.ExecuteActivity(pa.DetectChanges, DetectChangesParams{Metadata: PipelineActivityMetadata{Workdir: workdir}, Base: "main"})
+		assert.NoError(t, err)
+		var result DetectChangesResult
+		assert.NoError(t, val.Get(&result))
+		return result
+	}
+
+	t.Run("Changed package and its importers", func(t *testing.T) {
+		workdir := repo(t)
+		assert.NoError(t, os.WriteFile(filepath.Join(workdir, "a/a.go"), []byte("package a\n\nconst A = 2\n"), 0o644))
+		git(t, workdir, "commit", "-am", "Change a")
+
+		result := detect(t, workdir)
+		assert.Equal(t, []string{"a/a.go"}, result.Files)
+		assert.Equal(t, []string{"./a", "./b"}, result.Packages)
+	})
+
+	t.Run("Changed go.mod", func(t *testing.T) {
+		workdir := repo(t)
+		assert.NoError(t, os.WriteFile(filepath.Join(workdir, "go.mod"), []byte("module example.com/changes\n\ngo 1.22.5\n"), 0o644))
+		git(t, workdir, "commit", "-am", "Bump go")
+
+		result := detect(t, workdir)
+		assert.Equal(t, []string{"go.mod"}, result.Files)
+		assert.Empty(t, result.Packages)
+	})
+
+	t.Run("Unknown base", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.DetectChanges)
+
+		_, err := env.ExecuteActivity(pa.DetectChanges, DetectChangesParams{Metadata: PipelineActivityMetadata{Workdir: repo(t)}, Base: "missing"})
+		assert.ErrorContains(t, err, "InvalidDiffBase")
+	})
+}
+
+func TestDiffBase(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.DetectChanges, mock.Anything, mock.MatchedBy(func(p DetectChangesParams) bool {
+		return p.Base == "origin/main"
+	})).Return(&DetectChangesResult{Files: []string{"a/a.go"}, Packages: []string{"./a", "./b"}}, nil)
+	var tested, built []string
+	env.OnActivity(pa.GoTest, mock.Anything, mock.MatchedBy(func(p GoTestParams) bool {
+		tested = p.Packages
+		return true
+	})).Return(&GoTestResult{}, nil)
+	env.OnActivity(pa.GoBuild, mock.Anything, mock.MatchedBy(func(p GoBuildParams) bool {
+		built = p.Packages
+		return true
+	})).Return(&GoBuildResult{}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, DiffBase: "origin/main"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	assert.Equal(t, []string{"./a", "./b"}, tested)
+	assert.Equal(t, []string{"./a", "./b"}, built)
+
+	params := PipelineParams{GitURL: gitUrl, DiffBase: "origin/main", CommitRange: "v1..v2"}
+	assert.ErrorContains(t, params.Validate(), "DiffBase cannot be used")
+}
@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// cloneTimeout is the StartToClose timeout of GitClone. It covers the wait for a clone slot as
+// well as the clone, which the pipeline's 10s activity timeout is too short for.
+const cloneTimeout = 30 * time.Minute
+
+// CloneLimiter caps how many clones a worker runs at once against each git host, so a burst
+// of pipelines doesn't overwhelm or get rate limited by a shared git server. Clones past the
+// limit wait for a slot rather than fail.
+type CloneLimiter struct {
+	limit int
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// NewCloneLimiter returns a CloneLimiter allowing limit clones per host, or nil, which doesn't
+// limit clones, when limit isn't positive.
+func NewCloneLimiter(limit int) *CloneLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &CloneLimiter{limit: limit, slots: map[string]chan struct{}{}}
+}
+
+// acquire waits for a clone slot for the host of remote, until ctx is done. The returned func
+// releases the slot.
+func (l *CloneLimiter) acquire(ctx context.Context, remote string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	host := remoteHost(remote)
+	l.mu.Lock()
+	slots, ok := l.slots[host]
+	if !ok {
+		slots = make(chan struct{}, l.limit)
+		l.slots[host] = slots
+	}
+	l.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	default:
+	}
+	activity.GetLogger(ctx).Info("Waiting for a clone slot", "host", host, "limit", l.limit)
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// remoteHost returns the host of a git remote, either a URL or scp-like, e.g.
+// git@github.com:org/repo.git. Local paths have no host and share the empty one.
+func remoteHost(remote string) string {
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	if !strings.Contains(remote, "://") {
+		if hostPath, _, ok := strings.Cut(remote, ":"); ok && !strings.Contains(hostPath, "/") {
+			_, host, _ := strings.Cut(hostPath, "@")
+			if host == "" {
+				host = hostPath
+			}
+			return host
+		}
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestRemoteHost(t *testing.T) {
+	for remote, host := range map[string]string{
+		"https://github.com/afanwang/go-sample.git": "github.com",
+		"ssh://git@git.internal:2222/team/repo.git": "git.internal",
+		"git@git.internal:team/repo.git":            "git.internal",
+		"git.internal:team/repo.git":                "git.internal",
+		"/srv/git/repo.git":                         "",
+		"./relative/repo":                           "",
+	} {
+		assert.Equal(t, host, remoteHost(remote), remote)
+	}
+}
+
+func TestCloneLimiter(t *testing.T) {
+	remote := gitRepo(t, nil)
+
+	// A git whose clones record when they start and end, and take long enough to overlap.
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clones := filepath.Join(t.TempDir(), "clones")
+	fakeTool(t, "git", fmt.Sprintf(`if [ "$1" = clone ]; then
+	echo start >> %[1]q
+	sleep 0.3
+	echo end >> %[1]q
+fi
+exec %[2]q "$@"
+`, clones, realGit))
+
+	activities := &PipelineActivity{CloneLimiter: NewCloneLimiter(1)}
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestActivityEnvironment()
+			env.RegisterActivity(activities)
+			_, err := env.ExecuteActivity(activities.GitClone, GitCloneParams{
+				Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+				Remote:   remote,
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	content, err := os.ReadFile(clones)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"start", "end", "start", "end"}, strings.Fields(string(content)))
+}
+
+func TestCloneSlotWait(t *testing.T) {
+	t.Run("Heartbeats while waiting", func(t *testing.T) {
+		remote := gitRepo(t, nil)
+
+		interval := heartbeatInterval
+		heartbeatInterval = 10 * time.Millisecond
+		t.Cleanup(func() { heartbeatInterval = interval })
+
+		// Another clone holds the only slot for a while.
+		activities := &PipelineActivity{CloneLimiter: NewCloneLimiter(1)}
+		release, err := activities.CloneLimiter.acquire(context.Background(), remote)
+		assert.NoError(t, err)
+		time.AfterFunc(200*time.Millisecond, release)
+
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		var heartbeats atomic.Int32
+		env.SetOnActivityHeartbeatListener(func(*activity.Info, converter.EncodedValues) { heartbeats.Add(1) })
+		env.RegisterActivity(activities)
+		_, err = env.ExecuteActivity(activities.GitClone, GitCloneParams{
+			Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+			Remote:   remote,
+		})
+		assert.NoError(t, err)
+		// The SDK throttles heartbeats, so only the first of them reaches the listener.
+		assert.Positive(t, heartbeats.Load())
+	})
+
+	t.Run("Slot held past the activity timeout", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		// The clone waits 15s for a slot, longer than the 10s the other activities get.
+		env.OnActivity(pa.GitClone, mock.Anything, mock.Anything).After(15 * time.Second).Return(func(ctx context.Context, _ GitCloneParams) (*GitCloneResult, error) {
+			info := activity.GetInfo(ctx)
+			assert.Equal(t, cloneTimeout, info.Deadline.Sub(info.StartedTime))
+			assert.Equal(t, heartbeatTimeout, info.HeartbeatTimeout)
+			return &GitCloneResult{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}}, nil
+		})
+		mockSetupActivities(env)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertNumberOfCalls(t, "GitClone", 1)
+	})
+}
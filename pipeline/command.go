@@ -0,0 +1,230 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/activity"
+)
+
+// tracer starts a span per command, as a child of the activity's span when the worker exports
+// traces. It is a no-op until a tracer provider is set.
+var tracer = otel.Tracer("temporal-workflow/pipeline")
+
+// DefaultMaxCommandOutput is the default MaxCommandOutput.
+const DefaultMaxCommandOutput = 10 << 20
+
+// MaxCommandOutput caps the bytes of stdout, and of stderr, kept in memory for each command, so
+// a command printing gigabytes can't run the worker out of memory. The worker sets it from its
+// configuration before it starts.
+var MaxCommandOutput = DefaultMaxCommandOutput
+
+// commandOptions holds what some commands need besides a directory and arguments.
+type commandOptions struct {
+	// Env is the environment of the command; nil inherits the worker's.
+	Env []string
+	// Logs, when set, also receives the output of the command, for the Logs of a check result.
+	Logs *logTail
+	// Stdout, when set, receives the whole stdout of the command as it is written, e.g. a
+	// lineWriter parsing it, and runCommand returns no stdout. Output that is parsed must not go
+	// through the cap of MaxCommandOutput, which would drop results from its middle.
+	Stdout io.Writer
+	// StackDump makes the command print its goroutine stacks before it is killed when ctx is
+	// done, see stackDumpOnCancel.
+	StackDump bool
+}
+
+// runCommand runs name with args in dir and returns what it wrote to stdout and stderr. The
+// output is returned along with any error, so callers can still parse the findings of tools that
+// exit with a non-zero status to report them. Failures are logged with the output of the command.
+func runCommand(ctx context.Context, dir string, opts commandOptions, name string, args ...string) (stdout, stderr string, err error) {
+	activityLogger(ctx).Info("Running command", "command", name, "args", args, "dir", dir)
+
+	spanName := name
+	if len(args) > 0 {
+		spanName += " " + args[0]
+	}
+	// The arguments aren't recorded, as they can hold credentials, e.g. in a clone URL.
+	ctx, span := tracer.Start(ctx, spanName, trace.WithAttributes(
+		attribute.String("process.executable.name", name),
+	))
+	defer span.End()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	if opts.StackDump {
+		stackDumpOnCancel(cmd)
+	}
+	outBuf, errBuf := &cappedBuffer{limit: MaxCommandOutput}, &cappedBuffer{limit: MaxCommandOutput}
+	cmd.Stdout = io.Writer(outBuf)
+	if opts.Stdout != nil {
+		cmd.Stdout = opts.Stdout
+	}
+	cmd.Stderr = io.Writer(errBuf)
+	if opts.Logs != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, opts.Logs)
+		cmd.Stderr = io.MultiWriter(errBuf, opts.Logs)
+	}
+	cmd.Dir = dir
+	cmd.Env = opts.Env
+
+	err = cmd.Run()
+	if cmd.ProcessState != nil {
+		span.SetAttributes(attribute.Int("process.exit.code", cmd.ProcessState.ExitCode()))
+	}
+	if outBuf.dropped > 0 || errBuf.dropped > 0 {
+		activityLogger(ctx).Warn("Command output truncated", "command", name, "stdoutDropped", outBuf.dropped, "stderrDropped", errBuf.dropped)
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		activityLogger(ctx).Warn("Command failed", "command", name, "error", err, "stderr", errBuf.String(), "stdout", outBuf.String())
+	}
+	return outBuf.String(), errBuf.String(), err
+}
+
+// cappedBuffer captures up to limit bytes of output: the first half of it, and the last bytes
+// written once it is full, so both how a command started and how it ended are kept.
+type cappedBuffer struct {
+	limit int
+	head  []byte
+	// tail is a ring buffer once it is full, with its oldest byte at next.
+	tail    []byte
+	next    int
+	dropped int64
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	headCap, tailCap := b.limit/2, b.limit-b.limit/2
+	if room := headCap - len(b.head); room > 0 {
+		k := min(room, len(p))
+		b.head = append(b.head, p[:k]...)
+		p = p[k:]
+	}
+	if room := tailCap - len(b.tail); room > 0 {
+		k := min(room, len(p))
+		b.tail = append(b.tail, p[:k]...)
+		p = p[k:]
+	}
+	// Only the last tailCap bytes of p can survive.
+	if over := len(p) - tailCap; over > 0 {
+		b.dropped += int64(over)
+		p = p[over:]
+	}
+	for len(p) > 0 {
+		k := copy(b.tail[b.next:], p)
+		b.dropped += int64(k)
+		b.next = (b.next + k) % tailCap
+		p = p[k:]
+	}
+	return n, nil
+}
+
+// String returns the captured output, with a line marking where output was dropped, if any.
+func (b *cappedBuffer) String() string {
+	if b.dropped == 0 {
+		return string(b.head) + string(b.tail)
+	}
+	return fmt.Sprintf("%s\n[%d bytes of output truncated]\n%s%s", b.head, b.dropped, b.tail[b.next:], b.tail[:b.next])
+}
+
+// lineWriter calls fn with each line written to it, without its line ending. Flush must be
+// called once the writes are done to pass on a last line lacking one.
+type lineWriter struct {
+	fn  func(line string)
+	buf []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	rest := w.buf
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(strings.TrimSuffix(string(rest[:i]), "\r"))
+		rest = rest[i+1:]
+	}
+	w.buf = append(w.buf[:0], rest...)
+	return len(p), nil
+}
+
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.fn(string(w.buf))
+		w.buf = w.buf[:0]
+	}
+}
+
+// commandEnv returns the worker's environment augmented with env, for commands that aren't Go
+// commands, such as deploys.
+func commandEnv(env map[string]string) []string {
+	cmdEnv := os.Environ()
+	for k, v := range env {
+		cmdEnv = append(cmdEnv, k+"="+v)
+	}
+	return cmdEnv
+}
+
+// activityLogger is the default logger with the WorkflowID, RunID and ActivityType of the
+// activity ctx belongs to, so that the logs of a run can be grepped out of aggregated logs.
+// Outside of an activity, e.g. for git commands run by tests, it is the default logger.
+func activityLogger(ctx context.Context) *slog.Logger {
+	if !activity.IsActivity(ctx) {
+		return slog.Default()
+	}
+	info := activity.GetInfo(ctx)
+	return slog.With("WorkflowID", info.WorkflowExecution.ID, "RunID", info.WorkflowExecution.RunID,
+		"ActivityType", info.ActivityType.Name)
+}
+
+// commandTimeout is the StartToClose timeout of the activities that run the repository's own
+// commands, or that wait on the network: GoModDownload, GoTest retrying failed tests,
+// GoBenchmark, RunScript, PreDeploy and SmokeTest. The pipeline's 10s default only suits the
+// quick checks.
+const commandTimeout = 15 * time.Minute
+
+// heartbeatTimeout is the heartbeat timeout of the long-running activities that heartbeat, so one
+// lost with its worker is retried long before its StartToClose timeout.
+const heartbeatTimeout = time.Minute
+
+// heartbeatInterval is how often those activities heartbeat.
+var heartbeatInterval = 10 * time.Second
+
+// heartbeat records a heartbeat every interval until the returned func is called.
+func heartbeat(ctx context.Context, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ticker.C:
+				activity.RecordHeartbeat(ctx)
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestRunCommand(t *testing.T) {
+	logs := &logTail{}
+	stdout, stderr, err := runCommand(context.Background(), t.TempDir(), commandOptions{Env: []string{"GREETING=hello"}, Logs: logs},
+		"sh", "-c", `echo "$GREETING"; echo oops >&2; exit 3`)
+
+	var exitErr *exec.ExitError
+	if assert.ErrorAs(t, err, &exitErr) {
+		assert.Equal(t, 3, exitErr.ExitCode())
+	}
+	assert.Equal(t, "hello\n", stdout)
+	assert.Equal(t, "oops\n", stderr)
+	assert.Contains(t, logs.String(), "hello\n")
+	assert.Contains(t, logs.String(), "oops\n")
+}
+
+func TestRunCommandOutputCap(t *testing.T) {
+	defer func(limit int) { MaxCommandOutput = limit }(MaxCommandOutput)
+	MaxCommandOutput = 1000
+
+	// 120000 bytes of numbered lines, far over the cap.
+	stdout, _, err := runCommand(context.Background(), t.TempDir(), commandOptions{}, "seq", "-w", "1", "20000")
+	assert.NoError(t, err)
+	assert.Less(t, len(stdout), 1100)
+	assert.True(t, strings.HasPrefix(stdout, "00001\n00002\n"), "the head of the output is kept")
+	assert.True(t, strings.HasSuffix(stdout, "19999\n20000\n"), "the tail of the output is kept")
+	assert.Contains(t, stdout, "\n[119000 bytes of output truncated]\n")
+}
+
+func TestCappedBuffer(t *testing.T) {
+	b := &cappedBuffer{limit: 8}
+	for _, s := range []string{"ab", "cd", "efghij", "k"} {
+		n, err := b.Write([]byte(s))
+		assert.NoError(t, err)
+		assert.Equal(t, len(s), n)
+	}
+	assert.Equal(t, "abcd\n[3 bytes of output truncated]\nhijk", b.String())
+
+	b = &cappedBuffer{limit: 8}
+	b.Write([]byte("abc"))
+	assert.Equal(t, "abc", b.String(), "output under the cap is kept whole")
+}
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := &lineWriter{fn: func(line string) { lines = append(lines, line) }}
+	for _, chunk := range []string{"fir", "st\nsecond\r\n", "\nla", "st"} {
+		_, _ = w.Write([]byte(chunk))
+	}
+	assert.Equal(t, []string{"first", "second", ""}, lines)
+	w.Flush()
+	assert.Equal(t, []string{"first", "second", "", "last"}, lines)
+}
+
+func TestActivityLogger(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	var out bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&out, nil)))
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.CheckDiskSpace)
+	_, err := env.ExecuteActivity(pa.CheckDiskSpace, CheckDiskSpaceParams{WorkdirRoot: t.TempDir(), MinFreeBytes: 1})
+	assert.NoError(t, err)
+
+	var record map[string]any
+	line, _, _ := strings.Cut(out.String(), "\n")
+	if assert.NoError(t, json.Unmarshal([]byte(line), &record)) {
+		assert.Equal(t, "Checking free disk space", record["msg"])
+		assert.Equal(t, "default-test-workflow-id", record["WorkflowID"])
+		assert.Equal(t, "default-test-run-id", record["RunID"])
+		assert.Equal(t, "CheckDiskSpace", record["ActivityType"])
+	}
+
+	// Outside of an activity, there is nothing to correlate with.
+	out.Reset()
+	activityLogger(context.Background()).Info("Running tests")
+	assert.NotContains(t, out.String(), "WorkflowID")
+}
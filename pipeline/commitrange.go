@@ -0,0 +1,195 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/workflow"
+)
+
+// CommitResult is the outcome of the checks run against one commit of a commit range.
+type CommitResult struct {
+	SHA      string            `json:"sha"`
+	Failures []PipelineFailure `json:"failures"`
+}
+
+// ListCommits params and results
+type ListCommitsParams struct {
+	Metadata PipelineActivityMetadata
+	// Range is a git revision range such as base..head.
+	Range string
+	// CACertPath and Insecure verify the remote's certificate like those of GitCloneParams, for
+	// deepening a shallow clone.
+	CACertPath string
+	Insecure   bool
+}
+
+type ListCommitsResult struct {
+	// Commits are full SHAs, oldest first.
+	Commits []string
+}
+
+// ListCommits lists the commits of a range in the cloned workdir, oldest first. A shallow clone
+// is deepened first, since the range may reach past the fetched history.
+func (pa *PipelineActivity) ListCommits(ctx context.Context, params ListCommitsParams) (*ListCommitsResult, error) {
+	logger := activity.GetLogger(ctx)
+
+	env := gitEnv(params.CACertPath, params.Insecure)
+	shallow, err := runGitEnv(ctx, params.Metadata.Workdir, env, "rev-parse", "--is-shallow-repository")
+	if err != nil {
+		return nil, err
+	}
+	if shallow == "true" {
+		if _, err := runGitEnv(ctx, params.Metadata.Workdir, env, "fetch", "--unshallow"); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := runGitEnv(ctx, params.Metadata.Workdir, env, "rev-list", "--reverse", params.Range)
+	if err != nil {
+		return nil, err
+	}
+	result := &ListCommitsResult{Commits: strings.Fields(out)}
+	logger.Info("Listed commits", "range", params.Range, "count", len(result.Commits))
+	return result, nil
+}
+
+// GitCheckout params and results
+type GitCheckoutParams struct {
+	Metadata PipelineActivityMetadata
+	Commit   string
+}
+
+type GitCheckoutResult struct{}
+
+// GitCheckout checks out a commit of the cloned workdir, detaching HEAD.
+func (pa *PipelineActivity) GitCheckout(ctx context.Context, params GitCheckoutParams) (*GitCheckoutResult, error) {
+	if _, err := runGit(ctx, params.Metadata.Workdir, "checkout", "--detach", params.Commit); err != nil {
+		return nil, err
+	}
+	return &GitCheckoutResult{}, nil
+}
+
+// DefaultBisectMaxSteps bounds the commits a bisect checks when PipelineParams.BisectMaxSteps is zero.
+const DefaultBisectMaxSteps = 20
+
+// checkCommitRange runs the checks against the commits of params.CommitRange and records the
+// first one that fails as result.FirstBadCommit. Commits are checked oldest first, stopping at the
+// first failure, or bisected when params.Bisect is set.
+func checkCommitRange(ctx workflow.Context, params PipelineParams, metadata PipelineActivityMetadata, result *PipelineResult) error {
+	rCommits := &ListCommitsResult{}
+	err := workflow.ExecuteActivity(ctx, pa.ListCommits, ListCommitsParams{Metadata: metadata, Range: params.CommitRange, CACertPath: params.GitCACertPath, Insecure: params.GitInsecure}).Get(ctx, rCommits)
+	if err != nil {
+		return fmt.Errorf("ListCommits activity: %w", err)
+	}
+	if params.Bisect {
+		return bisectCommits(ctx, params, metadata, rCommits.Commits, result)
+	}
+
+	for _, sha := range rCommits.Commits {
+		commit, err := checkCommit(ctx, params, metadata, sha)
+		if err != nil {
+			return err
+		}
+		result.Commits = append(result.Commits, commit)
+
+		if len(commit.Failures) > 0 {
+			workflow.GetLogger(ctx).Info("Found first failing commit", "sha", sha)
+			result.FirstBadCommit = sha
+			result.Failures = append(result.Failures, PipelineFailure{Activity: "CommitRange", Kind: combinedKind(commit.Failures), Details: commit})
+			return nil
+		}
+	}
+	return nil
+}
+
+// bisectCommits binary searches commits for the first one that fails, like git bisect. It
+// assumes the base of the range passes and that once a commit fails, every later one does too.
+// Head is checked first, then base, so a range that doesn't break is confirmed in one step and
+// one that was already broken isn't bisected.
+func bisectCommits(ctx workflow.Context, params PipelineParams, metadata PipelineActivityMetadata, commits []string, result *PipelineResult) error {
+	logger := workflow.GetLogger(ctx)
+	if len(commits) == 0 {
+		return nil
+	}
+	maxSteps := params.BisectMaxSteps
+	if maxSteps == 0 {
+		maxSteps = DefaultBisectMaxSteps
+	}
+
+	check := func(sha string) (CommitResult, error) {
+		commit, err := checkCommit(ctx, params, metadata, sha)
+		if err == nil {
+			result.Commits = append(result.Commits, commit)
+		}
+		return commit, err
+	}
+
+	// good and bad are indexes into commits; -1 is the base of the range.
+	good, bad := -1, len(commits)-1
+	badCommit, err := check(commits[bad])
+	if err != nil || len(badCommit.Failures) == 0 {
+		return err
+	}
+	base, _, _ := strings.Cut(params.CommitRange, "..")
+	if baseCommit, err := check(base); err != nil {
+		return err
+	} else if len(baseCommit.Failures) > 0 {
+		result.Failures = append(result.Failures, PipelineFailure{Activity: "Bisect", Kind: combinedKind(baseCommit.Failures), Details: fmt.Sprintf("base %s already fails", base)})
+		return nil
+	}
+
+	for bad-good > 1 {
+		if len(result.Commits) >= maxSteps {
+			result.Failures = append(result.Failures, PipelineFailure{
+				Activity: "Bisect",
+				Kind:     FailureIssues,
+				Details: fmt.Sprintf("stopped after %d steps: the first failing commit is after %s and at or before %s",
+					len(result.Commits), bisectCommit(commits, base, good), commits[bad]),
+			})
+			return nil
+		}
+		mid := good + (bad-good)/2
+		commit, err := check(commits[mid])
+		if err != nil {
+			return err
+		}
+		if len(commit.Failures) == 0 {
+			good = mid
+		} else {
+			bad, badCommit = mid, commit
+		}
+	}
+
+	logger.Info("Bisect found first failing commit", "sha", badCommit.SHA)
+	result.FirstBadCommit = badCommit.SHA
+	result.Failures = append(result.Failures, PipelineFailure{Activity: "Bisect", Kind: combinedKind(badCommit.Failures), Details: badCommit})
+	return nil
+}
+
+// bisectCommit returns the commit at index i of a bisect, where -1 is base.
+func bisectCommit(commits []string, base string, i int) string {
+	if i < 0 {
+		return base
+	}
+	return commits[i]
+}
+
+// checkCommit checks out sha and runs the checks against it.
+func checkCommit(ctx workflow.Context, params PipelineParams, metadata PipelineActivityMetadata, sha string) (CommitResult, error) {
+	err := workflow.ExecuteActivity(ctx, pa.GitCheckout, GitCheckoutParams{Metadata: metadata, Commit: sha}).Get(ctx, nil)
+	if err != nil {
+		return CommitResult{}, fmt.Errorf("GitCheckout activity: %w", err)
+	}
+
+	// Each commit gets its own result, so test results don't accumulate across commits.
+	commit := CommitResult{SHA: sha, Failures: []PipelineFailure{}}
+	commitResult := &PipelineResult{}
+	heavyCtx := workflow.WithTaskQueue(ctx, HeavyTaskQueue(workflow.GetInfo(ctx).TaskQueueName))
+	for _, check := range startChecks(ctx, heavyCtx, params, metadata, params.RepoSpecs(), nil) {
+		commit.Failures = append(commit.Failures, checkFailures(ctx, check.name, check.future, params, commitResult)...)
+	}
+	return commit, nil
+}
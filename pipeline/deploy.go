@@ -0,0 +1,373 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// PipelineWorkflowIDSearchAttribute links a DeployWorkflow to the PipelineWorkflow that started it.
+// It must be registered on the server, e.g. `temporal operator search-attribute create
+// --name PipelineWorkflowID --type Keyword`.
+var PipelineWorkflowIDSearchAttribute = temporal.NewSearchAttributeKeyKeyword("PipelineWorkflowID")
+
+type DeployWorkflowParams struct {
+	Deploy GoDeployParams
+	Retry  RetryParams
+	// Timeout bounds the deploy command, as PipelineParams.DeployTimeout.
+	Timeout time.Duration
+}
+
+// DeployWorkflow runs the deploy step with its own history, so it can be queried, cancelled,
+// and retained independently of the checks that gated it.
+func DeployWorkflow(ctx workflow.Context, params DeployWorkflowParams) (*GoDeployResult, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+		RetryPolicy:         newRetryPolicy(params.Retry),
+	})
+	ctx = withDeployOptions(ctx, params.Deploy, params.Timeout)
+
+	rDeploy := &GoDeployResult{}
+	if err := workflow.ExecuteActivity(ctx, pa.GoDeploy, params.Deploy).Get(ctx, rDeploy); err != nil {
+		return nil, err
+	}
+	return rDeploy, nil
+}
+
+// Deployment statuses recorded in DeploymentResult.
+const (
+	DeploymentSucceeded = "succeeded"
+	DeploymentFailed    = "failed"
+	DeploymentSkipped   = "skipped"
+)
+
+// DeploymentResult is the outcome of deploying to one environment.
+type DeploymentResult struct {
+	Environment string `json:"environment"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	RolledBack  bool   `json:"rolled_back,omitempty"`
+}
+
+// ApprovalSignalName is the signal that approves deploying to the named environment.
+func ApprovalSignalName(environment string) string {
+	return "approve-" + environment
+}
+
+// DeployApprovalSignalName is the signal that approves the deploy of a PipelineWorkflow with
+// RequireApproval set.
+const DeployApprovalSignalName = "approve"
+
+// DefaultApprovalTimeout is the default PipelineParams.ApprovalTimeout.
+const DefaultApprovalTimeout = 30 * time.Minute
+
+func (pp *PipelineParams) approvalTimeout() time.Duration {
+	if pp.ApprovalTimeout > 0 {
+		return pp.ApprovalTimeout
+	}
+	return DefaultApprovalTimeout
+}
+
+// Outcomes of awaitApproval.
+const (
+	approvalGranted = iota
+	approvalAborted
+	approvalTimedOut
+	approvalCancelled
+)
+
+// awaitApproval waits up to timeout for the signalName signal. Approvals signalled before the
+// wait, e.g. for a previous run of a Repeat pipeline, don't count. An abort signal, or ctx being
+// cancelled, ends the wait too.
+func awaitApproval(ctx workflow.Context, signalName string, timeout time.Duration, abortCh workflow.ReceiveChannel) int {
+	approveCh := workflow.GetSignalChannel(ctx, signalName)
+	for approveCh.ReceiveAsync(nil) {
+		workflow.GetLogger(ctx).Info("Ignoring an approval signalled before the pipeline waited for one", "signal", signalName)
+	}
+
+	timerCtx, cancelTimer := workflow.WithCancel(ctx)
+	defer cancelTimer()
+	outcome := approvalCancelled
+	selector := workflow.NewSelector(ctx)
+	selector.AddReceive(approveCh, func(c workflow.ReceiveChannel, more bool) {
+		c.Receive(ctx, nil)
+		outcome = approvalGranted
+	})
+	selector.AddReceive(abortCh, func(c workflow.ReceiveChannel, more bool) {
+		c.Receive(ctx, nil)
+		outcome = approvalAborted
+	})
+	selector.AddFuture(workflow.NewTimer(timerCtx, timeout), func(f workflow.Future) {
+		// The timer is cancelled along with ctx, which isn't a missed approval.
+		if ctx.Err() == nil {
+			outcome = approvalTimedOut
+		}
+	})
+	selector.AddReceive(ctx.Done(), func(c workflow.ReceiveChannel, more bool) {})
+	selector.Select(ctx)
+	return outcome
+}
+
+// abortFailure is the failure recorded for a pipeline aborted by AbortSignalName.
+var abortFailure = PipelineFailure{Activity: "Abort", Kind: FailureAborted, Details: "pipeline aborted by signal"}
+
+// waitForApproval waits up to the approval timeout of params for DeployApprovalSignalName, as
+// awaitApproval, and records the deploy as skipped when it doesn't come in time.
+func waitForApproval(ctx workflow.Context, params PipelineParams, abortCh workflow.ReceiveChannel, result *PipelineResult, progress *PipelineProgress) {
+	logger := workflow.GetLogger(ctx)
+	timeout := params.approvalTimeout()
+	logger.Info("Waiting for deploy approval", "timeout", timeout)
+	progress.set("Approval", StepRunning)
+
+	switch awaitApproval(ctx, DeployApprovalSignalName, timeout, abortCh) {
+	case approvalGranted:
+		logger.Info("Deploy approved")
+		progress.set("Approval", StepDone)
+	case approvalAborted:
+		result.Failures = append(result.Failures, abortFailure)
+		progress.set("Approval", StepFailed)
+	case approvalTimedOut:
+		logger.Info("Deploy not approved in time, skipping deploy")
+		result.DeploySkipped = fmt.Sprintf("deploy not approved within %s", timeout)
+		progress.set("Approval", StepFailed)
+	case approvalCancelled:
+		progress.set("Approval", StepSkipped)
+	}
+}
+
+// deployEnvironments promotes through params.DeployEnvironments in order, waiting for approval
+// where required and smoke testing after each deploy. Approvals are awaited on limitCtx, so
+// MaxDuration bounds them, and up to the approval timeout of params. Once an environment fails or
+// isn't approved, the remaining ones are skipped; an abort signal during an approval wait records
+// an Abort failure in result.
+func deployEnvironments(ctx, limitCtx workflow.Context, params PipelineParams, metadata PipelineActivityMetadata, abortCh workflow.ReceiveChannel, result *PipelineResult) []DeploymentResult {
+	logger := workflow.GetLogger(ctx)
+	results := make([]DeploymentResult, 0, len(params.DeployEnvironments))
+
+	stopped := false
+	for _, env := range params.DeployEnvironments {
+		if stopped {
+			results = append(results, DeploymentResult{Environment: env.Name, Status: DeploymentSkipped})
+			continue
+		}
+
+		if env.RequireApproval {
+			timeout := params.approvalTimeout()
+			logger.Info("Waiting for deploy approval", "environment", env.Name, "timeout", timeout)
+			skipped := DeploymentResult{Environment: env.Name, Status: DeploymentSkipped}
+			switch awaitApproval(limitCtx, ApprovalSignalName(env.Name), timeout, abortCh) {
+			case approvalAborted:
+				result.Failures = append(result.Failures, abortFailure)
+				skipped.Error = "pipeline aborted by signal"
+			case approvalTimedOut:
+				skipped.Error = fmt.Sprintf("deploy not approved within %s", timeout)
+			case approvalCancelled:
+				skipped.Error = "pipeline exceeded its MaxDuration"
+			}
+			if skipped.Error != "" {
+				logger.Info("Skipping deploy", "environment", env.Name, "reason", skipped.Error)
+				results = append(results, skipped)
+				stopped = true
+				continue
+			}
+		}
+
+		deployment := DeploymentResult{Environment: env.Name, Status: DeploymentSucceeded}
+		rDeploy, err := runDeploy(ctx, params, GoDeployParams{
+			Metadata:      metadata,
+			DeployCommand: env.Command,
+			DeployEnv:     params.DeployEnv,
+		}, env.Name)
+		switch {
+		case err != nil:
+			deployment.Status, deployment.Error = DeploymentFailed, err.Error()
+		case rDeploy.ErrorMsg != "":
+			deployment.Status, deployment.Error = DeploymentFailed, rDeploy.ErrorMsg
+		case len(env.SmokeCommand) > 0:
+			smokeTestEnvironment(ctx, params, metadata, env, &deployment)
+		}
+		stopped = deployment.Status == DeploymentFailed
+		results = append(results, deployment)
+	}
+	return results
+}
+
+// smokeTestEnvironment runs the smoke test of a deployed environment and, if it fails, marks
+// the deployment failed and rolls it back when a rollback command is configured.
+func smokeTestEnvironment(ctx workflow.Context, params PipelineParams, metadata PipelineActivityMetadata, env DeployEnvironment, deployment *DeploymentResult) {
+	rSmoke := &SmokeTestResult{}
+	err := workflow.ExecuteActivity(workflow.WithStartToCloseTimeout(ctx, commandTimeout), pa.SmokeTest, SmokeTestParams{
+		Metadata: metadata,
+		Command:  env.SmokeCommand,
+		Env:      params.DeployEnv,
+	}).Get(ctx, rSmoke)
+	switch {
+	case err != nil:
+		deployment.Status, deployment.Error = DeploymentFailed, fmt.Sprintf("smoke test: %v", err)
+	case !rSmoke.Success:
+		deployment.Status, deployment.Error = DeploymentFailed, rSmoke.ErrorMsg
+	default:
+		return
+	}
+
+	if len(env.RollbackCommand) == 0 {
+		return
+	}
+	rRollback, err := runDeploy(ctx, params, GoDeployParams{
+		Metadata:      metadata,
+		DeployCommand: env.RollbackCommand,
+		DeployEnv:     params.DeployEnv,
+	}, env.Name+"-rollback")
+	if err != nil || rRollback.ErrorMsg != "" {
+		workflow.GetLogger(ctx).Error("Rollback failed", "environment", env.Name, "error", err, "errorMsg", rRollback.ErrorMsg)
+		return
+	}
+	deployment.RolledBack = true
+}
+
+// runDeploy executes the deploy step, either as an activity of the current workflow or, when
+// params.SeparateDeploy is set, as a DeployWorkflow that outlives the PipelineWorkflow.
+// environment distinguishes the DeployWorkflow of each stage of a multi-environment deploy.
+func runDeploy(ctx workflow.Context, params PipelineParams, deployParams GoDeployParams, environment string) (*GoDeployResult, error) {
+	rDeploy := &GoDeployResult{}
+	if !params.SeparateDeploy {
+		err := workflow.ExecuteActivity(withDeployOptions(ctx, deployParams, params.deployTimeout()), pa.GoDeploy, deployParams).Get(ctx, rDeploy)
+		return rDeploy, err
+	}
+
+	parentID := workflow.GetInfo(ctx).WorkflowExecution.ID
+	childID := "DeployWorkflow-" + parentID
+	if environment != "" {
+		childID += "-" + environment
+	}
+	ctx = workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:            childID,
+		ParentClosePolicy:     enumspb.PARENT_CLOSE_POLICY_ABANDON,
+		TypedSearchAttributes: temporal.NewSearchAttributes(PipelineWorkflowIDSearchAttribute.ValueSet(parentID)),
+	})
+	err := workflow.ExecuteChildWorkflow(ctx, DeployWorkflow, DeployWorkflowParams{
+		Deploy:  deployParams,
+		Retry:   params.Retry,
+		Timeout: params.deployTimeout(),
+	}).Get(ctx, rDeploy)
+	return rDeploy, err
+}
+
+// withDeployOptions bounds a GoDeploy running a deploy command by timeout and runs it at most
+// once: retrying after a failure or a timeout would redo a deploy that may be half done. The
+// simulated deploy keeps the options of ctx.
+func withDeployOptions(ctx workflow.Context, deployParams GoDeployParams, timeout time.Duration) workflow.Context {
+	if len(deployParams.DeployCommand) == 0 {
+		return ctx
+	}
+	ctx = workflow.WithStartToCloseTimeout(ctx, timeout)
+	return workflow.WithRetryPolicy(ctx, temporal.RetryPolicy{MaximumAttempts: 1})
+}
+
+// ServiceDeployment is the outcome of deploying one service of a deploy plan.
+type ServiceDeployment struct {
+	Service string `json:"service"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// deployServices deploys params.DeployServices, starting each service as soon as all the services
+// it depends on have succeeded, so independent services deploy in parallel. A service whose
+// dependency failed or was skipped is skipped. Results are returned in plan order.
+func deployServices(ctx workflow.Context, params PipelineParams, metadata PipelineActivityMetadata) []ServiceDeployment {
+	futures := make(map[string]workflow.Future, len(params.DeployServices))
+	settables := make(map[string]workflow.Settable, len(params.DeployServices))
+	for _, service := range params.DeployServices {
+		futures[service.Name], settables[service.Name] = workflow.NewFuture(ctx)
+	}
+
+	for _, service := range params.DeployServices {
+		service := service
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			deployment := ServiceDeployment{Service: service.Name, Status: DeploymentSucceeded}
+			for _, dependency := range service.DependsOn {
+				var dependencyDeployment ServiceDeployment
+				_ = futures[dependency].Get(ctx, &dependencyDeployment)
+				if dependencyDeployment.Status != DeploymentSucceeded {
+					deployment.Status = DeploymentSkipped
+				}
+			}
+			if deployment.Status == DeploymentSkipped {
+				settables[service.Name].Set(deployment, nil)
+				return
+			}
+
+			rDeploy, err := runDeploy(ctx, params, GoDeployParams{
+				Metadata:      metadata,
+				DeployCommand: service.Command,
+				DeployEnv:     params.DeployEnv,
+			}, service.Name)
+			switch {
+			case err != nil:
+				deployment.Status, deployment.Error = DeploymentFailed, err.Error()
+			case rDeploy.ErrorMsg != "":
+				deployment.Status, deployment.Error = DeploymentFailed, rDeploy.ErrorMsg
+			}
+			settables[service.Name].Set(deployment, nil)
+		})
+	}
+
+	results := make([]ServiceDeployment, 0, len(params.DeployServices))
+	for _, service := range params.DeployServices {
+		var deployment ServiceDeployment
+		_ = futures[service.Name].Get(ctx, &deployment)
+		results = append(results, deployment)
+	}
+	return results
+}
+
+// validateDeployServices checks that service names are unique, dependencies exist, and the
+// dependency graph has no cycles, which would otherwise leave the deploy waiting forever.
+func validateDeployServices(services []DeployService) error {
+	dependsOn := make(map[string][]string, len(services))
+	for _, service := range services {
+		if service.Name == "" {
+			return fmt.Errorf("DeployServices name is required")
+		}
+		if _, ok := dependsOn[service.Name]; ok {
+			return fmt.Errorf("DeployServices name %q is duplicated", service.Name)
+		}
+		dependsOn[service.Name] = service.DependsOn
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(services))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("DeployServices has a dependency cycle through %q", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dependency := range dependsOn[name] {
+			if _, ok := dependsOn[dependency]; !ok {
+				return fmt.Errorf("DeployServices %q depends on unknown service %q", name, dependency)
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, service := range services {
+		if err := visit(service.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
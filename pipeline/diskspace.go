@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// CheckDiskSpace params and results
+type CheckDiskSpaceParams struct {
+	// WorkdirRoot overrides the worker's WorkdirRoot, as for GitClone.
+	WorkdirRoot  string
+	MinFreeBytes int64
+}
+
+type CheckDiskSpaceResult struct {
+	FreeBytes int64
+}
+
+// CheckDiskSpace verifies that the filesystem of the workdir root has at least MinFreeBytes free,
+// so a pipeline fails up front instead of midway through a clone or module download.
+func (pa *PipelineActivity) CheckDiskSpace(ctx context.Context, params CheckDiskSpaceParams) (*CheckDiskSpaceResult, error) {
+	root := pa.workdirRoot(params.WorkdirRoot)
+	activityLogger(ctx).Info("Checking free disk space", "root", root)
+
+	free, err := freeBytes(root)
+	if err != nil {
+		return nil, fmt.Errorf("checking free disk space of %q: %w", root, err)
+	}
+	activity.GetLogger(ctx).Info("Free disk space checked", "root", root, "freeBytes", free)
+	if free < params.MinFreeBytes {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("not enough free disk space in %q: %d bytes free, %d required", root, free, params.MinFreeBytes),
+			"InsufficientDiskSpace", nil)
+	}
+	return &CheckDiskSpaceResult{FreeBytes: free}, nil
+}
+
+// workdirRoot returns the directory workdirs are created in: root when set, else the worker's
+// WorkdirRoot, else os.TempDir().
+func (pa *PipelineActivity) workdirRoot(root string) string {
+	if root != "" {
+		return root
+	}
+	if pa.WorkdirRoot != "" {
+		return pa.WorkdirRoot
+	}
+	return os.TempDir()
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd)
+
+package pipeline
+
+import (
+	"errors"
+	"runtime"
+)
+
+// freeBytes is not implemented on this platform.
+func freeBytes(path string) (int64, error) {
+	return 0, errors.New("checking free disk space is not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestCheckDiskSpaceActivity(t *testing.T) {
+	root := t.TempDir()
+
+	t.Run("Enough space", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.CheckDiskSpace)
+
+		val, err := env.ExecuteActivity(pa.CheckDiskSpace, CheckDiskSpaceParams{WorkdirRoot: root, MinFreeBytes: 1})
+		assert.NoError(t, err)
+
+		var result CheckDiskSpaceResult
+		assert.NoError(t, val.Get(&result))
+		assert.Positive(t, result.FreeBytes)
+	})
+
+	t.Run("Not enough space", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.CheckDiskSpace)
+
+		_, err := env.ExecuteActivity(pa.CheckDiskSpace, CheckDiskSpaceParams{WorkdirRoot: root, MinFreeBytes: 1 << 62})
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.True(t, appErr.NonRetryable())
+			assert.Contains(t, appErr.Error(), "not enough free disk space")
+		}
+	})
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.CheckDiskSpace, mock.Anything, CheckDiskSpaceParams{MinFreeBytes: 10 << 30}).
+		Return(nil, temporal.NewNonRetryableApplicationError("not enough free disk space", "InsufficientDiskSpace", nil))
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, MinFreeBytes: 10 << 30})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.ErrorContains(t, env.GetWorkflowError(), "not enough free disk space")
+	env.AssertNotCalled(t, "GitClone", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package pipeline
+
+import "syscall"
+
+// freeBytes returns the space available to unprivileged users on the filesystem of path.
+func freeBytes(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
@@ -0,0 +1,185 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// DefaultDocMinCoverage is the DocMinCoverage used when it is zero: every exported identifier
+// must be documented.
+const DefaultDocMinCoverage = 100.0
+
+// GoDocCheck params and results
+type GoDocCheckParams struct {
+	Metadata PipelineActivityMetadata
+}
+
+type GoDocCheckResult struct {
+	// Exported is the number of exported identifiers and packages checked.
+	Exported int
+	// Undocumented lists those without a doc comment, in file and line order.
+	Undocumented []UndocumentedSymbol
+	// Coverage is the percentage of Exported that is documented, 100 when there is none.
+	Coverage  float64
+	ElapsedMs int64
+}
+
+// UndocumentedSymbol is an exported identifier, or a package, without a doc comment.
+type UndocumentedSymbol struct {
+	// Name is the identifier, e.g. "Client.Close" for a method, or "package foo".
+	Name string `json:"name"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// GoDocCheck parses the Go files of the workdir to find the exported identifiers and packages
+// lacking a doc comment. Only the public API is checked: tests, generated files, commands, and
+// internal, vendor and testdata directories are skipped. Parsing is done in-process, so no tool
+// needs to be installed on the worker.
+func (pa *PipelineActivity) GoDocCheck(ctx context.Context, params GoDocCheckParams) (*GoDocCheckResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &GoDocCheckResult{Undocumented: []UndocumentedSymbol{}}
+
+	start := time.Now()
+	workdir := params.Metadata.Workdir
+	fset := token.NewFileSet()
+	// The files of each package, keyed by directory and package name.
+	packages := map[string][]*ast.File{}
+	err := filepath.WalkDir(workdir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != workdir && (name == "internal" || name == "vendor" || name == "testdata" ||
+				strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			// A file that doesn't parse fails the build and is reported there.
+			logger.Warn("Skipping unparsable file", "file", path, "error", err)
+			return nil
+		}
+		if f.Name.Name == "main" || ast.IsGenerated(f) {
+			return nil
+		}
+		key := filepath.Dir(path) + ":" + f.Name.Name
+		packages[key] = append(packages[key], f)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking %s: %w", workdir, err)
+	}
+
+	relPos := func(pos token.Pos) (string, int) {
+		p := fset.Position(pos)
+		if rel, err := filepath.Rel(workdir, p.Filename); err == nil {
+			return rel, p.Line
+		}
+		return p.Filename, p.Line
+	}
+	check := func(name string, pos token.Pos, documented bool) {
+		result.Exported++
+		if !documented {
+			file, line := relPos(pos)
+			result.Undocumented = append(result.Undocumented, UndocumentedSymbol{Name: name, File: file, Line: line})
+		}
+	}
+	for _, files := range packages {
+		documented := false
+		for _, f := range files {
+			documented = documented || f.Doc != nil
+		}
+		check("package "+files[0].Name.Name, files[0].Name.Pos(), documented)
+		for _, f := range files {
+			for _, decl := range f.Decls {
+				checkDecl(decl, check)
+			}
+		}
+	}
+
+	sort.Slice(result.Undocumented, func(i, j int) bool {
+		a, b := result.Undocumented[i], result.Undocumented[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	result.Coverage = 100
+	if result.Exported > 0 {
+		result.Coverage = 100 * float64(result.Exported-len(result.Undocumented)) / float64(result.Exported)
+	}
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	logger.Info("Checked doc comments", "exported", result.Exported, "undocumented", len(result.Undocumented))
+	return result, nil
+}
+
+// checkDecl calls check for each exported identifier of decl. A declaration in a group, e.g. a
+// const block, is documented by the group's doc comment too.
+func checkDecl(decl ast.Decl, check func(name string, pos token.Pos, documented bool)) {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if !decl.Name.IsExported() {
+			return
+		}
+		name := decl.Name.Name
+		if decl.Recv != nil {
+			recv := receiverType(decl.Recv.List[0].Type)
+			// The methods of unexported types aren't part of the documented API.
+			if !ast.IsExported(recv) {
+				return
+			}
+			name = recv + "." + name
+		}
+		check(name, decl.Name.Pos(), decl.Doc != nil)
+	case *ast.GenDecl:
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				if spec.Name.IsExported() {
+					check(spec.Name.Name, spec.Name.Pos(), decl.Doc != nil || spec.Doc != nil)
+				}
+			case *ast.ValueSpec:
+				for _, ident := range spec.Names {
+					if ident.IsExported() {
+						check(ident.Name, ident.Pos(), decl.Doc != nil || spec.Doc != nil)
+					}
+				}
+			}
+		}
+	}
+}
+
+// receiverType returns the name of the type of a method receiver, e.g. "List" for *List[T].
+func receiverType(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(expr.X)
+	case *ast.IndexExpr:
+		return receiverType(expr.X)
+	case *ast.IndexListExpr:
+		return receiverType(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestGoDocCheckActivity(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.GoDocCheck)
+
+	val, err := env.ExecuteActivity(pa.GoDocCheck, GoDocCheckParams{Metadata: PipelineActivityMetadata{Workdir: filepath.Join("testdata", "doccheck")}})
+	assert.NoError(t, err)
+
+	var result GoDocCheckResult
+	assert.NoError(t, val.Get(&result))
+	// The package, Store, New, Store.Get, Store.Set, ErrNotFound, ErrClosed and DefaultSize; tests,
+	// generated files, commands and internal packages are skipped.
+	assert.Equal(t, 8, result.Exported)
+	assert.Equal(t, []UndocumentedSymbol{
+		{Name: "New", File: "store/store.go", Line: 9},
+		{Name: "Store.Set", File: "store/store.go", Line: 18},
+		{Name: "DefaultSize", File: "store/store.go", Line: 28},
+	}, result.Undocumented)
+	assert.Equal(t, 62.5, result.Coverage)
+}
+
+func TestDocCheck(t *testing.T) {
+	undocumented := []UndocumentedSymbol{{Name: "New", File: "store.go", Line: 9}}
+	for _, tt := range []struct {
+		name        string
+		minCoverage float64
+		failed      bool
+	}{
+		{"Everything must be documented by default", 0, true},
+		{"Coverage above the threshold passes", 75, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestWorkflowEnvironment()
+			env.OnActivity(pa.GoDocCheck, mock.Anything, mock.Anything).Return(&GoDocCheckResult{Exported: 5, Undocumented: undocumented, Coverage: 80}, nil)
+			mockAllActivitiesSuccess(env)
+
+			env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, DocCheck: true, DocMinCoverage: tt.minCoverage})
+			assert.True(t, env.IsWorkflowCompleted())
+
+			var result PipelineResult
+			assert.NoError(t, env.GetWorkflowResult(&result))
+			if tt.failed {
+				assert.Equal(t, []PipelineFailure{{Activity: "GoDocCheck", Kind: FailureIssues, Details: []any{
+					map[string]any{"name": "New", "file": "store.go", "line": float64(9)},
+				}}}, result.Failures)
+			} else {
+				assert.Empty(t, result.Failures)
+			}
+		})
+	}
+
+	params := PipelineParams{GitURL: gitUrl, DocMinCoverage: 101}
+	assert.ErrorContains(t, params.Validate(), "DocMinCoverage must be a percentage")
+}
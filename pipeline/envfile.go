@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// DefaultEnvFile is the env file read from the root of the cloned repository when
+// PipelineParams.EnvFile is not set.
+const DefaultEnvFile = ".env"
+
+// ReadEnvFile params and results
+type ReadEnvFileParams struct {
+	Metadata PipelineActivityMetadata
+	// Path is relative to the workdir. When empty, DefaultEnvFile is read if it exists.
+	Path string
+}
+
+type ReadEnvFileResult struct {
+	// Found is false when no env file was configured and the repository has no DefaultEnvFile.
+	Found bool
+	// Path is the path of the env file on the worker, for PipelineActivityMetadata.EnvFile.
+	Path string
+	// Keys are the names of the variables it sets, sorted. Their values, which can be secrets,
+	// stay on the worker rather than in the workflow history.
+	Keys []string
+}
+
+// ReadEnvFile checks the .env-style file of the workdir. A configured file must exist.
+func (pa *PipelineActivity) ReadEnvFile(ctx context.Context, params ReadEnvFileParams) (*ReadEnvFileResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &ReadEnvFileResult{Keys: []string{}}
+
+	name := params.Path
+	if name == "" {
+		name = DefaultEnvFile
+	}
+	path := filepath.Join(params.Metadata.Workdir, name)
+	activityLogger(ctx).Info("Reading env file", "path", path)
+
+	env, err := readEnvFile(path)
+	if errors.Is(err, fs.ErrNotExist) && params.Path == "" {
+		logger.Info("No env file found")
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading env file %s: %w", name, err)
+	}
+	for key := range env {
+		result.Keys = append(result.Keys, key)
+	}
+	sort.Strings(result.Keys)
+	result.Found = true
+	result.Path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Env file read", "keys", result.Keys)
+	return result, nil
+}
+
+// readEnvFile reads and parses the env file at path.
+func readEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseEnvFile(f)
+}
+
+var envKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseEnvFile parses KEY=VALUE lines. Blank lines, # comments, and an `export ` prefix are
+// ignored. Double-quoted values support Go escapes such as \n, single-quoted values are taken
+// literally, and unquoted values end at a " #" comment.
+func parseEnvFile(r io.Reader) (map[string]string, error) {
+	env := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !envKey.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			end := closingQuote(value)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", n)
+			}
+			unquoted, err := strconv.Unquote(value[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			value = unquoted
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", n)
+			}
+			value = value[1 : end+1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		env[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// closingQuote returns the index of the unescaped double quote closing value, or -1.
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	t.Run("Parses values", func(t *testing.T) {
+		env, err := parseEnvFile(strings.NewReader(`KEY="a \"quoted\" value" # comment
+EMPTY=
+`))
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"KEY": `a "quoted" value`, "EMPTY": ""}, env)
+	})
+
+	t.Run("Rejects malformed lines", func(t *testing.T) {
+		_, err := parseEnvFile(strings.NewReader("# comment\nNOT A VARIABLE\n"))
+		assert.EqualError(t, err, "line 2: expected KEY=VALUE")
+
+		_, err = parseEnvFile(strings.NewReader(`KEY="unterminated`))
+		assert.EqualError(t, err, "line 1: unterminated quoted value")
+	})
+}
+
+func TestReadEnvFileActivity(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+
+	t.Run("Discovers .env in the repository", func(t *testing.T) {
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.ReadEnvFile)
+
+		val, err := env.ExecuteActivity(pa.ReadEnvFile, ReadEnvFileParams{
+			Metadata: PipelineActivityMetadata{Workdir: filepath.Join("testdata", "envfile")},
+		})
+		assert.NoError(t, err)
+
+		var result ReadEnvFileResult
+		assert.NoError(t, val.Get(&result))
+		assert.True(t, result.Found)
+		path, err := filepath.Abs(filepath.Join("testdata", "envfile", ".env"))
+		assert.NoError(t, err)
+		assert.Equal(t, path, result.Path)
+		assert.Equal(t, []string{"API_TOKEN", "CGO_ENABLED", "GOFLAGS", "GOPRIVATE", "GREETING", "LITERAL"}, result.Keys)
+	})
+
+	t.Run("A missing configured file is an error", func(t *testing.T) {
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.ReadEnvFile)
+
+		_, err := env.ExecuteActivity(pa.ReadEnvFile, ReadEnvFileParams{
+			Metadata: PipelineActivityMetadata{Workdir: filepath.Join("testdata", "envfile")},
+			Path:     "ci.env",
+		})
+		assert.ErrorContains(t, err, "reading env file ci.env")
+	})
+}
+
+func TestEnvFile(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.OnActivity(pa.GitClone, mock.Anything, mock.Anything).Return(&GitCloneResult{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}}, nil)
+	env.OnActivity(pa.ReadRepoConfig, mock.Anything, mock.Anything).Return(&ReadRepoConfigResult{}, nil)
+	env.OnActivity(pa.ReadEnvFile, mock.Anything, ReadEnvFileParams{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}, Path: "ci.env"}).
+		Return(&ReadEnvFileResult{Found: true, Path: "/tmp/test/ci.env", Keys: []string{"CGO_ENABLED", "GOPRIVATE"}}, nil)
+	env.OnActivity(pa.MeasureWorkdir, mock.Anything, mock.Anything).Return(&MeasureWorkdirResult{}, nil)
+	env.OnActivity(pa.DeleteWorkdir, mock.Anything, mock.Anything).Return(nil)
+	mockAllActivitiesSuccess(env)
+
+	var built []GoBuildParams
+	env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		if info.ActivityType.Name == "GoBuild" {
+			var p GoBuildParams
+			assert.NoError(t, args.Get(&p))
+			built = append(built, p)
+		}
+	})
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, EnvFile: "ci.env", NoCache: true, Env: map[string]string{"CGO_ENABLED": "0"}})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	// The values of the env file are read by the activities, not passed to them.
+	if assert.Len(t, built, 1) {
+		assert.Equal(t, map[string]string{"CGO_ENABLED": "0"}, built[0].Metadata.Env)
+		assert.Equal(t, "/tmp/test/ci.env", built[0].Metadata.EnvFile)
+	}
+}
+
+func TestGoEnvWithEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	assert.NoError(t, os.WriteFile(path, []byte("CGO_ENABLED=1\nGOPRIVATE=example.com/private\n"), 0o600))
+
+	env := goEnv(PipelineActivityMetadata{EnvFile: path, Env: map[string]string{"CGO_ENABLED": "0"}})
+	cmd := exec.Command("sh", "-c", `echo "$CGO_ENABLED $GOPRIVATE"`)
+	cmd.Env = env
+	out, err := cmd.Output()
+	assert.NoError(t, err)
+	// Env takes precedence over the env file.
+	assert.Equal(t, "0 example.com/private\n", string(out))
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxGateBodySize bounds how much of a deploy gate response is read.
+const maxGateBodySize = 1 << 20
+
+// DeployGate is an external go/no-go check, e.g. a deploy freeze calendar or a dependency's
+// readiness endpoint, that must pass before deploying.
+type DeployGate struct {
+	URL string `json:"url" yaml:"url"`
+	// ExpectedStatus is the status code of an open gate. Defaults to 200.
+	ExpectedStatus int `json:"expected_status" yaml:"expected_status"`
+	// ExpectedBody, when set, must appear in the response body of an open gate.
+	ExpectedBody string `json:"expected_body" yaml:"expected_body"`
+}
+
+// CheckDeployGate params and results
+type CheckDeployGateParams struct {
+	Gate DeployGate
+}
+
+type CheckDeployGateResult struct {
+	Open bool
+	// Reason explains why the gate is closed.
+	Reason string
+}
+
+// CheckDeployGate asks the gate whether deploying is allowed. A closed gate is a result, not an
+// error; only failing to reach the gate is retried.
+func (pa *PipelineActivity) CheckDeployGate(ctx context.Context, params CheckDeployGateParams) (*CheckDeployGateResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params.Gate.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating deploy gate request: %w", err)
+	}
+
+	activityLogger(ctx).Info("Checking deploy gate", "url", params.Gate.URL)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("checking deploy gate: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxGateBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("reading deploy gate response: %w", err)
+	}
+
+	expectedStatus := params.Gate.ExpectedStatus
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+	switch {
+	case resp.StatusCode != expectedStatus:
+		return &CheckDeployGateResult{Reason: fmt.Sprintf("deploy gate returned status %d, want %d: %s", resp.StatusCode, expectedStatus, strings.TrimSpace(string(body)))}, nil
+	case !strings.Contains(string(body), params.Gate.ExpectedBody):
+		return &CheckDeployGateResult{Reason: fmt.Sprintf("deploy gate response doesn't contain %q: %s", params.Gate.ExpectedBody, strings.TrimSpace(string(body)))}, nil
+	}
+	return &CheckDeployGateResult{Open: true}, nil
+}
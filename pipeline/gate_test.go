@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestCheckDeployGateActivity(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/frozen":
+			http.Error(w, "deploy freeze until Monday", http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte(`{"deploys":"allowed"}`))
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		gate DeployGate
+		want CheckDeployGateResult
+	}{
+		{
+			name: "Open",
+			gate: DeployGate{URL: srv.URL + "/status", ExpectedBody: `"allowed"`},
+			want: CheckDeployGateResult{Open: true},
+		},
+		{
+			name: "Unexpected status",
+			gate: DeployGate{URL: srv.URL + "/frozen"},
+			want: CheckDeployGateResult{Reason: "deploy gate returned status 503, want 200: deploy freeze until Monday"},
+		},
+		{
+			name: "Unexpected body",
+			gate: DeployGate{URL: srv.URL + "/status", ExpectedBody: `"ready"`},
+			want: CheckDeployGateResult{Reason: `deploy gate response doesn't contain "\"ready\"": {"deploys":"allowed"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestActivityEnvironment()
+			env.RegisterActivity(pa.CheckDeployGate)
+
+			val, err := env.ExecuteActivity(pa.CheckDeployGate, CheckDeployGateParams{Gate: tt.gate})
+			assert.NoError(t, err)
+
+			var result CheckDeployGateResult
+			assert.NoError(t, val.Get(&result))
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
+
+func TestDeployGate(t *testing.T) {
+	gate := &DeployGate{URL: "https://freeze.example.com/status"}
+
+	t.Run("Deploys when the gate is open", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.CheckDeployGate, mock.Anything, mock.Anything).Return(&CheckDeployGateResult{Open: true}, nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, DeployGate: gate})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Empty(t, result.DeploySkipped)
+		env.AssertCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Skips the deploy when the gate is closed", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.CheckDeployGate, mock.Anything, mock.Anything).Return(&CheckDeployGateResult{Reason: "deploy freeze"}, nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, DeployGate: gate})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Equal(t, "deploy freeze", result.DeploySkipped)
+		assert.Empty(t, result.Failures)
+		env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// GitHubAPIURL is the GitHub REST API used when GitHubConfig.APIURL is empty.
+	GitHubAPIURL = "https://api.github.com"
+	// DefaultGitHubStatusContext labels the commit status when GitHubConfig.Context is empty.
+	DefaultGitHubStatusContext = "temporal-workflow/pipeline"
+)
+
+// GitHub commit status states.
+const (
+	GitHubStatePending = "pending"
+	GitHubStateSuccess = "success"
+	GitHubStateFailure = "failure"
+	GitHubStateError   = "error"
+)
+
+// GitHubConfig reports the pipeline as a commit status of a GitHub repository. The token is not
+// part of it; the worker reads it from GITHUB_TOKEN.
+type GitHubConfig struct {
+	Owner string `json:"owner" yaml:"owner"`
+	Repo  string `json:"repo" yaml:"repo"`
+	// Context labels the status on the commit. Defaults to DefaultGitHubStatusContext.
+	Context string `json:"context" yaml:"context"`
+	// APIURL overrides GitHubAPIURL, e.g. for GitHub Enterprise.
+	APIURL string `json:"api_url" yaml:"api_url"`
+}
+
+func (gc *GitHubConfig) validate() error {
+	if gc == nil {
+		return nil
+	}
+	if gc.Owner == "" || gc.Repo == "" {
+		return fmt.Errorf("GitHub owner and repo are required")
+	}
+	return nil
+}
+
+// GitHubStatus params
+type GitHubStatusParams struct {
+	Config      GitHubConfig
+	SHA         string
+	State       string
+	Description string
+}
+
+// GitHubStatus sets the commit status of SHA through the GitHub statuses API.
+func (pa *PipelineActivity) GitHubStatus(ctx context.Context, params GitHubStatusParams) error {
+	if pa.GitHubToken == "" {
+		return temporal.NewNonRetryableApplicationError("no GitHub token configured", "NoGitHubToken", nil)
+	}
+	apiURL := params.Config.APIURL
+	if apiURL == "" {
+		apiURL = GitHubAPIURL
+	}
+	statusContext := params.Config.Context
+	if statusContext == "" {
+		statusContext = DefaultGitHubStatusContext
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"state":       params.State,
+		"description": params.Description,
+		"context":     statusContext,
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling commit status: %w", err)
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/statuses/%s", strings.TrimSuffix(apiURL, "/"), params.Config.Owner, params.Config.Repo, params.SHA)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating commit status request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+pa.GitHubToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	activityLogger(ctx).Info("Setting GitHub commit status", "repo", params.Config.Owner+"/"+params.Config.Repo, "sha", params.SHA, "state", params.State)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("setting commit status: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("setting commit status: unexpected status %s", resp.Status)
+	case resp.StatusCode >= 300:
+		// A bad token, an unknown repository or commit won't succeed on retry.
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("setting commit status: unexpected status %s", resp.Status), "GitHubStatusRejected", nil)
+	}
+	return nil
+}
+
+// setGitHubStatus reports state for sha when PipelineParams.GitHub is set. Errors are logged, not
+// returned: the status is informational and shouldn't fail the pipeline.
+func setGitHubStatus(ctx workflow.Context, params PipelineParams, sha, state, description string) {
+	if params.GitHub == nil || sha == "" {
+		return
+	}
+	err := workflow.ExecuteActivity(ctx, pa.GitHubStatus, GitHubStatusParams{
+		Config:      *params.GitHub,
+		SHA:         sha,
+		State:       state,
+		Description: description,
+	}).Get(ctx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("GitHubStatus failed", "state", state, "error", err)
+	}
+}
+
+// gitHubFinalStatus returns the commit status state and description for a finished pipeline.
+func gitHubFinalStatus(result *PipelineResult) (string, string) {
+	if len(result.Failures) > 0 {
+		return GitHubStateFailure, fmt.Sprintf("Pipeline failed with %d failure(s)", len(result.Failures))
+	}
+	return GitHubStateSuccess, "Pipeline succeeded"
+}
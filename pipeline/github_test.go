@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestGitHubStatusActivity(t *testing.T) {
+	var path, auth string
+	var status map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/afanwang/missing/statuses/abc123" {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&status))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	ghActivity := &PipelineActivity{GitHubToken: "ghp_secret"}
+	env.RegisterActivity(ghActivity)
+
+	_, err := env.ExecuteActivity(ghActivity.GitHubStatus, GitHubStatusParams{
+		Config:      GitHubConfig{Owner: "afanwang", Repo: "go-sample", APIURL: srv.URL},
+		SHA:         "abc123",
+		State:       GitHubStatePending,
+		Description: "Pipeline running",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "/repos/afanwang/go-sample/statuses/abc123", path)
+	assert.Equal(t, "Bearer ghp_secret", auth)
+	assert.Equal(t, map[string]string{
+		"state":       "pending",
+		"description": "Pipeline running",
+		"context":     DefaultGitHubStatusContext,
+	}, status)
+
+	t.Run("Rejected statuses are not retried", func(t *testing.T) {
+		_, err := env.ExecuteActivity(ghActivity.GitHubStatus, GitHubStatusParams{
+			Config: GitHubConfig{Owner: "afanwang", Repo: "missing", APIURL: srv.URL},
+			SHA:    "abc123",
+			State:  GitHubStateSuccess,
+		})
+		var appErr *temporal.ApplicationError
+		if assert.True(t, errors.As(err, &appErr)) {
+			assert.True(t, appErr.NonRetryable())
+		}
+	})
+}
+
+func TestGitHubStatus(t *testing.T) {
+	newEnv := func() *testsuite.TestWorkflowEnvironment {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		env.OnActivity(pa.GitClone, mock.Anything, mock.Anything).Return(&GitCloneResult{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}, CommitSHA: "abc123"}, nil)
+		env.OnActivity(pa.ReadRepoConfig, mock.Anything, mock.Anything).Return(&ReadRepoConfigResult{}, nil)
+		env.OnActivity(pa.ReadEnvFile, mock.Anything, mock.Anything).Return(&ReadEnvFileResult{}, nil)
+		env.OnActivity(pa.GoModDownload, mock.Anything, mock.Anything).Return(&GoModDownloadResult{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}}, nil)
+		env.OnActivity(pa.MeasureWorkdir, mock.Anything, mock.Anything).Return(&MeasureWorkdirResult{}, nil)
+		env.OnActivity(pa.DeleteWorkdir, mock.Anything, mock.Anything).Return(nil)
+		return env
+	}
+	statesOf := func(env *testsuite.TestWorkflowEnvironment) *[]string {
+		var states []string
+		env.OnActivity(pa.GitHubStatus, mock.Anything, mock.Anything).Return(func(_ context.Context, p GitHubStatusParams) error {
+			assert.Equal(t, "abc123", p.SHA)
+			states = append(states, p.State)
+			return nil
+		})
+		return &states
+	}
+	github := &GitHubConfig{Owner: "afanwang", Repo: "go-sample"}
+
+	t.Run("Success", func(t *testing.T) {
+		env := newEnv()
+		states := statesOf(env)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, GitHub: github})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		assert.Equal(t, []string{GitHubStatePending, GitHubStateSuccess}, *states)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		env := newEnv()
+		states := statesOf(env)
+		mockActivitiesWithFailures(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, GitHub: github})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		assert.Equal(t, []string{GitHubStatePending, GitHubStateFailure}, *states)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		env := newEnv()
+		states := statesOf(env)
+		env.OnActivity(pa.GoDeploy, mock.Anything, mock.Anything).Return(nil, temporal.NewNonRetryableApplicationError("boom", "Boom", nil))
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, GitHub: github})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.Error(t, env.GetWorkflowError())
+		assert.Equal(t, []string{GitHubStatePending, GitHubStateError}, *states)
+	})
+
+	t.Run("Not configured", func(t *testing.T) {
+		env := newEnv()
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertNotCalled(t, "GitHubStatus", mock.Anything, mock.Anything)
+	})
+}
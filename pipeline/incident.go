@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// PagerDutyEventsURL is the PagerDuty Events API v2 endpoint used when IncidentConfig.EventsURL is empty.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// IncidentConfig opens an incident through the PagerDuty Events API v2 (which Opsgenie also
+// accepts) when a deploy fails.
+type IncidentConfig struct {
+	RoutingKey string `json:"routing_key" yaml:"routing_key"`
+	// Environment limits incidents to failures deploying to this DeployEnvironments entry,
+	// e.g. "prod". When empty, any failed deploy opens an incident.
+	Environment string `json:"environment" yaml:"environment"`
+	// EventsURL overrides PagerDutyEventsURL.
+	EventsURL string `json:"events_url" yaml:"events_url"`
+}
+
+// TriggerIncident params
+type TriggerIncidentParams struct {
+	Config IncidentConfig
+	// DedupKey groups repeated failures of the same pipeline into one incident.
+	DedupKey string
+	Summary  string
+	Source   string
+	Result   PipelineResult
+}
+
+type incidentEvent struct {
+	RoutingKey  string               `json:"routing_key"`
+	EventAction string               `json:"event_action"`
+	DedupKey    string               `json:"dedup_key"`
+	Payload     incidentEventPayload `json:"payload"`
+}
+
+type incidentEventPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	CustomDetails PipelineResult `json:"custom_details"`
+}
+
+// TriggerIncident sends a trigger event to the Events API v2.
+func (pa *PipelineActivity) TriggerIncident(ctx context.Context, params TriggerIncidentParams) error {
+	url := params.Config.EventsURL
+	if url == "" {
+		url = PagerDutyEventsURL
+	}
+
+	body, err := json.Marshal(incidentEvent{
+		RoutingKey:  params.Config.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    params.DedupKey,
+		Payload: incidentEventPayload{
+			Summary:       params.Summary,
+			Source:        params.Source,
+			Severity:      "critical",
+			CustomDetails: params.Result,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling incident event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating incident request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	activityLogger(ctx).Info("Triggering incident", "dedupKey", params.DedupKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("triggering incident: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("triggering incident: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// deployFailed reports whether result has a deploy failure that should open an incident.
+func deployFailed(config IncidentConfig, result *PipelineResult) bool {
+	if config.Environment != "" {
+		for _, deployment := range result.Deployments {
+			if deployment.Environment == config.Environment && deployment.Status == DeploymentFailed {
+				return true
+			}
+		}
+		return false
+	}
+	for _, failure := range result.Failures {
+		if failure.Activity == "Deploy" {
+			return true
+		}
+	}
+	return false
+}
+
+// triggerIncident opens an incident for a failed deploy. Errors are logged, not returned: the
+// pipeline result already records the deploy failure.
+func triggerIncident(ctx workflow.Context, params PipelineParams, result *PipelineResult) {
+	workflowID := workflow.GetInfo(ctx).WorkflowExecution.ID
+	err := workflow.ExecuteActivity(ctx, pa.TriggerIncident, TriggerIncidentParams{
+		Config:   *params.Incident,
+		DedupKey: "deploy-failed-" + workflowID,
+		Summary:  fmt.Sprintf("Deploy of %s failed", params.RepoSpecs()[0].URL),
+		Source:   workflowID,
+		Result:   *result,
+	}).Get(ctx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("TriggerIncident failed", "error", err)
+	}
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestTriggerIncidentActivity(t *testing.T) {
+	var event map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.TriggerIncident)
+
+	_, err := env.ExecuteActivity(pa.TriggerIncident, TriggerIncidentParams{
+		Config:   IncidentConfig{RoutingKey: "R0UT1NG", EventsURL: srv.URL},
+		DedupKey: "deploy-failed-PipelineWorkflow-app",
+		Summary:  "Deploy of app failed",
+		Source:   "PipelineWorkflow-app",
+		Result: PipelineResult{
+			Failures:  []PipelineFailure{{Activity: "Deploy", Kind: FailureIssues, Details: "exit status 1"}},
+			StartedAt: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC),
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "R0UT1NG", event["routing_key"])
+	assert.Equal(t, "trigger", event["event_action"])
+	assert.Equal(t, "deploy-failed-PipelineWorkflow-app", event["dedup_key"])
+	assert.Equal(t, map[string]any{
+		"summary":  "Deploy of app failed",
+		"source":   "PipelineWorkflow-app",
+		"severity": "critical",
+		"custom_details": map[string]any{
+			"failures":   []any{map[string]any{"activity": "Deploy", "kind": "issues", "details": "exit status 1"}},
+			"started_at": "2024-07-01T12:00:00Z",
+		},
+	}, event["payload"])
+}
+
+func TestIncidentOnFailedDeploy(t *testing.T) {
+	environments := []DeployEnvironment{
+		{Name: "staging", Command: []string{"deploy", "staging"}},
+		{Name: "prod", Command: []string{"deploy", "prod"}},
+	}
+	deploysTo := func(name string) any {
+		return mock.MatchedBy(func(p GoDeployParams) bool { return p.DeployCommand[1] == name })
+	}
+
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoDeploy, mock.Anything, deploysTo("prod")).Return(&GoDeployResult{ErrorMsg: "exit status 1"}, nil)
+	mockAllActivitiesSuccess(env)
+	var incident TriggerIncidentParams
+	env.OnActivity(pa.TriggerIncident, mock.Anything, mock.Anything).Return(func(_ context.Context, p TriggerIncidentParams) error {
+		incident = p
+		return nil
+	})
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{
+		GitURL:             gitUrl,
+		DeployEnvironments: environments,
+		Incident:           &IncidentConfig{RoutingKey: "R0UT1NG", Environment: "prod"},
+	})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	assert.Equal(t, "deploy-failed-default-test-workflow-id", incident.DedupKey)
+	assert.Equal(t, "R0UT1NG", incident.Config.RoutingKey)
+	if assert.Len(t, incident.Result.Deployments, 2) {
+		assert.Equal(t, DeploymentFailed, incident.Result.Deployments[1].Status)
+	}
+}
@@ -0,0 +1,295 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/workflow"
+)
+
+// DefaultK8sTimeout is the default K8sConfig.Timeout.
+const DefaultK8sTimeout = 5 * time.Minute
+
+// K8sConfig deploys by applying the Kubernetes manifests of the repository with kubectl and
+// waiting for their rollout, in place of the deploy command.
+type K8sConfig struct {
+	// ManifestsDir is the directory of the manifests, relative to the repository.
+	ManifestsDir string `json:"manifests_dir" yaml:"manifests_dir"`
+	// Kubeconfig is the path of a kubeconfig on the worker. Empty uses kubectl's default, e.g.
+	// the in-cluster config of a worker running in Kubernetes.
+	Kubeconfig string `json:"kubeconfig" yaml:"kubeconfig"`
+	// Context and Namespace select the kubeconfig context and the namespace to deploy to.
+	Context   string `json:"context" yaml:"context"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	// Timeout bounds applying the manifests and waiting for every rollout. Defaults to
+	// DefaultK8sTimeout.
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+func (c *K8sConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.ManifestsDir == "" {
+		return fmt.Errorf("K8s manifests_dir is required")
+	}
+	if !filepath.IsLocal(c.ManifestsDir) {
+		return fmt.Errorf("K8s manifests_dir %q must be relative to the repository", c.ManifestsDir)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("K8s timeout must not be negative")
+	}
+	return nil
+}
+
+func (c K8sConfig) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultK8sTimeout
+}
+
+// K8sDeploy params and results
+type K8sDeployParams struct {
+	Metadata PipelineActivityMetadata
+	Config   K8sConfig
+}
+
+type K8sDeployResult struct {
+	Success bool
+	// ErrorMsg describes why the deploy failed, like GoDeployResult.ErrorMsg.
+	ErrorMsg string
+	// Applied lists the resources kubectl applied, e.g. deployment.apps/api.
+	Applied []string
+	// Rollouts holds the rollout of each applied workload, in the order they were waited for.
+	Rollouts []K8sRollout
+	// PriorRevisions has the revision of each workload before the apply, for Rollback. Workloads
+	// the deploy creates have none.
+	PriorRevisions map[string]string
+}
+
+// K8sRollout is the outcome of the rollout of one workload.
+type K8sRollout struct {
+	Resource string
+	Success  bool
+	// Status is the last line kubectl reported, e.g. `deployment "api" successfully rolled out`.
+	Status string
+}
+
+// rolloutKinds are the kinds of resources `kubectl rollout status` can wait for.
+var rolloutKinds = map[string]bool{"deployment": true, "daemonset": true, "statefulset": true}
+
+// K8sDeploy applies the manifests in params.Config.ManifestsDir with `kubectl apply` and waits
+// for the rollout of the deployments, daemonsets and statefulsets it applied. A failed apply or
+// rollout is a result, like a failed deploy command.
+func (pa *PipelineActivity) K8sDeploy(ctx context.Context, params K8sDeployParams) (*K8sDeployResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &K8sDeployResult{Applied: []string{}, Rollouts: []K8sRollout{}, PriorRevisions: map[string]string{}}
+
+	config := params.Config
+	deadline := time.Now().Add(config.timeout())
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	kubectl := func(args ...string) (string, string, error) {
+		return runKubectl(ctx, params.Metadata.Workdir, config, args...)
+	}
+
+	// A dry run names the workloads, so their current revision can be recorded before the apply.
+	// Should it fail, the apply fails too and reports why.
+	if stdout, _, err := kubectl("apply", "-f", config.ManifestsDir, "--recursive", "--dry-run=client", "-o", "name"); err == nil {
+		for _, resource := range strings.Fields(stdout) {
+			if !isWorkload(resource) {
+				continue
+			}
+			if revision := currentRevision(kubectl, resource); revision != "" {
+				result.PriorRevisions[resource] = revision
+			}
+		}
+	}
+
+	stdout, stderr, err := kubectl("apply", "-f", config.ManifestsDir, "--recursive", "-o", "name")
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("running kubectl apply: %w", err)
+		}
+		result.ErrorMsg = fmt.Sprintf("kubectl apply exited with status %d: %s", exitErr.ExitCode(), strings.TrimSpace(stderr))
+		return result, nil
+	}
+	result.Applied = append(result.Applied, strings.Fields(stdout)...)
+	logger.Info("Applied manifests", "resources", result.Applied)
+
+	for _, resource := range result.Applied {
+		if !isWorkload(resource) {
+			continue
+		}
+
+		remaining := time.Until(deadline).Round(time.Second)
+		stdout, stderr, err := kubectl("rollout", "status", resource, "--timeout", remaining.String())
+		rollout := K8sRollout{Resource: resource, Success: err == nil, Status: lastLine(stdout)}
+		if err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return nil, fmt.Errorf("running kubectl rollout status: %w", err)
+			}
+			rollout.Status = lastLine(stderr)
+			result.Rollouts = append(result.Rollouts, rollout)
+			result.ErrorMsg = fmt.Sprintf("rollout of %s failed: %s", resource, rollout.Status)
+			return result, nil
+		}
+		result.Rollouts = append(result.Rollouts, rollout)
+	}
+
+	logger.Info("Kubernetes deploy completed successfully", "rollouts", len(result.Rollouts))
+	result.Success = true
+	return result, nil
+}
+
+// Rollback params and results
+type RollbackParams struct {
+	Metadata PipelineActivityMetadata
+	Config   K8sConfig
+	// Revisions maps each workload to the revision it is rolled back to, as recorded in
+	// K8sDeployResult.PriorRevisions.
+	Revisions map[string]string
+}
+
+type RollbackResult struct {
+	Success  bool
+	ErrorMsg string
+	// RolledBack lists the workloads back at their prior revision.
+	RolledBack []string
+}
+
+// Rollback restores the workloads of a failed K8sDeploy to their prior revision with `kubectl
+// rollout undo`, and waits for them to roll out again.
+func (pa *PipelineActivity) Rollback(ctx context.Context, params RollbackParams) (*RollbackResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &RollbackResult{RolledBack: []string{}}
+
+	deadline := time.Now().Add(params.Config.timeout())
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	resources := make([]string, 0, len(params.Revisions))
+	for resource := range params.Revisions {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		revision := params.Revisions[resource]
+		logger.Info("Rolling back", "resource", resource, "revision", revision)
+		for _, args := range [][]string{
+			{"rollout", "undo", resource, "--to-revision", revision},
+			{"rollout", "status", resource, "--timeout", time.Until(deadline).Round(time.Second).String()},
+		} {
+			_, stderr, err := runKubectl(ctx, params.Metadata.Workdir, params.Config, args...)
+			if err != nil {
+				var exitErr *exec.ExitError
+				if !errors.As(err, &exitErr) {
+					return nil, fmt.Errorf("running kubectl %s: %w", strings.Join(args[:2], " "), err)
+				}
+				result.ErrorMsg = fmt.Sprintf("rollback of %s to revision %s failed: %s", resource, revision, lastLine(stderr))
+				return result, nil
+			}
+		}
+		result.RolledBack = append(result.RolledBack, resource)
+	}
+
+	logger.Info("Rollback completed successfully", "resources", result.RolledBack)
+	result.Success = true
+	return result, nil
+}
+
+// runKubectl runs kubectl against the cluster, context and namespace of config.
+func runKubectl(ctx context.Context, dir string, config K8sConfig, args ...string) (stdout, stderr string, err error) {
+	var global []string
+	if config.Kubeconfig != "" {
+		global = append(global, "--kubeconfig", config.Kubeconfig)
+	}
+	if config.Context != "" {
+		global = append(global, "--context", config.Context)
+	}
+	if config.Namespace != "" {
+		global = append(global, "--namespace", config.Namespace)
+	}
+	return runCommand(ctx, dir, commandOptions{}, "kubectl", append(global, args...)...)
+}
+
+// isWorkload reports whether resource, named kind.group/name by kubectl, e.g.
+// deployment.apps/api, is of a kind `kubectl rollout` manages.
+func isWorkload(resource string) bool {
+	kind, _, _ := strings.Cut(path.Dir(resource), ".")
+	return rolloutKinds[kind]
+}
+
+// currentRevision returns the latest revision in the rollout history of resource, or "" when
+// it has none, e.g. because it doesn't exist yet.
+func currentRevision(kubectl func(args ...string) (string, string, error), resource string) string {
+	stdout, _, err := kubectl("rollout", "history", resource)
+	if err != nil {
+		return ""
+	}
+	// The history is a table of REVISION and CHANGE-CAUSE, oldest first.
+	fields := strings.Fields(lastLine(stdout))
+	if len(fields) == 0 {
+		return ""
+	}
+	if _, err := strconv.Atoi(fields[0]); err != nil {
+		return ""
+	}
+	return fields[0]
+}
+
+// lastLine returns the last non-empty line of output.
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return lines[len(lines)-1]
+}
+
+// deployK8s runs K8sDeploy, with a timeout covering the rollouts.
+func deployK8s(ctx workflow.Context, params PipelineParams, metadata PipelineActivityMetadata) (*K8sDeployResult, error) {
+	ctx = workflow.WithStartToCloseTimeout(ctx, params.K8s.timeout()+time.Minute)
+	rK8s := &K8sDeployResult{}
+	err := workflow.ExecuteActivity(ctx, pa.K8sDeploy, K8sDeployParams{Metadata: metadata, Config: *params.K8s}).Get(ctx, rK8s)
+	return rK8s, err
+}
+
+// rollbackK8s rolls the workloads of a failed K8sDeploy back to their prior revisions, when it
+// recorded any.
+func rollbackK8s(ctx workflow.Context, params PipelineParams, metadata PipelineActivityMetadata, result *PipelineResult, progress *PipelineProgress) {
+	revisions := result.K8sDeploy.PriorRevisions
+	if len(revisions) == 0 {
+		return
+	}
+
+	progress.set("Rollback", StepRunning)
+	ctx = workflow.WithStartToCloseTimeout(ctx, params.K8s.timeout()+time.Minute)
+	rRollback := &RollbackResult{}
+	err := workflow.ExecuteActivity(ctx, pa.Rollback, RollbackParams{
+		Metadata:  metadata,
+		Config:    *params.K8s,
+		Revisions: revisions,
+	}).Get(ctx, rRollback)
+	switch {
+	case err != nil:
+		result.Failures = append(result.Failures, errorFailure("Rollback", err))
+		progress.set("Rollback", StepFailed)
+		return
+	case !rRollback.Success:
+		result.Failures = append(result.Failures, PipelineFailure{Activity: "Rollback", Kind: FailureIssues, Details: rRollback.ErrorMsg})
+		progress.set("Rollback", StepFailed)
+	default:
+		progress.set("Rollback", StepDone)
+	}
+	result.Rollback = rRollback
+}
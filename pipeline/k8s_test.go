@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestK8sDeployActivity(t *testing.T) {
+	// A kubectl that records its arguments, applies a service, an existing deployment at revision
+	// 2 and a new statefulset, and fails the statefulset's rollout when FAIL_ROLLOUT is set.
+	argsFile := filepath.Join(t.TempDir(), "args")
+	fakeTool(t, "kubectl", `echo "$@" >> `+argsFile+`
+case "$*" in
+*" apply "*) printf 'service/api\ndeployment.apps/api\nstatefulset.apps/db\n' ;;
+*"rollout history deployment.apps/api"*) printf 'deployment.apps/api\nREVISION  CHANGE-CAUSE\n1         <none>\n2         <none>\n\n' ;;
+*"rollout history"*) echo 'Error from server (NotFound): statefulsets.apps "db" not found' >&2; exit 1 ;;
+*"rollout status statefulset.apps/db"*)
+	if [ -n "$FAIL_ROLLOUT" ]; then echo 'error: timed out waiting for the condition' >&2; exit 1; fi
+	echo 'partitioned roll out complete: 1 new pods have been updated...' ;;
+*"rollout status"*) printf 'Waiting for deployment "api" rollout to finish\ndeployment "api" successfully rolled out\n' ;;
+esac
+`)
+
+	deploy := func(t *testing.T) *K8sDeployResult {
+		_ = os.Remove(argsFile)
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.K8sDeploy)
+
+		val, err := env.ExecuteActivity(pa.K8sDeploy, K8sDeployParams{
+			Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+			Config:   K8sConfig{ManifestsDir: "deploy/k8s", Context: "prod", Namespace: "shop"},
+		})
+		assert.NoError(t, err)
+		result := &K8sDeployResult{}
+		assert.NoError(t, val.Get(result))
+		return result
+	}
+
+	t.Run("Rolled out", func(t *testing.T) {
+		result := deploy(t)
+		assert.True(t, result.Success)
+		assert.Equal(t, []string{"service/api", "deployment.apps/api", "statefulset.apps/db"}, result.Applied)
+		assert.Equal(t, map[string]string{"deployment.apps/api": "2"}, result.PriorRevisions)
+		assert.Equal(t, []K8sRollout{
+			{Resource: "deployment.apps/api", Success: true, Status: `deployment "api" successfully rolled out`},
+			{Resource: "statefulset.apps/db", Success: true, Status: "partitioned roll out complete: 1 new pods have been updated..."},
+		}, result.Rollouts)
+
+		args, err := os.ReadFile(argsFile)
+		assert.NoError(t, err)
+		calls := strings.Split(strings.TrimSpace(string(args)), "\n")
+		if assert.Len(t, calls, 6) {
+			assert.Equal(t, "--context prod --namespace shop apply -f deploy/k8s --recursive --dry-run=client -o name", calls[0])
+			assert.Equal(t, "--context prod --namespace shop apply -f deploy/k8s --recursive -o name", calls[3])
+			assert.Regexp(t, `^--context prod --namespace shop rollout status deployment.apps/api --timeout \dm\d+s$`, calls[4])
+		}
+	})
+
+	t.Run("Failed rollout", func(t *testing.T) {
+		t.Setenv("FAIL_ROLLOUT", "1")
+		result := deploy(t)
+		assert.False(t, result.Success)
+		assert.Equal(t, "rollout of statefulset.apps/db failed: error: timed out waiting for the condition", result.ErrorMsg)
+		if assert.Len(t, result.Rollouts, 2) {
+			assert.False(t, result.Rollouts[1].Success)
+		}
+	})
+}
+
+func TestRollbackActivity(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args")
+	fakeTool(t, "kubectl", "echo \"$@\" >> "+argsFile+"\n")
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.Rollback)
+
+	val, err := env.ExecuteActivity(pa.Rollback, RollbackParams{
+		Metadata:  PipelineActivityMetadata{Workdir: t.TempDir()},
+		Config:    K8sConfig{ManifestsDir: "deploy/k8s", Namespace: "shop"},
+		Revisions: map[string]string{"deployment.apps/web": "7", "deployment.apps/api": "2"},
+	})
+	assert.NoError(t, err)
+	result := &RollbackResult{}
+	assert.NoError(t, val.Get(result))
+	assert.True(t, result.Success)
+	assert.Equal(t, []string{"deployment.apps/api", "deployment.apps/web"}, result.RolledBack)
+
+	args, err := os.ReadFile(argsFile)
+	assert.NoError(t, err)
+	calls := strings.Split(strings.TrimSpace(string(args)), "\n")
+	if assert.Len(t, calls, 4) {
+		assert.Equal(t, "--namespace shop rollout undo deployment.apps/api --to-revision 2", calls[0])
+		assert.Contains(t, calls[1], "--namespace shop rollout status deployment.apps/api --timeout")
+		assert.Equal(t, "--namespace shop rollout undo deployment.apps/web --to-revision 7", calls[2])
+	}
+}
+
+func TestAutoRollback(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.K8sDeploy, mock.Anything, mock.Anything).Return(&K8sDeployResult{
+		ErrorMsg:       "rollout of deployment.apps/api failed: progress deadline exceeded",
+		PriorRevisions: map[string]string{"deployment.apps/api": "2"},
+	}, nil)
+	var rollback RollbackParams
+	env.OnActivity(pa.Rollback, mock.Anything, mock.MatchedBy(func(p RollbackParams) bool {
+		rollback = p
+		return true
+	})).Return(&RollbackResult{Success: true, RolledBack: []string{"deployment.apps/api"}}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, K8s: &K8sConfig{ManifestsDir: "deploy/k8s"}, AutoRollback: true})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, map[string]string{"deployment.apps/api": "2"}, rollback.Revisions)
+	if assert.NotNil(t, result.Rollback) {
+		assert.Equal(t, []string{"deployment.apps/api"}, result.Rollback.RolledBack)
+	}
+	// The deploy still failed, although the previous version is back.
+	assert.Equal(t, []PipelineFailure{{Activity: "Deploy", Kind: FailureIssues, Details: "rollout of deployment.apps/api failed: progress deadline exceeded"}}, result.Failures)
+	for _, step := range result.Steps {
+		if step.Name == "Rollback" {
+			assert.Equal(t, StepDone, step.State)
+		}
+	}
+
+	params := PipelineParams{GitURL: gitUrl, AutoRollback: true}
+	assert.ErrorContains(t, params.Validate(), "AutoRollback requires K8s")
+}
+
+func TestK8sDeploy(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.K8sDeploy, mock.Anything, mock.MatchedBy(func(p K8sDeployParams) bool {
+		return p.Config.ManifestsDir == "deploy/k8s"
+	})).Return(&K8sDeployResult{ErrorMsg: "rollout of deployment.apps/api failed: progress deadline exceeded"}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, K8s: &K8sConfig{ManifestsDir: "deploy/k8s"}})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, []PipelineFailure{{Activity: "Deploy", Kind: FailureIssues, Details: "rollout of deployment.apps/api failed: progress deadline exceeded"}}, result.Failures)
+	env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+
+	params := PipelineParams{GitURL: gitUrl, K8s: &K8sConfig{ManifestsDir: "../k8s"}}
+	assert.ErrorContains(t, params.Validate(), "must be relative to the repository")
+	params = PipelineParams{GitURL: gitUrl, K8s: &K8sConfig{ManifestsDir: "k8s"}, DeployCommand: []string{"make", "deploy"}}
+	assert.ErrorContains(t, params.Validate(), "K8s cannot be used with DeployCommand")
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// GoLicenseCheck params and results
+type GoLicenseCheckParams struct {
+	Metadata PipelineActivityMetadata
+	// Allowed lists the acceptable licenses, as go-licenses names them, e.g. "MIT" or "Apache-2.0".
+	Allowed []string
+}
+
+type GoLicenseCheckResult struct {
+	// Forbidden lists the dependencies under a license not in Allowed, as "module (license)".
+	Forbidden []string
+	// Unknown lists the dependencies whose license go-licenses couldn't identify.
+	Unknown   []string
+	Logs      string
+	ElapsedMs int64
+}
+
+// GoLicenseCheck runs `go-licenses csv ./...` in the specified directory and sorts the
+// dependencies whose license isn't allowed into Forbidden and Unknown.
+func (pa *PipelineActivity) GoLicenseCheck(ctx context.Context, params GoLicenseCheckParams) (*GoLicenseCheckResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &GoLicenseCheckResult{
+		Forbidden: []string{},
+		Unknown:   []string{},
+	}
+
+	goLicenses := lookTool(params.Metadata, "go-licenses")
+	if _, err := exec.LookPath(goLicenses); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"go-licenses not found in PATH, install it with `go install github.com/google/go-licenses@latest` or PipelineParams.Tools",
+			"ToolNotFound", err)
+	}
+
+	start := time.Now()
+	stdout, stderr, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: goEnv(params.Metadata)}, goLicenses, "csv", "./...")
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	// The CSV goes to the result; the logs only get the diagnostics.
+	logs := &logTail{}
+	_, _ = logs.Write([]byte(stderr))
+	result.Logs = logs.String()
+
+	licenses, parseErr := parseGoLicensesCSV(strings.NewReader(stdout))
+	if parseErr != nil {
+		return nil, fmt.Errorf("parsing go-licenses output: %w", parseErr)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || len(licenses) == 0 {
+			return nil, toolError("go-licenses", err, stderr)
+		}
+		// go-licenses exits non-zero when it can't identify some licenses, but still lists them.
+		logger.Info("Command exited with non-zero status due to unidentified licenses")
+	}
+
+	for _, l := range licenses {
+		switch {
+		case l.license == "" || l.license == "Unknown":
+			result.Unknown = append(result.Unknown, l.module)
+		case !slices.Contains(params.Allowed, l.license):
+			result.Forbidden = append(result.Forbidden, fmt.Sprintf("%s (%s)", l.module, l.license))
+		}
+	}
+
+	logger.Info("License check ran", "dependencies", len(licenses), "forbidden", len(result.Forbidden), "unknown", len(result.Unknown))
+	return result, nil
+}
+
+type moduleLicense struct {
+	module  string
+	license string
+}
+
+// parseGoLicensesCSV parses the `module,license URL,license` lines of `go-licenses csv`.
+func parseGoLicensesCSV(r io.Reader) ([]moduleLicense, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	licenses := make([]moduleLicense, 0, len(records))
+	for _, record := range records {
+		licenses = append(licenses, moduleLicense{module: record[0], license: record[2]})
+	}
+	return licenses, nil
+}
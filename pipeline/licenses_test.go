@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestGoLicenseCheckActivity(t *testing.T) {
+	// A go-licenses that lists one license of each kind, and fails on the unidentified one.
+	fakeTool(t, "go-licenses", `echo 'example.com/app,https://example.com/app/LICENSE,MIT'
+echo 'github.com/copyleft/lib,https://github.com/copyleft/lib/LICENSE,GPL-3.0'
+echo 'github.com/mystery/lib,Unknown,Unknown'
+echo 'E0601 library.go:117] Failed to find license for github.com/mystery/lib' >&2
+exit 1
+`)
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.GoLicenseCheck)
+
+	val, err := env.ExecuteActivity(pa.GoLicenseCheck, GoLicenseCheckParams{
+		Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+		Allowed:  []string{"MIT", "Apache-2.0"},
+	})
+	assert.NoError(t, err)
+
+	var result GoLicenseCheckResult
+	assert.NoError(t, val.Get(&result))
+	assert.Equal(t, []string{"github.com/copyleft/lib (GPL-3.0)"}, result.Forbidden)
+	assert.Equal(t, []string{"github.com/mystery/lib"}, result.Unknown)
+	assert.Contains(t, result.Logs, "Failed to find license")
+}
+
+func TestLicenseCheck(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoLicenseCheck, mock.Anything, GoLicenseCheckParams{
+		Metadata: PipelineActivityMetadata{Workdir: "/tmp/test", GoModCache: "/tmp/gomodcache"},
+		Allowed:  []string{"MIT"},
+	}).Return(&GoLicenseCheckResult{Forbidden: []string{"github.com/copyleft/lib (GPL-3.0)"}}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, AllowedLicenses: []string{"MIT"}})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	if assert.Len(t, result.Failures, 1) {
+		assert.Equal(t, "GoLicenseCheck", result.Failures[0].Activity)
+	}
+}
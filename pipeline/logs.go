@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// maxLogBytes caps the logs an activity returns, so results stay well under Temporal's
+// payload size limit.
+const maxLogBytes = 64 << 10
+
+// logTail captures the combined output of a command, keeping only the last maxLogBytes.
+// It is safe for concurrent use, since exec.Cmd copies stdout and stderr from separate
+// goroutines when they are different writers.
+type logTail struct {
+	mu        sync.Mutex
+	buf       []byte
+	truncated bool
+}
+
+func (l *logTail) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf, p...)
+	if over := len(l.buf) - maxLogBytes; over > 0 {
+		l.buf = append(l.buf[:0], l.buf[over:]...)
+		l.truncated = true
+	}
+	return len(p), nil
+}
+
+// String returns the captured output, noting when earlier output was dropped.
+func (l *logTail) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.truncated {
+		return fmt.Sprintf("[output truncated to the last %d bytes]\n%s", maxLogBytes, l.buf)
+	}
+	return string(l.buf)
+}
+
+// checkReport is what every check activity's result reports besides its findings.
+type checkReport struct {
+	Logs      string
+	ElapsedMs int64
+}
+
+// activityReport returns the checkReport of a completed activity's result, empty if it failed.
+func activityReport(ctx workflow.Context, future workflow.Future) checkReport {
+	var report checkReport
+	if err := future.Get(ctx, &report); err != nil {
+		return checkReport{}
+	}
+	return report
+}
@@ -0,0 +1,27 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogTail(t *testing.T) {
+	t.Run("Keeps short output as is", func(t *testing.T) {
+		logs := &logTail{}
+		_, _ = logs.Write([]byte("ok  \texample.com/pkg\n"))
+		assert.Equal(t, "ok  \texample.com/pkg\n", logs.String())
+	})
+
+	t.Run("Keeps the last maxLogBytes of long output", func(t *testing.T) {
+		logs := &logTail{}
+		_, _ = logs.Write([]byte(strings.Repeat("a", maxLogBytes)))
+		_, _ = logs.Write([]byte("FAIL\n"))
+
+		got := logs.String()
+		assert.True(t, strings.HasPrefix(got, "[output truncated to the last 65536 bytes]\n"))
+		assert.True(t, strings.HasSuffix(got, "FAIL\n"))
+		assert.Len(t, strings.TrimPrefix(got, "[output truncated to the last 65536 bytes]\n"), maxLogBytes)
+	})
+}
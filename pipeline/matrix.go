@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// MatrixParams lists the axes of a build matrix. GoBuild runs once per combination; an empty
+// axis uses the worker's default.
+type MatrixParams struct {
+	// Go lists Go versions, e.g. "1.22.5", selected through GOTOOLCHAIN.
+	Go   []string `json:"go" yaml:"go"`
+	OS   []string `json:"os" yaml:"os"`
+	Arch []string `json:"arch" yaml:"arch"`
+	// Targets lists GOOS/GOARCH pairs, e.g. "linux/arm64", to build instead of every
+	// combination of OS and Arch.
+	Targets []string `json:"targets" yaml:"targets"`
+	// GOFLAGS is set for the matrix builds, e.g. "-tags=netgo".
+	GOFLAGS string `json:"goflags" yaml:"goflags"`
+}
+
+// platforms returns the GOOS/GOARCH pairs of the matrix, in OS, Arch order.
+func (m MatrixParams) platforms() [][2]string {
+	var platforms [][2]string
+	if len(m.Targets) > 0 {
+		for _, target := range m.Targets {
+			goos, goarch, _ := strings.Cut(target, "/")
+			platforms = append(platforms, [2]string{goos, goarch})
+		}
+		return platforms
+	}
+	for _, goos := range axis(m.OS) {
+		for _, goarch := range axis(m.Arch) {
+			platforms = append(platforms, [2]string{goos, goarch})
+		}
+	}
+	return platforms
+}
+
+// axis returns the values of a matrix axis, or a single empty value keeping the worker's default.
+func axis(values []string) []string {
+	if len(values) == 0 {
+		return []string{""}
+	}
+	return values
+}
+
+// Matrix cell statuses recorded in MatrixCellResult.
+const (
+	MatrixPassed  = "passed"
+	MatrixFailed  = "failed"
+	MatrixSkipped = "skipped"
+)
+
+// MatrixCellResult is the outcome of one combination of a build matrix.
+type MatrixCellResult struct {
+	Go       string            `json:"go,omitempty"`
+	OS       string            `json:"os,omitempty"`
+	Arch     string            `json:"arch,omitempty"`
+	Status   string            `json:"status"`
+	Failures []PipelineFailure `json:"failures,omitempty"`
+}
+
+type matrixCell struct {
+	result MatrixCellResult
+	future workflow.Future
+}
+
+// startMatrix starts a GoBuild for every combination of the matrix axes, in Go, OS, Arch order.
+func startMatrix(ctx workflow.Context, matrix MatrixParams, params GoBuildParams) []matrixCell {
+	var cells []matrixCell
+	for _, goVersion := range axis(matrix.Go) {
+		for _, platform := range matrix.platforms() {
+			cellParams := params
+			cellParams.GoVersion, cellParams.GOOS, cellParams.GOARCH = goVersion, platform[0], platform[1]
+			cellParams.GOFLAGS = matrix.GOFLAGS
+			cells = append(cells, matrixCell{
+				result: MatrixCellResult{Go: goVersion, OS: platform[0], Arch: platform[1]},
+				future: workflow.ExecuteActivity(ctx, pa.GoBuild, cellParams),
+			})
+		}
+	}
+	return cells
+}
+
+// collectMatrix waits for every cell started by startMatrix and returns their results.
+func collectMatrix(ctx workflow.Context, cells []matrixCell, params PipelineParams, result *PipelineResult) []MatrixCellResult {
+	results := make([]MatrixCellResult, 0, len(cells))
+	for _, cell := range cells {
+		cellResult := cell.result
+		if err := cell.future.Get(ctx, nil); temporal.IsCanceledError(err) {
+			cellResult.Status = MatrixSkipped
+			results = append(results, cellResult)
+			continue
+		}
+		cellResult.Failures = checkFailures(ctx, "GoBuild", cell.future, params, result)
+		cellResult.Status = MatrixPassed
+		if len(cellResult.Failures) > 0 {
+			cellResult.Status = MatrixFailed
+		}
+		results = append(results, cellResult)
+	}
+	return results
+}
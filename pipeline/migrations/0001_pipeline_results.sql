@@ -0,0 +1,16 @@
+-- pipeline_results archives the result of every pipeline run. A Repeat pipeline runs several
+-- times within one workflow run, so rows are keyed by the start of the pipeline run too.
+CREATE TABLE IF NOT EXISTS pipeline_results (
+    workflow_id TEXT NOT NULL,
+    run_id      TEXT NOT NULL,
+    git_url     TEXT NOT NULL,
+    commit_sha  TEXT NOT NULL,
+    started_at  TIMESTAMPTZ NOT NULL,
+    duration_ms BIGINT NOT NULL,
+    success     BOOLEAN NOT NULL,
+    result      JSONB NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (workflow_id, run_id, started_at)
+);
+
+CREATE INDEX IF NOT EXISTS pipeline_results_git_url_started_at ON pipeline_results (git_url, started_at);
@@ -0,0 +1,290 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// DefaultNotifyTemplate renders the notification message when PipelineParams.NotifyTemplate is empty.
+const DefaultNotifyTemplate = `{{if .Failures}}:x: Pipeline failed with {{len .Failures}} failure(s):
+{{range .Failures}}• {{.Activity}}
+{{end}}{{else}}:white_check_mark: Pipeline succeeded{{end}}`
+
+// Notify params
+type NotifyParams struct {
+	// URL receives a Slack-compatible `{"text": ...}` JSON payload.
+	URL string
+	// Template is a text/template executed against Result. Empty uses DefaultNotifyTemplate.
+	Template string
+	Result   PipelineResult
+}
+
+// Notify posts a message rendered from the pipeline result to a Slack or generic webhook.
+func (pa *PipelineActivity) Notify(ctx context.Context, params NotifyParams) error {
+	message, err := renderNotification(params.Template, params.Result)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]string{"text": message})
+	if err != nil {
+		return fmt.Errorf("marshalling notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, params.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	activityLogger(ctx).Info("Sending pipeline notification")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sending notification: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// EmailNotification configures the email notification target. SMTP credentials are not part
+// of it; the worker reads them from SMTP_USERNAME and SMTP_PASSWORD.
+type EmailNotification struct {
+	Host string   `json:"host" yaml:"host"`
+	Port int      `json:"port" yaml:"port"`
+	From string   `json:"from" yaml:"from"`
+	To   []string `json:"to" yaml:"to"`
+}
+
+func (en *EmailNotification) validate() error {
+	if en == nil {
+		return nil
+	}
+	if en.Host == "" || en.Port == 0 {
+		return fmt.Errorf("NotifyEmail host and port are required")
+	}
+	if en.From == "" || len(en.To) == 0 {
+		return fmt.Errorf("NotifyEmail from and to are required")
+	}
+	return nil
+}
+
+// NotifyEmail params
+type NotifyEmailParams struct {
+	Email    EmailNotification
+	Template string
+	Result   PipelineResult
+}
+
+// NotifyEmail sends a message rendered from the pipeline result by email.
+func (pa *PipelineActivity) NotifyEmail(ctx context.Context, params NotifyEmailParams) error {
+	message, err := renderNotification(params.Template, params.Result)
+	if err != nil {
+		return err
+	}
+
+	subject := "Pipeline succeeded"
+	if len(params.Result.Failures) > 0 {
+		subject = "Pipeline failed"
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", params.Email.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(params.Email.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(message, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if pa.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", pa.SMTPUsername, pa.SMTPPassword, params.Email.Host)
+	}
+	sendMail := pa.SendMail
+	if sendMail == nil {
+		sendMail = smtp.SendMail
+	}
+
+	addr := net.JoinHostPort(params.Email.Host, strconv.Itoa(params.Email.Port))
+	activityLogger(ctx).Info("Sending pipeline email notification", "addr", addr, "to", params.Email.To)
+	if err := sendMail(addr, auth, params.Email.From, params.Email.To, msg.Bytes()); err != nil {
+		// Permanent SMTP failures (5xx), such as a rejected login or recipient, won't succeed on retry.
+		var smtpErr *textproto.Error
+		if errors.As(err, &smtpErr) && smtpErr.Code >= 500 {
+			return temporal.NewNonRetryableApplicationError("sending email notification", "SMTPPermanentError", err)
+		}
+		return fmt.Errorf("sending email notification: %w", err)
+	}
+	return nil
+}
+
+// callbackTimeout is the StartToClose timeout of NotifyWebhook, which bounds each attempt to
+// deliver the callback.
+const callbackTimeout = 30 * time.Second
+
+// NotifyWebhook params
+type NotifyWebhookParams struct {
+	URL string
+	// Headers maps each header to the environment variable of the worker holding its value.
+	Headers map[string]string
+	Payload WebhookPayload
+}
+
+// WebhookPayload is the JSON body NotifyWebhook posts to PipelineParams.CallbackURL.
+type WebhookPayload struct {
+	WorkflowID string `json:"workflow_id"`
+	RunID      string `json:"run_id"`
+	// Status is "succeeded", or "failed" when the result has failures.
+	Status string         `json:"status"`
+	Result PipelineResult `json:"result"`
+}
+
+// NotifyWebhook posts the pipeline result to a callback URL. Server errors are retried, while
+// other non-2xx responses, like a rejected authorization, fail right away.
+func (pa *PipelineActivity) NotifyWebhook(ctx context.Context, params NotifyWebhookParams) error {
+	body, err := json.Marshal(params.Payload)
+	if err != nil {
+		return fmt.Errorf("marshalling callback: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, params.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating callback request: %w", err)
+	}
+	for header, variable := range params.Headers {
+		value, ok := os.LookupEnv(variable)
+		if !ok {
+			return temporal.NewNonRetryableApplicationError(fmt.Sprintf("callback header %s: %s is not set on the worker", header, variable), "CallbackHeaderUnset", nil)
+		}
+		req.Header.Set(header, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	activityLogger(ctx).Info("Sending pipeline callback", "status", params.Payload.Status)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending callback: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("sending callback: unexpected status %s", resp.Status)
+	case resp.StatusCode >= 300:
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("sending callback: unexpected status %s", resp.Status), "CallbackRejected", nil)
+	}
+	return nil
+}
+
+func validateCallback(callbackURL string, headers map[string]string) error {
+	if callbackURL == "" {
+		if len(headers) > 0 {
+			return fmt.Errorf("CallbackHeaders requires CallbackURL")
+		}
+		return nil
+	}
+	for header, variable := range headers {
+		if variable == "" || strings.ContainsAny(variable, "= ") {
+			return fmt.Errorf("CallbackHeaders %s must name an environment variable of the worker, not %q", header, variable)
+		}
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return fmt.Errorf("CallbackURL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("CallbackURL %q must be an http or https URL", callbackURL)
+	}
+	return nil
+}
+
+func hasNotifications(params PipelineParams) bool {
+	return params.NotifyURL != "" || params.NotifyEmail != nil || params.CallbackURL != ""
+}
+
+// notify sends the pipeline result to every configured notification target, each as its own
+// activity so a retry of one target doesn't resend the others. It returns the last error, if any.
+func notify(ctx workflow.Context, params PipelineParams, result *PipelineResult) error {
+	logger := workflow.GetLogger(ctx)
+
+	var futures []workflow.Future
+	if params.NotifyURL != "" {
+		futures = append(futures, workflow.ExecuteActivity(ctx, pa.Notify, NotifyParams{
+			URL:      params.NotifyURL,
+			Template: params.NotifyTemplate,
+			Result:   *result,
+		}))
+	}
+	if params.NotifyEmail != nil {
+		futures = append(futures, workflow.ExecuteActivity(ctx, pa.NotifyEmail, NotifyEmailParams{
+			Email:    *params.NotifyEmail,
+			Template: params.NotifyTemplate,
+			Result:   *result,
+		}))
+	}
+	if params.CallbackURL != "" {
+		info := workflow.GetInfo(ctx)
+		status := "succeeded"
+		if len(result.Failures) > 0 {
+			status = "failed"
+		}
+		futures = append(futures, workflow.ExecuteActivity(workflow.WithStartToCloseTimeout(ctx, callbackTimeout), pa.NotifyWebhook, NotifyWebhookParams{
+			URL:     params.CallbackURL,
+			Headers: params.CallbackHeaders,
+			Payload: WebhookPayload{
+				WorkflowID: info.WorkflowExecution.ID,
+				RunID:      info.WorkflowExecution.RunID,
+				Status:     status,
+				Result:     *result,
+			},
+		}))
+	}
+
+	var lastErr error
+	for _, future := range futures {
+		if err := future.Get(ctx, nil); err != nil {
+			// The pipeline itself is done; a lost notification shouldn't fail it.
+			logger.Warn("Notify failed", "error", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// parseNotifyTemplate parses a notification template, falling back to DefaultNotifyTemplate.
+func parseNotifyTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultNotifyTemplate
+	}
+	tmpl, err := template.New("notify").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing notify template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderNotification executes the notification template against result.
+func renderNotification(text string, result PipelineResult) (string, error) {
+	tmpl, err := parseNotifyTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var message strings.Builder
+	if err := tmpl.Execute(&message, result); err != nil {
+		return "", fmt.Errorf("rendering notify template: %w", err)
+	}
+	return strings.TrimSpace(message.String()), nil
+}
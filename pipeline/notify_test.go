@@ -0,0 +1,232 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestRenderNotification(t *testing.T) {
+	result := PipelineResult{
+		Failures: []PipelineFailure{
+			{Activity: "GoTest", Details: "TestFoo failed"},
+			{Activity: "GoVulnCheck", Details: "GO-2024-0001"},
+		},
+		Deployments: []DeploymentResult{{Environment: "prod", Status: DeploymentSkipped}},
+	}
+
+	t.Run("Custom template", func(t *testing.T) {
+		tmpl := `:rotating_light: {{len .Failures}} failed:{{range .Failures}} {{.Activity}}{{end}}` +
+			`{{range .Deployments}} | {{.Environment}}={{.Status}}{{end}}`
+		message, err := renderNotification(tmpl, result)
+		assert.NoError(t, err)
+		assert.Equal(t, ":rotating_light: 2 failed: GoTest GoVulnCheck | prod=skipped", message)
+	})
+
+	t.Run("Default template", func(t *testing.T) {
+		message, err := renderNotification("", result)
+		assert.NoError(t, err)
+		assert.Equal(t, ":x: Pipeline failed with 2 failure(s):\n• GoTest\n• GoVulnCheck", message)
+
+		message, err = renderNotification("", PipelineResult{})
+		assert.NoError(t, err)
+		assert.Equal(t, ":white_check_mark: Pipeline succeeded", message)
+	})
+
+	t.Run("Invalid template is rejected by Validate", func(t *testing.T) {
+		params := PipelineParams{GitURL: gitUrl, NotifyTemplate: "{{.Failures"}
+		assert.ErrorContains(t, params.Validate(), "parsing notify template")
+	})
+}
+
+func TestNotifyActivity(t *testing.T) {
+	var payload map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer srv.Close()
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.Notify)
+
+	_, err := env.ExecuteActivity(pa.Notify, NotifyParams{
+		URL:      srv.URL,
+		Template: "{{len .Failures}} failures",
+		Result:   PipelineResult{Failures: []PipelineFailure{{Activity: "GoFmt"}}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"text": "1 failures"}, payload)
+}
+
+func TestNotifyEmailActivity(t *testing.T) {
+	email := EmailNotification{Host: "smtp.example.com", Port: 587, From: "ci@example.com", To: []string{"team@example.com"}}
+
+	t.Run("Sends the rendered summary", func(t *testing.T) {
+		var gotAddr, gotFrom string
+		var gotTo []string
+		var gotMsg []byte
+		var gotAuth smtp.Auth
+		a := &PipelineActivity{
+			SMTPUsername: "ci",
+			SMTPPassword: "secret",
+			SendMail: func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+				gotAddr, gotAuth, gotFrom, gotTo, gotMsg = addr, auth, from, to, msg
+				return nil
+			},
+		}
+
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(a.NotifyEmail)
+
+		_, err := env.ExecuteActivity(a.NotifyEmail, NotifyEmailParams{
+			Email:  email,
+			Result: PipelineResult{Failures: []PipelineFailure{{Activity: "GoTest"}}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "smtp.example.com:587", gotAddr)
+		assert.NotNil(t, gotAuth)
+		assert.Equal(t, "ci@example.com", gotFrom)
+		assert.Equal(t, []string{"team@example.com"}, gotTo)
+		assert.Contains(t, string(gotMsg), "Subject: Pipeline failed\r\n")
+		assert.Contains(t, string(gotMsg), "\r\n\r\n:x: Pipeline failed with 1 failure(s):\r\n• GoTest")
+	})
+
+	t.Run("Permanent SMTP errors are not retried", func(t *testing.T) {
+		a := &PipelineActivity{
+			SendMail: func(string, smtp.Auth, string, []string, []byte) error {
+				return &textproto.Error{Code: 535, Msg: "authentication failed"}
+			},
+		}
+
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(a.NotifyEmail)
+
+		_, err := env.ExecuteActivity(a.NotifyEmail, NotifyEmailParams{Email: email})
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.True(t, appErr.NonRetryable())
+		}
+	})
+}
+
+func TestNotifyWebhookActivity(t *testing.T) {
+	t.Run("Posts the result with the headers", func(t *testing.T) {
+		t.Setenv("CALLBACK_AUTHORIZATION", "Bearer token")
+		var payload WebhookPayload
+		var auth string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		}))
+		defer srv.Close()
+
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.NotifyWebhook)
+
+		sent := WebhookPayload{
+			WorkflowID: "pipeline-1",
+			Status:     "failed",
+			Result:     PipelineResult{Failures: []PipelineFailure{{Activity: "GoTest", Kind: FailureIssues}}},
+		}
+		_, err := env.ExecuteActivity(pa.NotifyWebhook, NotifyWebhookParams{
+			URL:     srv.URL,
+			Headers: map[string]string{"Authorization": "CALLBACK_AUTHORIZATION"},
+			Payload: sent,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer token", auth)
+		assert.Equal(t, sent, payload)
+	})
+
+	t.Run("Header variable not set", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("callback sent without its headers")
+		}))
+		defer srv.Close()
+
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.NotifyWebhook)
+
+		_, err := env.ExecuteActivity(pa.NotifyWebhook, NotifyWebhookParams{
+			URL:     srv.URL,
+			Headers: map[string]string{"Authorization": "CALLBACK_UNSET_AUTHORIZATION"},
+		})
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.True(t, appErr.NonRetryable())
+		}
+		assert.ErrorContains(t, err, "CALLBACK_UNSET_AUTHORIZATION is not set on the worker")
+	})
+
+	for _, tt := range []struct {
+		status       int
+		nonRetryable bool
+	}{
+		{http.StatusServiceUnavailable, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusUnauthorized, true},
+	} {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestActivityEnvironment()
+			env.RegisterActivity(pa.NotifyWebhook)
+
+			_, err := env.ExecuteActivity(pa.NotifyWebhook, NotifyWebhookParams{URL: srv.URL})
+			var appErr *temporal.ApplicationError
+			if assert.ErrorAs(t, err, &appErr) {
+				assert.Equal(t, tt.nonRetryable, appErr.NonRetryable())
+			}
+		})
+	}
+}
+
+func TestCallback(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	var sent NotifyWebhookParams
+	env.OnActivity(pa.NotifyWebhook, mock.Anything, mock.MatchedBy(func(p NotifyWebhookParams) bool {
+		sent = p
+		return true
+	})).Return(nil)
+	env.OnActivity(pa.GoBuild, mock.Anything, mock.Anything).Return(&GoBuildResult{FailedFiles: []string{"main.go"}}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{
+		GitURL:          gitUrl,
+		CallbackURL:     "https://ci.example.com/callback",
+		CallbackHeaders: map[string]string{"Authorization": "CALLBACK_AUTHORIZATION"},
+	})
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	assert.Equal(t, "https://ci.example.com/callback", sent.URL)
+	assert.Equal(t, map[string]string{"Authorization": "CALLBACK_AUTHORIZATION"}, sent.Headers)
+	assert.Equal(t, "default-test-workflow-id", sent.Payload.WorkflowID)
+	assert.Equal(t, "failed", sent.Payload.Status)
+	assert.Len(t, sent.Payload.Result.Failures, 1)
+
+	for _, params := range []PipelineParams{
+		{GitURL: gitUrl, CallbackURL: "ftp://ci.example.com"},
+		{GitURL: gitUrl, CallbackHeaders: map[string]string{"Authorization": "CALLBACK_AUTHORIZATION"}},
+		{GitURL: gitUrl, CallbackURL: "https://ci.example.com/callback", CallbackHeaders: map[string]string{"Authorization": "Bearer token"}},
+	} {
+		assert.ErrorContains(t, params.Validate(), "Callback")
+	}
+}
@@ -2,7 +2,11 @@ package pipeline
 
 import (
 	"fmt"
+	"path"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"go.temporal.io/sdk/temporal"
@@ -10,149 +14,1375 @@ import (
 )
 
 type PipelineParams struct {
-	GitURL        string   `json:"git_url" yaml:"git_url"`
+	GitURL string `json:"git_url" yaml:"git_url"`
+	Ref    string `json:"ref" yaml:"ref"`
+	// Subdir runs the Go checks and the deploy in this directory of the repository instead of
+	// its root, e.g. "services/foo" in a mono-repo. The repository config and env file are read
+	// from it too.
+	Subdir string `json:"subdir" yaml:"subdir"`
+	// Branches, when set, limits the pipeline to refs matching one of these path.Match patterns,
+	// e.g. "main" or "release/*". Other refs are skipped without cloning. An empty Ref is the
+	// default branch and always runs.
+	Branches []string `json:"branches" yaml:"branches"`
+	// Repos, instead of GitURL and Ref, clones several repositories into their own subdirs of
+	// one workdir and runs the Go checks in each.
+	Repos []RepoSpec `json:"repos" yaml:"repos"`
+	// TarballURL, instead of GitURL, downloads a .tar.gz or .tar of the sources into the workdir,
+	// dropping TarballStripComponents leading directories from its paths.
+	TarballURL             string `json:"tarball_url" yaml:"tarball_url"`
+	TarballStripComponents int    `json:"tarball_strip_components" yaml:"tarball_strip_components"`
+	// WorkdirRoot is the directory the workdir is created in, instead of the worker's
+	// WORKDIR_ROOT or os.TempDir(), e.g. a larger volume for big repositories.
+	WorkdirRoot string `json:"workdir_root" yaml:"workdir_root"`
+	// GitCACertPath is a CA bundle on the worker to trust for cloning and fetching over HTTPS, for git hosts
+	// with certificates from an internal PKI. Empty trusts the system's CAs.
+	GitCACertPath string `json:"git_ca_cert_path" yaml:"git_ca_cert_path"`
+	// GitInsecure skips the verification of the git host's certificate altogether. Anyone on the
+	// network path can then serve the pipeline other sources, which it would build and deploy;
+	// only use it for testing, and prefer GitCACertPath.
+	GitInsecure bool `json:"git_insecure" yaml:"git_insecure"`
+	// MinFreeBytes, when set, fails the pipeline before cloning if the filesystem of the workdir
+	// root has less free space.
+	MinFreeBytes int64 `json:"min_free_bytes" yaml:"min_free_bytes"`
+	// CommitRange, e.g. "base..head", runs the checks against each commit of the range in turn
+	// instead of the checked out ref, and reports the first commit that fails. Nothing is deployed.
+	CommitRange string `json:"commit_range" yaml:"commit_range"`
+	// Bisect binary searches CommitRange for the first failing commit instead of checking every
+	// commit, checking at most BisectMaxSteps commits (DefaultBisectMaxSteps when zero).
+	Bisect         bool `json:"bisect" yaml:"bisect"`
+	BisectMaxSteps int  `json:"bisect_max_steps" yaml:"bisect_max_steps"`
+	// Env is added to the environment of every Go command, e.g. CGO_ENABLED=0 or GOPRIVATE.
+	// Values are recorded in the workflow history, so pass secrets through the worker's environment.
+	Env map[string]string `json:"env" yaml:"env"`
+	// EnvFile is a .env-style file, relative to the repository, whose variables are added to the
+	// Go commands' environment below Env. When empty, DefaultEnvFile is used if it exists.
+	EnvFile       string   `json:"env_file" yaml:"env_file"`
 	TestFlags     []string `json:"test_flags" yaml:"test_flags"`
 	BuildFlags    []string `json:"build_flags" yaml:"build_flags"`
 	GenerateFlags []string `json:"generate_flags" yaml:"generate_flags"`
+	// BuildTags are passed as -tags to GoTest, GoBuild, GoGenerate and GoBenchmark, e.g.
+	// ["integration"], so they don't have to be repeated in each of their flags.
+	BuildTags []string `json:"build_tags" yaml:"build_tags"`
+	// TestPackages, when set, limits GoTest to these package patterns instead of ./..., e.g.
+	// ["./api/...", "./internal/store"]. They must be relative to the repository.
+	TestPackages []string `json:"test_packages" yaml:"test_packages"`
+	// DiffBase, when set, limits GoTest and GoBuild to the packages affected by the changes
+	// between it and the checked out ref, e.g. "origin/main" for a pull request. When no package
+	// can be singled out, e.g. because go.mod changed, everything runs.
+	DiffBase string `json:"diff_base" yaml:"diff_base"`
+	// LintFlags are passed to `golangci-lint run`, e.g. ["--config", ".golangci.yml", "--timeout", "5m"].
+	LintFlags    []string `json:"lint_flags" yaml:"lint_flags"`
+	FmtCheckOnly bool     `json:"fmt_check_only" yaml:"fmt_check_only"`
+	// Formatter selects the formatter of GoFmt, "gofmt" (the default) or "gofumpt". The workers
+	// need gofumpt installed for it, e.g. with Tools.
+	Formatter string `json:"formatter" yaml:"formatter"`
+	// Race runs the tests with the race detector. The workers need a C compiler for it.
+	Race bool `json:"race" yaml:"race"`
+	// TestP and TestParallel limit GoTest to building and testing TestP packages at once, and
+	// running TestParallel parallel tests at once in each, e.g. on workers shared by several
+	// pipelines. Zero keeps the go command's defaults, the number of CPUs.
+	TestP        int `json:"test_p" yaml:"test_p"`
+	TestParallel int `json:"test_parallel" yaml:"test_parallel"`
+	// RetryFailedTests re-runs failed tests up to this many times. Tests passing on a retry are
+	// reported in the result's FlakyTests and don't fail the pipeline.
+	RetryFailedTests int `json:"retry_failed_tests" yaml:"retry_failed_tests"`
+	// AutoCancelSuperseded cancels the running pipelines of the same repository and branch when
+	// this one starts. It needs the PipelineRepo and PipelineBranch search attributes.
+	AutoCancelSuperseded bool `json:"auto_cancel_superseded" yaml:"auto_cancel_superseded"`
+	// ConcurrencyGroup, e.g. "deploy-prod", lets only one pipeline of the group run at a time: a
+	// new pipeline waits for the running one to finish, or cancels it with CancelInProgress. It
+	// needs the PipelineConcurrencyGroup search attribute.
+	ConcurrencyGroup string `json:"concurrency_group" yaml:"concurrency_group"`
+	CancelInProgress bool   `json:"cancel_in_progress" yaml:"cancel_in_progress"`
+	// TaskQueue and Namespace override TEMPORAL_QUEUE and TEMPORAL_NAMESPACE when set.
+	TaskQueue string `json:"task_queue" yaml:"task_queue"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	// Retry tunes the backoff of the retry policy applied to every activity.
+	Retry RetryParams `json:"retry" yaml:"retry"`
+	// Staticcheck also runs staticcheck, separately from golangci-lint, in each repository.
+	Staticcheck bool `json:"staticcheck" yaml:"staticcheck"`
+	// AllowedLicenses, when set, checks the licenses of each repository's dependencies with
+	// go-licenses, e.g. ["MIT", "BSD-3-Clause", "Apache-2.0"]. Other licenses fail the pipeline;
+	// licenses go-licenses can't identify are only reported.
+	AllowedLicenses []string `json:"allowed_licenses" yaml:"allowed_licenses"`
+	// DocCheck also checks that the exported identifiers of each repository are documented,
+	// for library modules. Less than DocMinCoverage percent of them documented
+	// (DefaultDocMinCoverage when zero) fails the pipeline.
+	DocCheck       bool    `json:"doc_check" yaml:"doc_check"`
+	DocMinCoverage float64 `json:"doc_min_coverage" yaml:"doc_min_coverage"`
+	// LintFailSeverity is the lowest lint severity (info, warning, error) that fails the pipeline.
+	// When empty, any lint issue fails the pipeline.
+	LintFailSeverity string `json:"lint_fail_severity" yaml:"lint_fail_severity"`
+	// SeparateDeploy runs the deploy step as its own DeployWorkflow instead of an activity.
+	SeparateDeploy bool `json:"separate_deploy" yaml:"separate_deploy"`
+	// DeployCommand and DeployEnv configure a real deploy. Without a command the deploy is simulated.
+	DeployCommand []string          `json:"deploy_command" yaml:"deploy_command"`
+	DeployEnv     map[string]string `json:"deploy_env" yaml:"deploy_env"`
+	// DeployTimeout bounds each deploy command, DefaultDeployTimeout when zero. A deploy command
+	// that fails or times out isn't retried, so a deploy never runs twice unattended.
+	DeployTimeout time.Duration `json:"deploy_timeout" yaml:"deploy_timeout"`
+	// DeployEnvironments, when set, replaces the single deploy with a promotion through each
+	// environment in order, stopping at the first one that fails.
+	DeployEnvironments []DeployEnvironment `json:"deploy_environments" yaml:"deploy_environments"`
+	// DeployServices, when set, replaces the single deploy with a plan of services that deploy in
+	// dependency order, with independent services deploying in parallel.
+	DeployServices []DeployService `json:"deploy_services" yaml:"deploy_services"`
+	// K8s, when set, replaces the deploy command with applying Kubernetes manifests and waiting
+	// for their rollout.
+	K8s *K8sConfig `json:"k8s" yaml:"k8s"`
+	// AutoRollback rolls a failed K8s deploy back to the revisions its workloads had before.
+	AutoRollback bool `json:"auto_rollback" yaml:"auto_rollback"`
+	// Tools are installed with GoInstallTools before the checks run, e.g. a pinned golangci-lint.
+	Tools []ToolSpec `json:"tools" yaml:"tools"`
+	// NoCache skips GoModDownload, so each Go activity resolves modules on its own.
+	NoCache bool `json:"no_cache" yaml:"no_cache"`
+	// PreDeployCommand runs after checks pass and before deploying; a failure blocks the deploy.
+	PreDeployCommand []string `json:"pre_deploy_command" yaml:"pre_deploy_command"`
+	// Sign, when set, signs container images with cosign after PreDeployCommand; a failed
+	// signature blocks the deploy.
+	Sign *SignConfig `json:"sign" yaml:"sign"`
+	// DeployGate, when set, is checked after PreDeployCommand; a closed gate skips the deploy.
+	DeployGate *DeployGate `json:"deploy_gate" yaml:"deploy_gate"`
+	// RequireApproval holds a deploy that is otherwise ready until it is approved with the
+	// DeployApprovalSignalName signal, e.g. by the approve command. Without an approval within
+	// ApprovalTimeout (DefaultApprovalTimeout when zero), the deploy is skipped.
+	RequireApproval bool          `json:"require_approval" yaml:"require_approval"`
+	ApprovalTimeout time.Duration `json:"approval_timeout" yaml:"approval_timeout"`
+	// GitHub, when set, reports the pipeline as a commit status of the cloned commit.
+	GitHub *GitHubConfig `json:"github" yaml:"github"`
+	// Incident, when set, opens an incident when the deploy fails.
+	Incident *IncidentConfig `json:"incident" yaml:"incident"`
+	// Matrix, when set, also builds every combination of its Go versions, OSes and architectures,
+	// or of its Go versions and targets.
+	Matrix *MatrixParams `json:"matrix" yaml:"matrix"`
+	// BenchmarkPattern, when set, also runs the benchmarks matching it with GoBenchmark.
+	BenchmarkPattern string   `json:"benchmark_pattern" yaml:"benchmark_pattern"`
+	BenchmarkFlags   []string `json:"benchmark_flags" yaml:"benchmark_flags"`
+	// BenchmarkBaseline is a path (relative to the repository) or URL of a JSON array of
+	// BenchmarkResult. Benchmarks slower than it by more than BenchmarkRegressionPercent
+	// (DefaultBenchmarkRegressionPercent when zero) fail the pipeline.
+	BenchmarkBaseline          string  `json:"benchmark_baseline" yaml:"benchmark_baseline"`
+	BenchmarkRegressionPercent float64 `json:"benchmark_regression_percent" yaml:"benchmark_regression_percent"`
+	// CustomSteps run the repository's own check scripts alongside the built-in checks.
+	CustomSteps []CustomStep `json:"custom_steps" yaml:"custom_steps"`
+	// MaxDuration bounds each run of the pipeline, DefaultMaxDuration when zero. Past it, the
+	// checks still running and any approval wait are cancelled and the deploy is skipped, but the
+	// pipeline still cleans up and reports, with a timeout failure.
+	MaxDuration time.Duration `json:"max_duration" yaml:"max_duration"`
+	// Repeat runs the pipeline again every Interval, from a fresh clone, until the workflow is
+	// cancelled. The workflow continues as new every ContinueAsNewEvery runs
+	// (DefaultContinueAsNewEvery when zero).
+	Repeat             bool          `json:"repeat" yaml:"repeat"`
+	Interval           time.Duration `json:"interval" yaml:"interval"`
+	ContinueAsNewEvery int           `json:"continue_as_new_every" yaml:"continue_as_new_every"`
+	// FailWorkflowOnChecks fails the workflow when the pipeline has failures, with the result as
+	// the details of a PipelineFailedErrorType application error, instead of completing it. A
+	// repeating pipeline only logs its failures.
+	FailWorkflowOnChecks bool `json:"fail_workflow_on_checks" yaml:"fail_workflow_on_checks"`
+	// FailFast cancels the remaining checks as soon as one of them fails.
+	FailFast bool `json:"fail_fast" yaml:"fail_fast"`
+	// NotifyURL, when set, receives a message summarizing the result once the pipeline finishes.
+	NotifyURL string `json:"notify_url" yaml:"notify_url"`
+	// NotifyEmail, when set, also sends the message by email.
+	NotifyEmail *EmailNotification `json:"notify_email" yaml:"notify_email"`
+	// NotifyTemplate is a text/template rendered against the PipelineResult to build the message.
+	// When empty, DefaultNotifyTemplate is used.
+	NotifyTemplate string `json:"notify_template" yaml:"notify_template"`
+	// CallbackURL, when set, receives the whole PipelineResult as JSON once the pipeline
+	// finishes, with CallbackHeaders. Each header maps to the environment variable of the worker
+	// holding its value, e.g. {"Authorization": "CALLBACK_AUTHORIZATION"}, so credentials stay
+	// out of workflow history.
+	CallbackURL     string            `json:"callback_url" yaml:"callback_url"`
+	CallbackHeaders map[string]string `json:"callback_headers" yaml:"callback_headers"`
+	// ArchiveDSN, when set, is the PostgreSQL database the result is archived to once the
+	// pipeline finishes, e.g. "postgres://ci@db/pipelines". Leave the password out and set
+	// PGPASSWORD on the worker instead, so it stays out of workflow history.
+	ArchiveDSN string `json:"archive_dsn" yaml:"archive_dsn"`
+}
+
+// RepoSpec is one repository of a multi-repo pipeline.
+type RepoSpec struct {
+	URL string `json:"url" yaml:"url"`
+	Ref string `json:"ref" yaml:"ref"`
+	// Subdir is where the repository is cloned, relative to the workdir. It also labels the
+	// repository's failures.
+	Subdir string `json:"subdir" yaml:"subdir"`
+	// Dir is the directory of the repository the Go checks run in, like PipelineParams.Subdir.
+	Dir string `json:"dir" yaml:"dir"`
+}
+
+// RepoSpecs returns the repositories of the pipeline: Repos, or the single GitURL or TarballURL
+// at the root of the workdir.
+func (pp *PipelineParams) RepoSpecs() []RepoSpec {
+	if len(pp.Repos) > 0 {
+		return pp.Repos
+	}
+	if pp.TarballURL != "" {
+		return []RepoSpec{{URL: pp.TarballURL, Dir: pp.Subdir}}
+	}
+	return []RepoSpec{{URL: pp.GitURL, Ref: pp.Ref, Dir: pp.Subdir}}
+}
+
+// DefaultMaxDuration is the default PipelineParams.MaxDuration.
+const DefaultMaxDuration = time.Hour
+
+func (pp *PipelineParams) maxDuration() time.Duration {
+	if pp.MaxDuration > 0 {
+		return pp.MaxDuration
+	}
+	return DefaultMaxDuration
+}
+
+// DefaultDeployTimeout is the default PipelineParams.DeployTimeout.
+const DefaultDeployTimeout = 30 * time.Minute
+
+func (pp *PipelineParams) deployTimeout() time.Duration {
+	if pp.DeployTimeout > 0 {
+		return pp.DeployTimeout
+	}
+	return DefaultDeployTimeout
+}
+
+// DeployEnvironment is one stage of a multi-environment deploy.
+type DeployEnvironment struct {
+	Name    string   `json:"name" yaml:"name"`
+	Command []string `json:"command" yaml:"command"`
+	// RequireApproval waits for the ApprovalSignalName(Name) signal before deploying. Without an
+	// approval within the pipeline's ApprovalTimeout, this and the remaining environments are
+	// skipped.
+	RequireApproval bool `json:"require_approval" yaml:"require_approval"`
+	// SmokeCommand runs after the deploy; promotion continues only if it succeeds.
+	SmokeCommand []string `json:"smoke_command" yaml:"smoke_command"`
+	// RollbackCommand, when set, runs if the smoke test fails.
+	RollbackCommand []string `json:"rollback_command" yaml:"rollback_command"`
+}
+
+// DeployService is one service of a deploy plan.
+type DeployService struct {
+	Name    string   `json:"name" yaml:"name"`
+	Command []string `json:"command" yaml:"command"`
+	// DependsOn lists the services that must deploy successfully before this one starts.
+	DependsOn []string `json:"depends_on" yaml:"depends_on"`
+}
+
+// RetryParams configures activity retry backoff. Zero values keep Temporal's defaults.
+type RetryParams struct {
+	InitialInterval    time.Duration `json:"initial_interval" yaml:"initial_interval"`
+	BackoffCoefficient float64       `json:"backoff_coefficient" yaml:"backoff_coefficient"`
+	MaximumInterval    time.Duration `json:"maximum_interval" yaml:"maximum_interval"`
 }
 
 func (pp *PipelineParams) Validate() error {
-	if pp.GitURL == "" {
+	sources := 0
+	for _, set := range []bool{pp.GitURL != "", len(pp.Repos) > 0, pp.TarballURL != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources == 0 {
 		return fmt.Errorf("GitURL is required")
 	}
+	if sources > 1 {
+		return fmt.Errorf("only one of GitURL, Repos and TarballURL can be set")
+	}
+	if pp.TarballURL != "" && (pp.Ref != "" || pp.CommitRange != "") {
+		return fmt.Errorf("Ref and CommitRange cannot be used with TarballURL")
+	}
+	for _, pkg := range pp.TestPackages {
+		if (pkg != "." && !strings.HasPrefix(pkg, "./")) || !filepath.IsLocal(path.Clean(pkg)) {
+			return fmt.Errorf("TestPackages pattern %q must be relative to the repository, e.g. ./pkg/...", pkg)
+		}
+	}
+	if pp.DiffBase != "" && (len(pp.Repos) > 0 || pp.TarballURL != "" || pp.CommitRange != "" || len(pp.TestPackages) > 0) {
+		return fmt.Errorf("DiffBase cannot be used with Repos, TarballURL, CommitRange or TestPackages")
+	}
+	if pp.GitCACertPath != "" && pp.GitInsecure {
+		return fmt.Errorf("GitCACertPath and GitInsecure cannot both be set")
+	}
+	if pp.RetryFailedTests < 0 {
+		return fmt.Errorf("RetryFailedTests must not be negative")
+	}
+	if pp.DocMinCoverage < 0 || pp.DocMinCoverage > 100 {
+		return fmt.Errorf("DocMinCoverage must be a percentage between 0 and 100")
+	}
+	if pp.TestP < 0 || pp.TestParallel < 0 {
+		return fmt.Errorf("TestP and TestParallel must not be negative")
+	}
+	switch pp.Formatter {
+	case "", FormatterGofmt, FormatterGofumpt:
+	default:
+		return fmt.Errorf("Formatter must be %q or %q, got %q", FormatterGofmt, FormatterGofumpt, pp.Formatter)
+	}
+	for _, tag := range pp.BuildTags {
+		if tag == "" || strings.ContainsAny(tag, ", \t") {
+			return fmt.Errorf("BuildTags tag %q must be a single non-empty tag", tag)
+		}
+	}
+	if pp.TarballStripComponents < 0 {
+		return fmt.Errorf("TarballStripComponents must not be negative")
+	}
+	subdirs := map[string]bool{}
+	for _, repo := range pp.Repos {
+		if repo.URL == "" {
+			return fmt.Errorf("Repos url is required")
+		}
+		if !filepath.IsLocal(repo.Subdir) {
+			return fmt.Errorf("Repos subdir %q must be a relative path within the workdir", repo.Subdir)
+		}
+		if subdirs[repo.Subdir] {
+			return fmt.Errorf("Repos subdir %q is duplicated", repo.Subdir)
+		}
+		subdirs[repo.Subdir] = true
+		if repo.Dir != "" && !filepath.IsLocal(repo.Dir) {
+			return fmt.Errorf("Repos dir %q must be a relative path within the repository", repo.Dir)
+		}
+	}
+	if pp.Subdir != "" && len(pp.Repos) > 0 {
+		return fmt.Errorf("Subdir cannot be used with Repos, set their dir instead")
+	}
+	if pp.Subdir != "" && !filepath.IsLocal(pp.Subdir) {
+		return fmt.Errorf("Subdir %q must be a relative path within the repository", pp.Subdir)
+	}
+	if pp.CommitRange != "" && len(pp.Repos) > 0 {
+		return fmt.Errorf("CommitRange cannot be used with Repos")
+	}
+	if pp.CommitRange != "" && !strings.Contains(pp.CommitRange, "..") {
+		return fmt.Errorf("CommitRange %q must be of the form base..head", pp.CommitRange)
+	}
+	if pp.CancelInProgress && pp.ConcurrencyGroup == "" {
+		return fmt.Errorf("CancelInProgress requires ConcurrencyGroup")
+	}
+	if pp.WorkdirRoot != "" && !filepath.IsAbs(pp.WorkdirRoot) {
+		return fmt.Errorf("WorkdirRoot %q must be an absolute path", pp.WorkdirRoot)
+	}
+	if pp.MinFreeBytes < 0 {
+		return fmt.Errorf("MinFreeBytes must not be negative")
+	}
+	if pp.EnvFile != "" && !filepath.IsLocal(pp.EnvFile) {
+		return fmt.Errorf("EnvFile %q must be a relative path within the repository", pp.EnvFile)
+	}
+	if pp.Matrix != nil {
+		if len(pp.Matrix.Targets) > 0 && (len(pp.Matrix.OS) > 0 || len(pp.Matrix.Arch) > 0) {
+			return fmt.Errorf("Matrix Targets cannot be combined with OS or Arch")
+		}
+		for _, target := range pp.Matrix.Targets {
+			if goos, goarch, ok := strings.Cut(target, "/"); !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+				return fmt.Errorf("Matrix target %q must be GOOS/GOARCH, e.g. linux/arm64", target)
+			}
+		}
+	}
+	if pp.MaxDuration < 0 {
+		return fmt.Errorf("MaxDuration must not be negative")
+	}
+	if pp.Repeat && pp.Interval <= 0 {
+		return fmt.Errorf("Interval must be positive when Repeat is set")
+	}
+	if pp.ContinueAsNewEvery < 0 {
+		return fmt.Errorf("ContinueAsNewEvery must not be negative")
+	}
+	if pp.Bisect && pp.CommitRange == "" {
+		return fmt.Errorf("Bisect requires CommitRange")
+	}
+	if pp.BisectMaxSteps < 0 {
+		return fmt.Errorf("BisectMaxSteps must not be negative")
+	}
+	if err := validateCustomSteps(pp.CustomSteps); err != nil {
+		return err
+	}
+	if _, ok := lintSeverities[pp.LintFailSeverity]; pp.LintFailSeverity != "" && !ok {
+		return fmt.Errorf("LintFailSeverity %q is not one of info, warning, error", pp.LintFailSeverity)
+	}
+	seen := map[string]bool{}
+	for _, env := range pp.DeployEnvironments {
+		if env.Name == "" {
+			return fmt.Errorf("DeployEnvironments name is required")
+		}
+		if seen[env.Name] {
+			return fmt.Errorf("DeployEnvironments name %q is duplicated", env.Name)
+		}
+		seen[env.Name] = true
+		// Without a command, GoDeploy would simulate the deploy and report it succeeded.
+		if len(env.Command) == 0 {
+			return fmt.Errorf("DeployEnvironments %q command is required", env.Name)
+		}
+		if env.RequireApproval && pp.approvalTimeout() >= pp.maxDuration() {
+			return fmt.Errorf("ApprovalTimeout %s must be shorter than the pipeline's MaxDuration %s", pp.approvalTimeout(), pp.maxDuration())
+		}
+	}
+	if len(pp.DeployEnvironments) > 0 && len(pp.DeployServices) > 0 {
+		return fmt.Errorf("DeployEnvironments and DeployServices cannot both be set")
+	}
+	if err := validateDeployServices(pp.DeployServices); err != nil {
+		return err
+	}
+	if pp.K8s != nil && (len(pp.DeployCommand) > 0 || len(pp.DeployEnvironments) > 0 || len(pp.DeployServices) > 0) {
+		return fmt.Errorf("K8s cannot be used with DeployCommand, DeployEnvironments or DeployServices")
+	}
+	if err := pp.K8s.validate(); err != nil {
+		return err
+	}
+	if pp.AutoRollback && pp.K8s == nil {
+		return fmt.Errorf("AutoRollback requires K8s")
+	}
+	for _, pattern := range pp.Branches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Branches pattern %q: %w", pattern, err)
+		}
+	}
+	if _, err := parseNotifyTemplate(pp.NotifyTemplate); err != nil {
+		return err
+	}
+	if err := pp.NotifyEmail.validate(); err != nil {
+		return err
+	}
+	if err := validateCallback(pp.CallbackURL, pp.CallbackHeaders); err != nil {
+		return err
+	}
+	if err := pp.Sign.validate(); err != nil {
+		return err
+	}
+	if pp.ApprovalTimeout < 0 {
+		return fmt.Errorf("ApprovalTimeout must not be negative")
+	}
+	if pp.DeployTimeout < 0 {
+		return fmt.Errorf("DeployTimeout must not be negative")
+	}
+	if pp.RequireApproval && pp.approvalTimeout() >= pp.maxDuration() {
+		return fmt.Errorf("ApprovalTimeout %s must be shorter than the pipeline's MaxDuration %s", pp.approvalTimeout(), pp.maxDuration())
+	}
+	if pp.DeployGate != nil && pp.DeployGate.URL == "" {
+		return fmt.Errorf("DeployGate url is required")
+	}
+	if err := pp.GitHub.validate(); err != nil {
+		return err
+	}
+	if pp.Incident != nil && pp.Incident.RoutingKey == "" {
+		return fmt.Errorf("Incident routing_key is required")
+	}
 	return nil
 }
 
 type PipelineResult struct {
 	Failures []PipelineFailure `json:"failures"`
+	// StartedAt is when this run of the pipeline started, later than the workflow's start for
+	// the runs of a Repeat pipeline after the first.
+	StartedAt time.Time `json:"started_at"`
+	// Skipped explains why the pipeline didn't run, e.g. because of PipelineParams.Branches.
+	Skipped string `json:"skipped,omitempty"`
+	// DeploySkipped explains why the deploy didn't run although the checks passed, e.g. a closed
+	// PipelineParams.DeployGate.
+	DeploySkipped string `json:"deploy_skipped,omitempty"`
+	// Deployments records the outcome of each environment of a multi-environment deploy.
+	Deployments []DeploymentResult `json:"deployments,omitempty"`
+	// Services records the outcome of each service of a deploy plan.
+	Services []ServiceDeployment `json:"services,omitempty"`
+	// K8sDeploy records the applied resources and their rollouts, when PipelineParams.K8s is set.
+	K8sDeploy *K8sDeployResult `json:"k8s_deploy,omitempty"`
+	// Rollback records the rollback of a failed K8s deploy, when PipelineParams.AutoRollback is set.
+	Rollback *RollbackResult `json:"rollback,omitempty"`
+	// Signatures has the cosign signature of each image of PipelineParams.Sign.
+	Signatures []ArtifactSignature `json:"signatures,omitempty"`
+	// Tests holds the parsed GoTest result, used to render reports such as TAP.
+	Tests *GoTestResult `json:"tests,omitempty"`
+	// Benchmarks holds the GoBenchmark results, when benchmarks were run.
+	Benchmarks []BenchmarkResult `json:"benchmarks,omitempty"`
+	// MatrixResults has one cell per combination of PipelineParams.Matrix.
+	MatrixResults []MatrixCellResult `json:"matrix_results,omitempty"`
+	// Commits has the outcome of each commit checked, when PipelineParams.CommitRange is set.
+	Commits []CommitResult `json:"commits,omitempty"`
+	// FirstBadCommit is the first commit of the range whose checks failed.
+	FirstBadCommit string `json:"first_bad_commit,omitempty"`
+	// Logs holds the captured output of each check, keyed by its step name in the progress.
+	Logs map[string]string `json:"logs,omitempty"`
+	// Timings has how long each step took: the command's own run time as measured by a check
+	// activity, otherwise the step's duration in the workflow. Steps that didn't run are omitted.
+	Timings map[string]time.Duration `json:"timings,omitempty"`
+	// Steps has the final state and duration of each step of the pipeline, in execution order.
+	Steps []StepProgress `json:"steps,omitempty"`
+	// Summary is set when the result was too large to return in full and details were dropped.
+	Summary *ResultSummary `json:"summary,omitempty"`
+	// CommitSHA is the commit checked out, of the first repository in a multi-repo pipeline.
+	CommitSHA string `json:"commit_sha,omitempty"`
+	// WorkdirBytes is the disk space the run's workdir used, measured just before cleanup.
+	WorkdirBytes int64 `json:"workdir_bytes,omitempty"`
 }
 
+// AbortSignalName is the signal that cancels the in-flight checks of a PipelineWorkflow and
+// skips its deploy. The workdir is still cleaned up.
+const AbortSignalName = "abort"
+
 type PipelineFailure struct {
+	// Repo is the subdir of the repository that failed, in a multi-repo pipeline.
+	Repo     string `json:"repo,omitempty"`
 	Activity string `json:"activity"`
-	Details  any    `json:"details"`
+	// Kind tells what failed: one of FailureIssues, FailureError, FailureTimeout or
+	// FailureAborted.
+	Kind    string `json:"kind"`
+	Details any    `json:"details"`
+}
+
+// Kinds of PipelineFailure.
+const (
+	// FailureIssues is a step that ran and found problems, e.g. failing tests, lint issues, or a
+	// command exiting with a non-zero status.
+	FailureIssues = "issues"
+	// FailureError is an activity that couldn't run to completion, e.g. that crashed or ran out
+	// of retries.
+	FailureError = "error"
+	// FailureTimeout is an activity that timed out.
+	FailureTimeout = "timeout"
+	// FailureAborted is a pipeline aborted by AbortSignalName.
+	FailureAborted = "aborted"
+)
+
+// errorFailure is the failure of an activity that returned err instead of a result.
+func errorFailure(activity string, err error) PipelineFailure {
+	kind := FailureError
+	if temporal.IsTimeoutError(err) {
+		kind = FailureTimeout
+	}
+	return PipelineFailure{Activity: activity, Kind: kind, Details: err.Error()}
+}
+
+// combinedKind is the kind of a failure made up of failures, e.g. a commit's checks: an error
+// or timeout in any of them outweighs the issues found by the others.
+func combinedKind(failures []PipelineFailure) string {
+	kind := FailureIssues
+	for _, failure := range failures {
+		switch failure.Kind {
+		case FailureError:
+			return FailureError
+		case FailureTimeout:
+			kind = FailureTimeout
+		}
+	}
+	return kind
 }
 
 var pa = PipelineActivity{}
 
+// HeavyTaskQueue is the task queue for resource-intensive activities (build, test, lint) of
+// workflows running on queue. Workers poll it with a separate concurrency limit.
+func HeavyTaskQueue(queue string) string {
+	return queue + "-heavy"
+}
+
+// PipelineFailedErrorType is the type of the application error a PipelineWorkflow fails with when
+// PipelineParams.FailWorkflowOnChecks is set. Its details are the PipelineResult.
+const PipelineFailedErrorType = "PipelineFailed"
+
+// DefaultContinueAsNewEvery is how many runs a repeating PipelineWorkflow does before continuing
+// as new, when PipelineParams.ContinueAsNewEvery is zero.
+const DefaultContinueAsNewEvery = 10
+
 func PipelineWorkflow(ctx workflow.Context, params PipelineParams) (*PipelineResult, error) {
-	result := &PipelineResult{Failures: []PipelineFailure{}}
+	if !params.Repeat {
+		result, err := runPipeline(ctx, params)
+		if err == nil && params.FailWorkflowOnChecks && len(result.Failures) > 0 {
+			return nil, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("pipeline failed with %d failure(s)", len(result.Failures)), PipelineFailedErrorType, nil, result)
+		}
+		return result, err
+	}
+
+	// Continuing as new keeps the history of a repeating pipeline bounded.
+	every := params.ContinueAsNewEvery
+	if every == 0 {
+		every = DefaultContinueAsNewEvery
+	}
+	logger := workflow.GetLogger(ctx)
+	for i := 0; i < every; i++ {
+		if _, err := runPipeline(ctx, params); err != nil {
+			// A broken run shouldn't stop the loop; the next one starts from a fresh clone.
+			logger.Error("Pipeline run failed", "error", err)
+		}
+		if err := workflow.Sleep(ctx, params.Interval); err != nil {
+			return nil, err
+		}
+	}
+	return nil, workflow.NewContinueAsNewError(ctx, PipelineWorkflow, params)
+}
+
+// runPipeline clones the repository, runs the checks, and deploys once.
+func runPipeline(ctx workflow.Context, params PipelineParams) (_ *PipelineResult, err error) {
+	result := &PipelineResult{
+		Failures:  []PipelineFailure{},
+		StartedAt: workflow.Now(ctx),
+		Logs:      map[string]string{},
+		Timings:   map[string]time.Duration{},
+	}
+
+	progress := newPipelineProgress(params)
+	progress.now = func() time.Time { return workflow.Now(ctx) }
+	if err := workflow.SetQueryHandler(ctx, ProgressQueryName, func() (PipelineProgress, error) {
+		return *progress, nil
+	}); err != nil {
+		return nil, fmt.Errorf("registering %s query handler: %w", ProgressQueryName, err)
+	}
 
 	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
 		StartToCloseTimeout: 10 * time.Second,
-		RetryPolicy: &temporal.RetryPolicy{
-			MaximumAttempts: 3,
-		},
+		RetryPolicy:         newRetryPolicy(params.Retry),
 	})
 
-	fClone := workflow.ExecuteActivity(ctx, pa.GitClone, GitCloneParams{
-		Remote: params.GitURL,
+	if !branchConfigured(params.Branches, params.Ref) {
+		workflow.GetLogger(ctx).Info("Skipping pipeline, branch not configured", "ref", params.Ref)
+		result.Skipped = "skipped: branch not configured"
+		progress.skipPending()
+		result.Steps = progress.Steps
+		return result, nil
+	}
+
+	// Past MaxDuration, cancel the checks and the approval wait rather than time the workflow out,
+	// so the pipeline still deletes its workdir and reports. The deploy isn't interrupted.
+	limitCtx, cancelLimit := workflow.WithCancel(ctx)
+	defer cancelLimit()
+	timerCtx, stopTimer := workflow.WithCancel(ctx)
+	defer stopTimer()
+	workflow.Go(timerCtx, func(timerCtx workflow.Context) {
+		if err := workflow.NewTimer(timerCtx, params.maxDuration()).Get(timerCtx, nil); err != nil {
+			return
+		}
+		workflow.GetLogger(ctx).Info("Pipeline exceeded its MaxDuration, cancelling the remaining checks", "max_duration", params.maxDuration())
+		result.Failures = append(result.Failures, PipelineFailure{
+			Activity: "MaxDuration",
+			Kind:     FailureTimeout,
+			Details:  fmt.Sprintf("pipeline exceeded its MaxDuration of %s", params.maxDuration()),
+		})
+		cancelLimit()
 	})
-	rClone := &GitCloneResult{}
-	if err := fClone.Get(ctx, rClone); err != nil {
-		return nil, fmt.Errorf("GitClone activity: %w", err)
+
+	// Fail up front rather than midway through the clone when the disk is nearly full.
+	if params.MinFreeBytes > 0 {
+		progress.set("CheckDiskSpace", StepRunning)
+		err := workflow.ExecuteActivity(ctx, pa.CheckDiskSpace, CheckDiskSpaceParams{
+			WorkdirRoot:  params.WorkdirRoot,
+			MinFreeBytes: params.MinFreeBytes,
+		}).Get(ctx, nil)
+		progress.finish("CheckDiskSpace", err)
+		if err != nil {
+			return nil, fmt.Errorf("CheckDiskSpace activity: %w", err)
+		}
+	}
+
+	repos := params.RepoSpecs()
+	var metadata PipelineActivityMetadata
+	defer func() {
+		// A cancelled pipeline never gets to finishPipeline, e.g. when its worker died and it was
+		// cancelled to clean up, so delete the workdir on the way out. It exists once the first
+		// clone or the tarball fetch returns; a failed one removes the workdir it created.
+		if ctx.Err() != nil && metadata.Workdir != "" {
+			disconnectedCtx, _ := workflow.NewDisconnectedContext(ctx)
+			if err := workflow.ExecuteActivity(disconnectedCtx, pa.DeleteWorkdir, DeleteWorkdirParams{
+				Metadata:    metadata,
+				WorkdirRoot: params.WorkdirRoot,
+			}).Get(disconnectedCtx, nil); err != nil {
+				workflow.GetLogger(ctx).Warn("DeleteWorkdir failed after cancellation", "error", err)
+			}
+		}
+	}()
+
+	if params.TarballURL != "" {
+		progress.set("FetchTarball", StepRunning)
+		rFetch := &FetchTarballResult{}
+		err := workflow.ExecuteActivity(ctx, pa.FetchTarball, FetchTarballParams{
+			URL:             params.TarballURL,
+			StripComponents: params.TarballStripComponents,
+			Dir:             params.Subdir,
+			WorkdirRoot:     params.WorkdirRoot,
+		}).Get(ctx, rFetch)
+		progress.finish("FetchTarball", err)
+		if err != nil {
+			return nil, fmt.Errorf("FetchTarball activity: %w", err)
+		}
+		metadata = rFetch.Metadata
+	} else {
+		// The first clone creates the workdir; every other repository is cloned into it.
+		progress.set("GitClone", StepRunning)
+		cloneCtx := workflow.WithHeartbeatTimeout(workflow.WithStartToCloseTimeout(ctx, cloneTimeout), heartbeatTimeout)
+		for i, repo := range repos {
+			rClone := &GitCloneResult{}
+			err := workflow.ExecuteActivity(cloneCtx, pa.GitClone, GitCloneParams{
+				Metadata:    metadata,
+				Remote:      repo.URL,
+				Ref:         repo.Ref,
+				Subdir:      repo.Subdir,
+				Dir:         repo.Dir,
+				WorkdirRoot: params.WorkdirRoot,
+				CACertPath:  params.GitCACertPath,
+				Insecure:    params.GitInsecure,
+			}).Get(cloneCtx, rClone)
+			if err != nil {
+				progress.finish("GitClone", err)
+				return nil, fmt.Errorf("GitClone activity: %w", err)
+			}
+			metadata = rClone.Metadata
+			if i == 0 {
+				result.CommitSHA = rClone.CommitSHA
+			}
+		}
+		progress.finish("GitClone", nil)
+	}
+
+	setGitHubStatus(ctx, params, result.CommitSHA, GitHubStatePending, "Pipeline running")
+	defer func() {
+		// Don't leave the status pending when the pipeline errors out, or is cancelled.
+		if err != nil {
+			disconnectedCtx, _ := workflow.NewDisconnectedContext(ctx)
+			setGitHubStatus(disconnectedCtx, params, result.CommitSHA, GitHubStateError, "Pipeline errored")
+		}
+	}()
+	// Repository config, benchmarks, and the build matrix use the first repository.
+	primary := repoMetadata(metadata, repos[0])
+
+	// Merge the repository's own config, if any. Params passed to the workflow take precedence.
+	progress.set("ReadRepoConfig", StepRunning)
+	rConfig := &ReadRepoConfigResult{}
+	err = workflow.ExecuteActivity(ctx, pa.ReadRepoConfig, ReadRepoConfigParams{Metadata: primary}).Get(ctx, rConfig)
+	progress.finish("ReadRepoConfig", err)
+	if err != nil {
+		return nil, fmt.Errorf("ReadRepoConfig activity: %w", err)
+	}
+	if rConfig.Found {
+		params = mergeRepoConfig(params, rConfig.Config)
+	}
+
+	// Variables from the repository's env file apply below the explicitly configured Env.
+	progress.set("ReadEnvFile", StepRunning)
+	rEnvFile := &ReadEnvFileResult{}
+	err = workflow.ExecuteActivity(ctx, pa.ReadEnvFile, ReadEnvFileParams{Metadata: primary, Path: params.EnvFile}).Get(ctx, rEnvFile)
+	progress.finish("ReadEnvFile", err)
+	if err != nil {
+		return nil, fmt.Errorf("ReadEnvFile activity: %w", err)
+	}
+	if rEnvFile.Found {
+		metadata.EnvFile = rEnvFile.Path
+		workflow.GetLogger(ctx).Info("Adding env file to Go commands", "path", rEnvFile.Path, "keys", rEnvFile.Keys)
+	}
+	metadata.Env = params.Env
+	if len(metadata.Env) > 0 {
+		workflow.GetLogger(ctx).Info("Adding environment to Go commands", "env", redactEnv(metadata.Env))
+	}
+	primary = repoMetadata(metadata, repos[0])
+
+	// Populate the shared module cache once, so the parallel Go activities don't each fetch modules.
+	if !params.NoCache {
+		progress.set("GoModDownload", StepRunning)
+		downloadCtx := workflow.WithStartToCloseTimeout(ctx, commandTimeout)
+		var downloadErr error
+		for _, repo := range repos {
+			rDownload := &GoModDownloadResult{}
+			err := workflow.ExecuteActivity(downloadCtx, pa.GoModDownload, GoModDownloadParams{Metadata: repoMetadata(metadata, repo)}).Get(ctx, rDownload)
+			if err != nil {
+				// Not fatal: the Go activities download what they need and report their own errors.
+				workflow.GetLogger(ctx).Warn("GoModDownload failed, continuing without the module cache", "error", err)
+				downloadErr = err
+				continue
+			}
+			metadata.GoModCache = rDownload.Metadata.GoModCache
+		}
+		progress.finish("GoModDownload", downloadErr)
+		primary = repoMetadata(metadata, repos[0])
 	}
 
-	metadata := rClone.Metadata
+	if len(params.Tools) > 0 {
+		progress.set("GoInstallTools", StepRunning)
+		rTools := &GoInstallToolsResult{}
+		installCtx := workflow.WithHeartbeatTimeout(workflow.WithStartToCloseTimeout(ctx, installTimeout), heartbeatTimeout)
+		err := workflow.ExecuteActivity(installCtx, pa.GoInstallTools, GoInstallToolsParams{Metadata: metadata, Tools: params.Tools}).Get(ctx, rTools)
+		progress.finish("GoInstallTools", err)
+		if err != nil {
+			// Keep going: the checks that need a missing tool report it themselves.
+			result.Failures = append(result.Failures, errorFailure("GoInstallTools", err))
+		} else {
+			metadata.BinDir = rTools.Metadata.BinDir
+			primary = repoMetadata(metadata, repos[0])
+		}
+	}
+
+	// Limit the tests and build to the packages the changes affect, e.g. those of a pull request.
+	var packages []string
+	if params.DiffBase != "" {
+		progress.set("DetectChanges", StepRunning)
+		rChanges := &DetectChangesResult{}
+		err := workflow.ExecuteActivity(ctx, pa.DetectChanges, DetectChangesParams{Metadata: primary, Base: params.DiffBase, CACertPath: params.GitCACertPath, Insecure: params.GitInsecure}).Get(ctx, rChanges)
+		progress.finish("DetectChanges", err)
+		if err != nil {
+			return nil, fmt.Errorf("DetectChanges activity: %w", err)
+		}
+		packages = rChanges.Packages
+	}
+
+	// A commit range replaces the checks and the deploy with checking each commit of the range.
+	if params.CommitRange != "" {
+		progress.set("CommitRange", StepRunning)
+		stopTimer()
+		if err := checkCommitRange(ctx, params, metadata, result); err != nil {
+			progress.finish("CommitRange", err)
+			return nil, err
+		}
+		progress.set("CommitRange", stepStateFor(result))
+		if err := finishPipeline(ctx, params, metadata, result, progress); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	// The checks share a cancellable context, so an abort signal or FailFast can stop them early.
+	checksCtx, cancelChecks := workflow.WithCancel(limitCtx)
+	defer cancelChecks()
+
+	// Resource-intensive activities run on their own task queue with a separate slot count.
+	heavyCtx := workflow.WithTaskQueue(checksCtx, HeavyTaskQueue(workflow.GetInfo(ctx).TaskQueueName))
+
+	activities := startChecks(checksCtx, heavyCtx, params, metadata, repos, packages)
 
-	// Define activities to run in parallel
-	activities := []struct {
-		name   string
-		future workflow.Future
-	}{
-		{"GoTest", workflow.ExecuteActivity(ctx, pa.GoTest, GoTestParams{Metadata: metadata, Flags: params.TestFlags})},
-		{"GoFmt", workflow.ExecuteActivity(ctx, pa.GoFmt, GoFmtParams{Metadata: metadata})},
-		{"GoModTidy", workflow.ExecuteActivity(ctx, pa.GoModTidy, GoModTidyParams{Metadata: metadata})},
-		{"GoBuild", workflow.ExecuteActivity(ctx, pa.GoBuild, GoBuildParams{Metadata: metadata, Flags: params.BuildFlags})},
-		{"GoGenerate", workflow.ExecuteActivity(ctx, pa.GoGenerate, GoGenerateParams{Metadata: metadata, Flags: params.GenerateFlags})},
-		{"GolangCILint", workflow.ExecuteActivity(ctx, pa.GolangCILint, GolangCILintParams{Metadata: metadata})},
+	var matrix []matrixCell
+	if params.Matrix != nil {
+		progress.set("Matrix", StepRunning)
+		matrix = startMatrix(heavyCtx, *params.Matrix, GoBuildParams{Metadata: primary, Flags: params.BuildFlags, Tags: params.BuildTags})
 	}
 
+	// Failures are collected per activity as each completes, and appended in slice order below
+	// so the result doesn't depend on completion order.
+	failures := make([][]PipelineFailure, len(activities))
+	remaining := len(activities)
+	aborted := false
+
 	// Create a selector to wait for all activities
 	selector := workflow.NewSelector(ctx)
 	for i := range activities {
 		activity := activities[i]
+		progress.set(activity.step(), StepRunning)
 		selector.AddFuture(activity.future, func(f workflow.Future) {
-			// This function will be called when the future is ready
+			remaining--
+			if err := f.Get(ctx, nil); temporal.IsCanceledError(err) {
+				progress.set(activity.step(), StepSkipped)
+				return
+			}
+			report := activityReport(ctx, f)
+			if report.Logs != "" {
+				result.Logs[activity.step()] = report.Logs
+			}
+			if report.ElapsedMs > 0 {
+				result.Timings[activity.step()] = time.Duration(report.ElapsedMs) * time.Millisecond
+			}
+			failures[i] = checkFailures(ctx, activity.name, f, params, result)
+			for j := range failures[i] {
+				failures[i][j].Repo = activity.repo
+			}
+			if len(failures[i]) == 0 {
+				progress.set(activity.step(), StepDone)
+				return
+			}
+			progress.set(activity.step(), StepFailed)
+			if params.FailFast {
+				workflow.GetLogger(ctx).Info("Check failed, cancelling the remaining checks", "activity", activity.name)
+				cancelChecks()
+			}
 		})
 	}
+	abortCh := workflow.GetSignalChannel(ctx, AbortSignalName)
+	selector.AddReceive(abortCh, func(c workflow.ReceiveChannel, more bool) {
+		c.Receive(ctx, nil)
+		workflow.GetLogger(ctx).Info("Abort signal received, cancelling the remaining checks")
+		aborted = true
+		cancelChecks()
+	})
 
 	// Wait for all activities to complete
-	for i := 0; i < len(activities); i++ {
+	for remaining > 0 {
 		selector.Select(ctx)
 	}
 
-	// Process results
-	for _, activity := range activities {
-		var err error
-		switch activity.name {
-		case "GoTest":
-			var rTest GoTestResult
-			err = activity.future.Get(ctx, &rTest)
-			if err == nil && len(rTest.FailedTests) > 0 {
-				result.Failures = append(result.Failures, PipelineFailure{Activity: activity.name, Details: rTest.FailedTests})
-			}
-		case "GoFmt":
-			var rFmt GoFmtResult
-			err = activity.future.Get(ctx, &rFmt)
-			if err == nil && len(rFmt.FailedFiles) > 0 {
-				result.Failures = append(result.Failures, PipelineFailure{Activity: activity.name, Details: rFmt.FailedFiles})
-			}
-		case "GoModTidy":
-			var rModTidy GoModTidyResult
-			err = activity.future.Get(ctx, &rModTidy)
-			if err == nil && len(rModTidy.FailedFiles) > 0 {
-				result.Failures = append(result.Failures, PipelineFailure{Activity: activity.name, Details: rModTidy.FailedFiles})
-			}
-		case "GoBuild":
-			var rBuild GoBuildResult
-			err = activity.future.Get(ctx, &rBuild)
-			if err == nil && len(rBuild.FailedFiles) > 0 {
-				result.Failures = append(result.Failures, PipelineFailure{Activity: activity.name, Details: rBuild.FailedFiles})
-			}
-		case "GoGenerate":
-			var rGenerate GoGenerateResult
-			err = activity.future.Get(ctx, &rGenerate)
-			if err == nil && len(rGenerate.FailedFiles) > 0 {
-				result.Failures = append(result.Failures, PipelineFailure{Activity: activity.name, Details: rGenerate.FailedFiles})
-			}
-		case "GolangCILint":
-			var rLint GolangCILintResult
-			err = activity.future.Get(ctx, &rLint)
-			if err == nil && len(rLint.Issues) > 0 {
-				result.Failures = append(result.Failures, PipelineFailure{Activity: activity.name, Details: rLint.Issues})
+	for _, activityFailures := range failures {
+		result.Failures = append(result.Failures, activityFailures...)
+	}
+	if params.Matrix != nil {
+		result.MatrixResults = collectMatrix(ctx, matrix, params, result)
+		progress.set("Matrix", StepDone)
+		for _, cell := range result.MatrixResults {
+			if cell.Status == MatrixFailed {
+				result.Failures = append(result.Failures, PipelineFailure{Activity: "Matrix", Kind: combinedKind(cell.Failures), Details: cell})
+				progress.set("Matrix", StepFailed)
 			}
 		}
+	}
+	// An abort signalled after the checks finished still skips the deploy.
+	if aborted || abortCh.ReceiveAsync(nil) {
+		result.Failures = append(result.Failures, abortFailure)
+	}
+
+	// Verify the deploy can proceed, e.g. with a dry run, before touching any environment.
+	if !hasErrors(result) && len(params.PreDeployCommand) > 0 {
+		progress.set("PreDeploy", StepRunning)
+		rPreDeploy := &PreDeployResult{}
+		err := workflow.ExecuteActivity(workflow.WithStartToCloseTimeout(ctx, commandTimeout), pa.PreDeploy, PreDeployParams{
+			Metadata: metadata,
+			Command:  params.PreDeployCommand,
+			Env:      params.DeployEnv,
+		}).Get(ctx, rPreDeploy)
 		if err != nil {
-			result.Failures = append(result.Failures, PipelineFailure{Activity: activity.name, Details: err.Error()})
+			result.Failures = append(result.Failures, errorFailure("PreDeploy", err))
+		} else if !rPreDeploy.Success {
+			result.Failures = append(result.Failures, PipelineFailure{Activity: "PreDeploy", Kind: FailureIssues, Details: rPreDeploy})
 		}
+		progress.set("PreDeploy", stepStateFor(result))
+	} else if len(params.PreDeployCommand) > 0 {
+		progress.set("PreDeploy", StepSkipped)
+	}
+
+	if !hasErrors(result) && params.Sign != nil {
+		signArtifacts(ctx, params, metadata, result, progress)
+	} else if params.Sign != nil {
+		progress.set("Sign", StepSkipped)
+	}
+
+	// An external gate, e.g. a deploy freeze, can hold back a deploy that is otherwise ready.
+	if !hasErrors(result) && params.DeployGate != nil {
+		progress.set("DeployGate", StepRunning)
+		rGate := &CheckDeployGateResult{}
+		err := workflow.ExecuteActivity(ctx, pa.CheckDeployGate, CheckDeployGateParams{Gate: *params.DeployGate}).Get(ctx, rGate)
+		switch {
+		case err != nil:
+			result.Failures = append(result.Failures, errorFailure("DeployGate", err))
+			progress.set("DeployGate", StepFailed)
+		case !rGate.Open:
+			workflow.GetLogger(ctx).Info("Deploy gate closed, skipping deploy", "reason", rGate.Reason)
+			result.DeploySkipped = rGate.Reason
+			progress.set("DeployGate", StepFailed)
+		default:
+			progress.set("DeployGate", StepDone)
+		}
+	} else if params.DeployGate != nil {
+		progress.set("DeployGate", StepSkipped)
+	}
+
+	if !hasErrors(result) && result.DeploySkipped == "" && params.RequireApproval {
+		waitForApproval(limitCtx, params, abortCh, result, progress)
+	} else if params.RequireApproval {
+		progress.set("Approval", StepSkipped)
+	}
+
+	// The approvals of a promotion through DeployEnvironments are bounded by MaxDuration too.
+	if len(params.DeployEnvironments) == 0 {
+		stopTimer()
 	}
 
 	// If all checks pass, execute deploy
-	if !hasErrors(result) {
-		fDeploy := workflow.ExecuteActivity(ctx, pa.GoDeploy, GoDeployParams{Metadata: metadata})
-		rDeploy := &GoDeployResult{}
-		if err := fDeploy.Get(ctx, rDeploy); err != nil {
+	if result.DeploySkipped != "" {
+		progress.set("Deploy", StepSkipped)
+	} else if !hasErrors(result) && params.K8s != nil {
+		progress.set("Deploy", StepRunning)
+		rK8s, err := deployK8s(ctx, params, metadata)
+		if err != nil {
+			return nil, fmt.Errorf("K8sDeploy activity: %w", err)
+		}
+		result.K8sDeploy = rK8s
+		if !rK8s.Success {
+			result.Failures = append(result.Failures, PipelineFailure{
+				Activity: "Deploy",
+				Kind:     FailureIssues,
+				Details:  rK8s.ErrorMsg,
+			})
+		}
+		progress.set("Deploy", stepStateFor(result))
+		if !rK8s.Success && params.AutoRollback {
+			rollbackK8s(ctx, params, metadata, result, progress)
+		}
+	} else if !hasErrors(result) && len(params.DeployEnvironments) > 0 {
+		progress.set("Deploy", StepRunning)
+		result.Deployments = deployEnvironments(ctx, limitCtx, params, metadata, abortCh, result)
+		for _, deployment := range result.Deployments {
+			if deployment.Status == DeploymentFailed {
+				result.Failures = append(result.Failures, PipelineFailure{
+					Activity: "Deploy",
+					Kind:     FailureIssues,
+					Details:  deployment,
+				})
+			}
+		}
+		progress.set("Deploy", stepStateFor(result))
+	} else if !hasErrors(result) && len(params.DeployServices) > 0 {
+		progress.set("Deploy", StepRunning)
+		result.Services = deployServices(ctx, params, metadata)
+		for _, service := range result.Services {
+			if service.Status == DeploymentFailed {
+				result.Failures = append(result.Failures, PipelineFailure{
+					Activity: "Deploy",
+					Kind:     FailureIssues,
+					Details:  service,
+				})
+			}
+		}
+		progress.set("Deploy", stepStateFor(result))
+	} else if !hasErrors(result) {
+		progress.set("Deploy", StepRunning)
+		rDeploy, err := runDeploy(ctx, params, GoDeployParams{
+			Metadata:      metadata,
+			DeployCommand: params.DeployCommand,
+			DeployEnv:     params.DeployEnv,
+		}, "")
+		if err != nil {
 			return nil, fmt.Errorf("deploy activity: %w", err)
 		}
-		if rDeploy.Error != nil {
+		if rDeploy.ErrorMsg != "" {
 			result.Failures = append(result.Failures, PipelineFailure{
 				Activity: "Deploy",
-				Details:  rDeploy.Error,
+				Kind:     FailureIssues,
+				Details:  rDeploy.ErrorMsg,
 			})
 		}
+		progress.set("Deploy", stepStateFor(result))
+	} else {
+		progress.set("Deploy", StepSkipped)
+	}
+
+	// Nothing to roll back when the deploy didn't run, succeeded, or only created workloads.
+	progress.skip("Rollback")
+
+	if params.Incident != nil && deployFailed(*params.Incident, result) {
+		triggerIncident(ctx, params, result)
+	}
+
+	if err := finishPipeline(ctx, params, metadata, result, progress); err != nil {
+		return nil, err
+	}
+	// Workflow code logs through the workflow logger, which skips the messages while replaying
+	// history, rather than slog or fmt, which would repeat them on every replay.
+	workflow.GetLogger(ctx).Info("Pipeline finished", "failures", len(result.Failures), "commit", result.CommitSHA)
+	return result, nil
+}
+
+// branchConfigured reports whether ref matches one of the branch patterns. Full ref names like
+// refs/heads/main are matched by branch name.
+func branchConfigured(patterns []string, ref string) bool {
+	if len(patterns) == 0 || ref == "" {
+		return true
+	}
+	branch := strings.TrimPrefix(ref, "refs/heads/")
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// startChecks starts the check activities of every repository in parallel. The resource-intensive
+// ones run on heavyCtx.
+func startChecks(ctx, heavyCtx workflow.Context, params PipelineParams, metadata PipelineActivityMetadata, repos []RepoSpec, packages []string) []checkActivity {
+	testPackages := params.TestPackages
+	if len(packages) > 0 {
+		testPackages = packages
+	}
+	commandCtx := workflow.WithStartToCloseTimeout(heavyCtx, commandTimeout)
+	testCtx := heavyCtx
+	if params.RetryFailedTests > 0 {
+		// Each retry runs the failed tests again.
+		testCtx = commandCtx
+	}
+
+	var activities []checkActivity
+	for _, repo := range repos {
+		repoMeta := repoMetadata(metadata, repo)
+		activities = append(activities,
+			checkActivity{"GoTest", repo.Subdir, workflow.ExecuteActivity(testCtx, pa.GoTest, GoTestParams{Metadata: repoMeta, Flags: params.TestFlags, Tags: params.BuildTags, Race: params.Race, Packages: testPackages, TestP: params.TestP, TestParallel: params.TestParallel, RetryFailedTests: params.RetryFailedTests})},
+			checkActivity{"GoFmt", repo.Subdir, workflow.ExecuteActivity(ctx, pa.GoFmt, GoFmtParams{Metadata: repoMeta, CheckOnly: params.FmtCheckOnly, Formatter: params.Formatter})},
+			checkActivity{"GoModTidy", repo.Subdir, workflow.ExecuteActivity(ctx, pa.GoModTidy, GoModTidyParams{Metadata: repoMeta})},
+			checkActivity{"GoModVerify", repo.Subdir, workflow.ExecuteActivity(ctx, pa.GoModVerify, GoModVerifyParams{Metadata: repoMeta})},
+			checkActivity{"GoBuild", repo.Subdir, workflow.ExecuteActivity(heavyCtx, pa.GoBuild, GoBuildParams{Metadata: repoMeta, Flags: params.BuildFlags, Tags: params.BuildTags, Packages: packages})},
+			checkActivity{"GoGenerate", repo.Subdir, workflow.ExecuteActivity(ctx, pa.GoGenerate, GoGenerateParams{Metadata: repoMeta, Flags: params.GenerateFlags, Tags: params.BuildTags})},
+			checkActivity{"GolangCILint", repo.Subdir, workflow.ExecuteActivity(heavyCtx, pa.GolangCILint, GolangCILintParams{Metadata: repoMeta, Flags: params.LintFlags})},
+			checkActivity{"GoVulnCheck", repo.Subdir, workflow.ExecuteActivity(heavyCtx, pa.GoVulnCheck, GoVulnCheckParams{Metadata: repoMeta})},
+		)
+		if params.Staticcheck {
+			activities = append(activities, checkActivity{"GoStaticcheck", repo.Subdir, workflow.ExecuteActivity(heavyCtx, pa.GoStaticcheck, GoStaticcheckParams{Metadata: repoMeta})})
+		}
+		if len(params.AllowedLicenses) > 0 {
+			activities = append(activities, checkActivity{"GoLicenseCheck", repo.Subdir, workflow.ExecuteActivity(heavyCtx, pa.GoLicenseCheck, GoLicenseCheckParams{Metadata: repoMeta, Allowed: params.AllowedLicenses})})
+		}
+		if params.DocCheck {
+			activities = append(activities, checkActivity{"GoDocCheck", repo.Subdir, workflow.ExecuteActivity(ctx, pa.GoDocCheck, GoDocCheckParams{Metadata: repoMeta})})
+		}
+	}
+	if params.BenchmarkPattern != "" {
+		activities = append(activities, checkActivity{"GoBenchmark", repos[0].Subdir, workflow.ExecuteActivity(commandCtx, pa.GoBenchmark, GoBenchmarkParams{
+			Metadata: repoMetadata(metadata, repos[0]),
+			Pattern:  params.BenchmarkPattern,
+			Flags:    params.BenchmarkFlags,
+			Tags:     params.BuildTags,
+			Baseline: params.BenchmarkBaseline,
+		})})
+	}
+	for _, step := range params.CustomSteps {
+		activities = append(activities, checkActivity{step.Name, repos[0].Subdir, workflow.ExecuteActivity(commandCtx, pa.RunScript, RunScriptParams{
+			Metadata:            repoMetadata(metadata, repos[0]),
+			Name:                step.Name,
+			Command:             step.Command,
+			AllowOutsideWorkdir: step.AllowOutsideWorkdir,
+		})})
+	}
+	return activities
+}
+
+// finishPipeline measures and deletes the workdir, then sends the notifications.
+func finishPipeline(ctx workflow.Context, params PipelineParams, metadata PipelineActivityMetadata, result *PipelineResult, progress *PipelineProgress) error {
+	rMeasure := &MeasureWorkdirResult{}
+	if err := workflow.ExecuteActivity(ctx, pa.MeasureWorkdir, MeasureWorkdirParams{Metadata: metadata}).Get(ctx, rMeasure); err != nil {
+		// Only used for capacity planning, so it shouldn't fail the pipeline.
+		workflow.GetLogger(ctx).Warn("MeasureWorkdir failed", "error", err)
+	} else {
+		result.WorkdirBytes = rMeasure.Bytes
 	}
 
 	// Finally, workflow finished successfully. Clean up the directory.
+	progress.set("DeleteWorkdir", StepRunning)
 	fCleanup := workflow.ExecuteActivity(ctx, pa.DeleteWorkdir, DeleteWorkdirParams{
-		Metadata: metadata,
+		Metadata:    metadata,
+		WorkdirRoot: params.WorkdirRoot,
 	})
-	if err := fCleanup.Get(ctx, nil); err != nil {
-		return nil, fmt.Errorf("deleteWorkdir activity: %w", err)
+	err := fCleanup.Get(ctx, nil)
+	progress.finish("DeleteWorkdir", err)
+	if err != nil {
+		return fmt.Errorf("deleteWorkdir activity: %w", err)
 	}
 
-	fmt.Printf("==debug: result=%v", result)
+	sortFailures(result.Failures)
+	summarizeResult(ctx, result)
 
-	return result, nil
+	state, description := gitHubFinalStatus(result)
+	setGitHubStatus(ctx, params, result.CommitSHA, state, description)
+
+	if hasNotifications(params) {
+		progress.set("Notify", StepRunning)
+		progress.finish("Notify", notify(ctx, params, result))
+	}
+	result.Steps = progress.Steps
+	for _, step := range result.Steps {
+		if _, ok := result.Timings[step.Name]; !ok && step.Duration > 0 {
+			result.Timings[step.Name] = step.Duration
+		}
+	}
+	if params.ArchiveDSN != "" {
+		archiveResult(ctx, params, result)
+	}
+	return nil
+}
+
+// checkActivity is a check running in the fan-out, named after its activity.
+type checkActivity struct {
+	name string
+	// repo is the subdir of the repository checked, in a multi-repo pipeline.
+	repo   string
+	future workflow.Future
+}
+
+// step is the name of the check in the pipeline progress.
+func (ca checkActivity) step() string {
+	return checkStepName(ca.name, ca.repo)
+}
+
+func checkStepName(name, repo string) string {
+	if repo == "" {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, repo)
+}
+
+// repoMetadata returns metadata for running activities in repo's subdir of the workdir.
+func repoMetadata(metadata PipelineActivityMetadata, repo RepoSpec) PipelineActivityMetadata {
+	if repo.Subdir != "" || repo.Dir != "" {
+		metadata.Workdir = filepath.Join(metadata.Workdir, repo.Subdir, repo.Dir)
+	}
+	return metadata
+}
+
+// checkFailures reads the result of a completed check activity and returns the failures it
+// reports. GoTest results are also recorded in result.Tests.
+func checkFailures(ctx workflow.Context, name string, future workflow.Future, params PipelineParams, result *PipelineResult) []PipelineFailure {
+	var failures []PipelineFailure
+	var err error
+	switch name {
+	case "GoTest":
+		var rTest GoTestResult
+		err = future.Get(ctx, &rTest)
+		// The output is already in result.Logs; don't carry it twice.
+		rTest.Logs = ""
+		if err == nil && result.Tests == nil {
+			result.Tests = &rTest
+		} else if err == nil {
+			// A multi-repo pipeline reports the tests of every repository together.
+			if result.Tests.Summary != nil || rTest.Summary != nil {
+				a, b := testCounts(result.Tests), testCounts(&rTest)
+				result.Tests.Summary = &ResultSummary{
+					PassedTests:    a.PassedTests + b.PassedTests,
+					FailedTests:    a.FailedTests + b.FailedTests,
+					FlakyTests:     a.FlakyTests + b.FlakyTests,
+					FailedPackages: a.FailedPackages + b.FailedPackages,
+				}
+			}
+			result.Tests.PassedTests = append(result.Tests.PassedTests, rTest.PassedTests...)
+			result.Tests.FailedTests = append(result.Tests.FailedTests, rTest.FailedTests...)
+			result.Tests.FlakyTests = append(result.Tests.FlakyTests, rTest.FlakyTests...)
+			result.Tests.FailedPackages = append(result.Tests.FailedPackages, rTest.FailedPackages...)
+		}
+		if err == nil && len(rTest.FailedTests) > 0 {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rTest.FailedTests})
+		}
+		if err == nil && len(rTest.FailedPackages) > 0 {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rTest.FailedPackages})
+		}
+		if err == nil && rTest.Diagnostics != "" {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rTest.Diagnostics})
+		}
+	case "GoFmt":
+		var rFmt GoFmtResult
+		err = future.Get(ctx, &rFmt)
+		if err == nil && len(rFmt.FailedFiles) > 0 {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rFmt.FailedFiles})
+		}
+	case "GoModTidy":
+		var rModTidy GoModTidyResult
+		err = future.Get(ctx, &rModTidy)
+		if err == nil && len(rModTidy.FailedFiles) > 0 {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rModTidy.FailedFiles})
+		}
+	case "GoModVerify":
+		var rModVerify GoModVerifyResult
+		err = future.Get(ctx, &rModVerify)
+		if err == nil && len(rModVerify.FailedModules) > 0 {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rModVerify.FailedModules})
+		}
+	case "GoBuild":
+		var rBuild GoBuildResult
+		err = future.Get(ctx, &rBuild)
+		if err == nil && len(rBuild.FailedFiles) > 0 {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rBuild.FailedFiles})
+		}
+		if err == nil && rBuild.Diagnostics != "" {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rBuild.Diagnostics})
+		}
+	case "GoGenerate":
+		var rGenerate GoGenerateResult
+		err = future.Get(ctx, &rGenerate)
+		if err == nil && len(rGenerate.FailedFiles) > 0 {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rGenerate.FailedFiles})
+		}
+	case "GolangCILint":
+		var rLint GolangCILintResult
+		err = future.Get(ctx, &rLint)
+		if err == nil && hasFailingLintIssue(rLint.Issues, params.LintFailSeverity) {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rLint.Issues})
+		}
+	case "GoStaticcheck":
+		var rStaticcheck GoStaticcheckResult
+		err = future.Get(ctx, &rStaticcheck)
+		if err == nil && hasFailingLintIssue(rStaticcheck.Issues, params.LintFailSeverity) {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rStaticcheck.Issues})
+		}
+	case "GoLicenseCheck":
+		var rLicense GoLicenseCheckResult
+		err = future.Get(ctx, &rLicense)
+		if err == nil && len(rLicense.Forbidden) > 0 {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rLicense.Forbidden})
+		}
+		if err == nil && len(rLicense.Unknown) > 0 {
+			workflow.GetLogger(ctx).Warn("Dependencies with unidentified licenses", "modules", rLicense.Unknown)
+		}
+	case "GoDocCheck":
+		var rDoc GoDocCheckResult
+		err = future.Get(ctx, &rDoc)
+		minCoverage := params.DocMinCoverage
+		if minCoverage == 0 {
+			minCoverage = DefaultDocMinCoverage
+		}
+		if err == nil && rDoc.Coverage < minCoverage {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rDoc.Undocumented})
+		}
+	case "GoVulnCheck":
+		var rVuln GoVulnCheckResult
+		err = future.Get(ctx, &rVuln)
+		if err == nil && len(rVuln.Vulns) > 0 {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rVuln.Vulns})
+		}
+	case "GoBenchmark":
+		var rBenchmark GoBenchmarkResult
+		err = future.Get(ctx, &rBenchmark)
+		if err == nil {
+			result.Benchmarks = rBenchmark.Benchmarks
+		}
+		if err == nil && rBenchmark.Diagnostics != "" {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rBenchmark.Diagnostics})
+		}
+		if err == nil && len(rBenchmark.Baseline) > 0 {
+			threshold := params.BenchmarkRegressionPercent
+			if threshold == 0 {
+				threshold = DefaultBenchmarkRegressionPercent
+			}
+			if regressions := compareBenchmarks(rBenchmark.Baseline, rBenchmark.Benchmarks, threshold); len(regressions) > 0 {
+				failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: regressions})
+			}
+		}
+	default:
+		// A CustomStep, which can't take the name of a built-in check.
+		var rScript RunScriptResult
+		err = future.Get(ctx, &rScript)
+		if err == nil && !rScript.Success {
+			failures = append(failures, PipelineFailure{Activity: name, Kind: FailureIssues, Details: rScript.ErrorMsg})
+		}
+	}
+	if err != nil {
+		failures = append(failures, errorFailure(name, err))
+	}
+	return failures
+}
+
+// newRetryPolicy builds the default activity retry policy. Spreading retries out with a
+// larger backoff keeps correlated failures (e.g. a module proxy outage) from retrying in lockstep.
+func newRetryPolicy(params RetryParams) *temporal.RetryPolicy {
+	return &temporal.RetryPolicy{
+		InitialInterval:    params.InitialInterval,
+		BackoffCoefficient: params.BackoffCoefficient,
+		MaximumInterval:    params.MaximumInterval,
+		MaximumAttempts:    3,
+	}
+}
+
+// lintSeverities ranks lint severities from least to most severe.
+var lintSeverities = map[string]int{
+	"info":    1,
+	"warning": 2,
+	"error":   3,
+}
+
+// hasFailingLintIssue reports whether any issue meets or exceeds threshold. Issues with an
+// unknown or empty severity are treated as errors so they are never silently ignored.
+func hasFailingLintIssue(issues []LintIssue, threshold string) bool {
+	for _, issue := range issues {
+		if threshold == "" {
+			return true
+		}
+		severity, ok := lintSeverities[strings.ToLower(issue.Severity)]
+		if !ok {
+			severity = lintSeverities["error"]
+		}
+		if severity >= lintSeverities[threshold] {
+			return true
+		}
+	}
+	return false
+}
+
+// sortFailures orders failures by activity, then repository, for output that reads the same
+// from run to run. Failures of the same activity and repository keep their order.
+func sortFailures(failures []PipelineFailure) {
+	sort.SliceStable(failures, func(i, j int) bool {
+		if failures[i].Activity != failures[j].Activity {
+			return failures[i].Activity < failures[j].Activity
+		}
+		return failures[i].Repo < failures[j].Repo
+	})
 }
 
 func hasErrors(result *PipelineResult) bool {
@@ -1,11 +1,28 @@
 package pipeline
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
 )
 
 const (
@@ -13,14 +30,8 @@ const (
 )
 
 func TestPipelineWorkflow(t *testing.T) {
-	testSuite := &testsuite.WorkflowTestSuite{}
-	env := testSuite.NewTestWorkflowEnvironment()
-
-	// Mock GitClone and DeleteWorkdir for all tests
-	env.OnActivity(pa.GitClone, mock.Anything, mock.Anything).Return(&GitCloneResult{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}}, nil)
-	env.OnActivity(pa.DeleteWorkdir, mock.Anything, mock.Anything).Return(nil)
-
 	t.Run("All steps succeed", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
 		mockAllActivitiesSuccess(env)
 		env.OnActivity(pa.GoDeploy, mock.Anything, mock.Anything).Return(&GoDeployResult{}, nil)
 
@@ -32,9 +43,11 @@ func TestPipelineWorkflow(t *testing.T) {
 		var result PipelineResult
 		assert.NoError(t, env.GetWorkflowResult(&result))
 		assert.Empty(t, result.Failures)
+		assert.Equal(t, int64(4096), result.WorkdirBytes)
 	})
 
 	t.Run("Some failures introduced by fail flags", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
 		mockActivitiesWithFailures(env)
 
 		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{
@@ -87,27 +100,2013 @@ func TestPipelineWorkflow(t *testing.T) {
 		// Ensure GoDeploy was not called
 		env.AssertNotCalled(t, "OnActivity", pa.GoDeploy, mock.Anything)
 	})
+
+	t.Run("Package-level test failure fails the pipeline", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).Return(&GoTestResult{
+			FailedPackages: []GoTestCLIOutput{{Action: "fail", Package: "example.com/broken"}},
+		}, nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Len(t, result.Failures, 1)
+		assert.Equal(t, "GoTest", result.Failures[0].Activity)
+		env.AssertActivityNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+	})
 }
 
-func mockAllActivitiesSuccess(env *testsuite.TestWorkflowEnvironment) {
-	// all 7 passes
-	env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).Return(&GoTestResult{}, nil)
-	env.OnActivity(pa.GoFmt, mock.Anything, mock.Anything).Return(&GoFmtResult{}, nil)
-	env.OnActivity(pa.GoModTidy, mock.Anything, mock.Anything).Return(&GoModTidyResult{}, nil)
-	env.OnActivity(pa.GoBuild, mock.Anything, mock.Anything).Return(&GoBuildResult{}, nil)
-	env.OnActivity(pa.GoGenerate, mock.Anything, mock.Anything).Return(&GoGenerateResult{}, nil)
-	env.OnActivity(pa.GolangCILint, mock.Anything, mock.Anything).Return(&GolangCILintResult{}, nil)
-	env.OnActivity(pa.GoDeploy, mock.Anything, mock.Anything).Return(&GoDeployResult{}, nil)
+func TestDeployFailure(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoDeploy, mock.Anything, mock.Anything).Return(&GoDeployResult{
+		ErrorMsg: "deploy command exited with status 1: permission denied",
+	}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, []PipelineFailure{{
+		Activity: "Deploy",
+		Kind:     FailureIssues,
+		Details:  "deploy command exited with status 1: permission denied",
+	}}, result.Failures)
 }
 
-func mockActivitiesWithFailures(env *testsuite.TestWorkflowEnvironment) {
-	// 3 passes
-	env.OnActivity(pa.GoFmt, mock.Anything, mock.Anything).Return(&GoFmtResult{}, nil)
-	env.OnActivity(pa.GoModTidy, mock.Anything, mock.Anything).Return(&GoModTidyResult{}, nil)
-	env.OnActivity(pa.GolangCILint, mock.Anything, mock.Anything).Return(&GolangCILintResult{}, nil)
+func TestDeployTimeout(t *testing.T) {
+	// deploy runs a pipeline whose GoDeploy times out, returning the timeout of each attempt.
+	deploy := func(t *testing.T, params PipelineParams) []time.Duration {
+		var timeouts []time.Duration
+		env := newTestWorkflowEnvironment()
+		env.RegisterWorkflow(DeployWorkflow)
+		env.OnActivity(pa.GoDeploy, mock.Anything, mock.Anything).Return(func(ctx context.Context, _ GoDeployParams) (*GoDeployResult, error) {
+			info := activity.GetInfo(ctx)
+			timeouts = append(timeouts, info.Deadline.Sub(info.StartedTime))
+			return nil, temporal.NewTimeoutError(enumspb.TIMEOUT_TYPE_START_TO_CLOSE, nil)
+		})
+		mockAllActivitiesSuccess(env)
 
-	// 3 failures
-	env.OnActivity(pa.GoBuild, mock.Anything, mock.Anything).Return(&GoBuildResult{FailedFiles: []string{"main.go"}}, nil)
-	env.OnActivity(pa.GoGenerate, mock.Anything, mock.Anything).Return(&GoGenerateResult{FailedFiles: []string{"generated.go"}}, nil)
-	env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).Return(&GoTestResult{FailedTests: []GoTestCLIOutput{{Test: "TestFailed"}}}, nil)
+		env.ExecuteWorkflow(PipelineWorkflow, params)
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.Error(t, env.GetWorkflowError())
+		return timeouts
+	}
+
+	t.Run("Deploy command", func(t *testing.T) {
+		timeouts := deploy(t, PipelineParams{GitURL: gitUrl, DeployCommand: []string{"./deploy.sh"}, DeployTimeout: 20 * time.Minute})
+		assert.Equal(t, []time.Duration{20 * time.Minute}, timeouts, "runs once, with its own timeout")
+	})
+
+	t.Run("Deploy command in a DeployWorkflow", func(t *testing.T) {
+		timeouts := deploy(t, PipelineParams{GitURL: gitUrl, DeployCommand: []string{"./deploy.sh"}, SeparateDeploy: true})
+		assert.Equal(t, []time.Duration{DefaultDeployTimeout}, timeouts)
+	})
+
+	t.Run("Simulated deploy", func(t *testing.T) {
+		timeouts := deploy(t, PipelineParams{GitURL: gitUrl})
+		assert.Equal(t, []time.Duration{10 * time.Second, 10 * time.Second, 10 * time.Second}, timeouts, "is retried like the other activities")
+	})
+
+	params := PipelineParams{GitURL: gitUrl, DeployTimeout: -time.Minute}
+	assert.ErrorContains(t, params.Validate(), "DeployTimeout must not be negative")
+}
+
+func TestCommandTimeout(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoBenchmark, mock.Anything, mock.Anything).Return(&GoBenchmarkResult{}, nil)
+	env.OnActivity(pa.RunScript, mock.Anything, mock.Anything).Return(&RunScriptResult{Success: true}, nil)
+	env.OnActivity(pa.PreDeploy, mock.Anything, mock.Anything).Return(&PreDeployResult{Success: true}, nil)
+	env.OnActivity(pa.SmokeTest, mock.Anything, mock.Anything).Return(&SmokeTestResult{Success: true}, nil)
+	mockAllActivitiesSuccess(env)
+	timeouts := map[string]time.Duration{}
+	env.SetOnActivityStartedListener(func(info *activity.Info, _ context.Context, _ converter.EncodedValues) {
+		timeouts[info.ActivityType.Name] = info.Deadline.Sub(info.StartedTime)
+	})
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{
+		GitURL:             gitUrl,
+		RetryFailedTests:   2,
+		BenchmarkPattern:   ".",
+		CustomSteps:        []CustomStep{{Name: "e2e", Command: []string{"make", "e2e"}}},
+		PreDeployCommand:   []string{"./scripts/migrate", "--dry-run"},
+		DeployEnvironments: []DeployEnvironment{{Name: "dev", Command: []string{"deploy", "dev"}, SmokeCommand: []string{"smoke", "dev"}}},
+	})
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	for _, name := range []string{"GoModDownload", "GoTest", "GoBenchmark", "RunScript", "PreDeploy", "SmokeTest"} {
+		assert.Equal(t, commandTimeout, timeouts[name], name)
+	}
+	assert.Equal(t, 10*time.Second, timeouts["GoFmt"], "the quick checks keep the default")
+}
+
+func TestPreDeployFailureSkipsDeploy(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.PreDeploy, mock.Anything, mock.Anything).Return(&PreDeployResult{
+		ErrorMsg: "pre-deploy command exited with status 1",
+		Output:   "Error: migration 0042 would drop column users.email",
+	}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{
+		GitURL:           gitUrl,
+		PreDeployCommand: []string{"./scripts/migrate", "--dry-run"},
+	})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	if assert.Len(t, result.Failures, 1) {
+		assert.Equal(t, "PreDeploy", result.Failures[0].Activity)
+	}
+	env.AssertActivityNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+}
+
+func TestSeparateDeploy(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.RegisterWorkflow(DeployWorkflow)
+	mockAllActivitiesSuccess(env)
+
+	var childID, linkedParentID string
+	env.SetOnChildWorkflowStartedListener(func(info *workflow.Info, ctx workflow.Context, args converter.EncodedValues) {
+		childID = info.WorkflowExecution.ID
+		linkedParentID, _ = workflow.GetTypedSearchAttributes(ctx).GetKeyword(PipelineWorkflowIDSearchAttribute)
+	})
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, SeparateDeploy: true})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Empty(t, result.Failures)
+
+	parentID := "default-test-workflow-id"
+	assert.Equal(t, "DeployWorkflow-"+parentID, childID)
+	assert.Equal(t, parentID, linkedParentID)
+	env.AssertActivityCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+}
+
+func TestDeployEnvironments(t *testing.T) {
+	environments := []DeployEnvironment{
+		{Name: "dev", Command: []string{"deploy", "dev"}},
+		{Name: "staging", Command: []string{"deploy", "staging"}, RequireApproval: true},
+		{Name: "prod", Command: []string{"deploy", "prod"}},
+	}
+	deploysTo := func(name string) any {
+		return mock.MatchedBy(func(p GoDeployParams) bool { return p.DeployCommand[1] == name })
+	}
+
+	t.Run("Promotes through each environment in order", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		mockAllActivitiesSuccess(env)
+
+		var deployed []string
+		env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+			if info.ActivityType.Name == "GoDeploy" {
+				var p GoDeployParams
+				assert.NoError(t, args.Get(&p))
+				deployed = append(deployed, p.DeployCommand[1])
+			}
+		})
+		env.RegisterDelayedCallback(func() {
+			assert.Equal(t, []string{"dev"}, deployed, "staging must wait for approval")
+			env.SignalWorkflow(ApprovalSignalName("staging"), nil)
+		}, 10*time.Minute)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, DeployEnvironments: environments})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Empty(t, result.Failures)
+		assert.Equal(t, []string{"dev", "staging", "prod"}, deployed)
+		assert.Equal(t, []DeploymentResult{
+			{Environment: "dev", Status: DeploymentSucceeded},
+			{Environment: "staging", Status: DeploymentSucceeded},
+			{Environment: "prod", Status: DeploymentSucceeded},
+		}, result.Deployments)
+	})
+
+	t.Run("Stops promotion at the first failed environment", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GoDeploy, mock.Anything, deploysTo("dev")).Return(&GoDeployResult{Success: true}, nil)
+		env.OnActivity(pa.GoDeploy, mock.Anything, deploysTo("staging")).Return(nil, errors.New("connection refused"))
+		mockAllActivitiesSuccess(env)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(ApprovalSignalName("staging"), nil)
+		}, 10*time.Minute)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, DeployEnvironments: environments})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		if assert.Len(t, result.Failures, 1) {
+			assert.Equal(t, "Deploy", result.Failures[0].Activity)
+		}
+		if assert.Len(t, result.Deployments, 3) {
+			assert.Equal(t, DeploymentSucceeded, result.Deployments[0].Status)
+			assert.Equal(t, DeploymentFailed, result.Deployments[1].Status)
+			assert.Contains(t, result.Deployments[1].Error, "connection refused")
+			assert.Equal(t, DeploymentSkipped, result.Deployments[2].Status)
+		}
+		env.AssertActivityNotCalled(t, "GoDeploy", mock.Anything, deploysTo("prod"))
+	})
+
+	// promote runs a promotion whose dev deploy takes 10 minutes, returning its deployments and
+	// failures.
+	promote := func(t *testing.T, env *testsuite.TestWorkflowEnvironment, params PipelineParams) PipelineResult {
+		env.OnActivity(pa.GoDeploy, mock.Anything, deploysTo("dev")).After(10*time.Minute).Return(&GoDeployResult{Success: true}, nil)
+		mockAllActivitiesSuccess(env)
+
+		params.GitURL, params.DeployEnvironments = gitUrl, environments
+		env.ExecuteWorkflow(PipelineWorkflow, params)
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		env.AssertActivityNotCalled(t, "GoDeploy", mock.Anything, deploysTo("staging"))
+		env.AssertActivityNotCalled(t, "GoDeploy", mock.Anything, deploysTo("prod"))
+		return result
+	}
+
+	t.Run("Skips the remaining environments when not approved in time", func(t *testing.T) {
+		result := promote(t, newTestWorkflowEnvironment(), PipelineParams{ApprovalTimeout: 15 * time.Minute})
+		assert.Equal(t, []DeploymentResult{
+			{Environment: "dev", Status: DeploymentSucceeded},
+			{Environment: "staging", Status: DeploymentSkipped, Error: "deploy not approved within 15m0s"},
+			{Environment: "prod", Status: DeploymentSkipped},
+		}, result.Deployments)
+		assert.Empty(t, result.Failures)
+	})
+
+	t.Run("Ignores an approval signalled before the wait", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(ApprovalSignalName("staging"), nil)
+		}, 5*time.Minute)
+
+		result := promote(t, env, PipelineParams{ApprovalTimeout: 15 * time.Minute})
+		assert.Equal(t, DeploymentSkipped, result.Deployments[1].Status)
+	})
+
+	t.Run("Abort ends the wait", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AbortSignalName, nil)
+		}, 12*time.Minute)
+
+		result := promote(t, env, PipelineParams{})
+		assert.Equal(t, DeploymentResult{Environment: "staging", Status: DeploymentSkipped, Error: "pipeline aborted by signal"}, result.Deployments[1])
+		if assert.Len(t, result.Failures, 1) {
+			assert.Equal(t, FailureAborted, result.Failures[0].Kind)
+		}
+	})
+
+	t.Run("MaxDuration ends the wait", func(t *testing.T) {
+		result := promote(t, newTestWorkflowEnvironment(), PipelineParams{MaxDuration: 20 * time.Minute, ApprovalTimeout: 15 * time.Minute})
+		assert.Equal(t, DeploymentResult{Environment: "staging", Status: DeploymentSkipped, Error: "pipeline exceeded its MaxDuration"}, result.Deployments[1])
+		if assert.Len(t, result.Failures, 1) {
+			assert.Equal(t, "MaxDuration", result.Failures[0].Activity)
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		params := PipelineParams{GitURL: gitUrl, DeployEnvironments: []DeployEnvironment{{Name: "prod"}}}
+		assert.ErrorContains(t, params.Validate(), `DeployEnvironments "prod" command is required`)
+
+		params.DeployEnvironments = []DeployEnvironment{{Name: "prod", Command: []string{"deploy"}, RequireApproval: true}}
+		params.MaxDuration = DefaultApprovalTimeout
+		assert.ErrorContains(t, params.Validate(), "must be shorter than the pipeline's MaxDuration")
+	})
+}
+
+func TestRequireApproval(t *testing.T) {
+	t.Run("Deploys once approved", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		mockAllActivitiesSuccess(env)
+		env.RegisterDelayedCallback(func() {
+			env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+			env.SignalWorkflow(DeployApprovalSignalName, nil)
+		}, 10*time.Minute)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, RequireApproval: true})
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Empty(t, result.Failures)
+		assert.Empty(t, result.DeploySkipped)
+		env.AssertCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+		assert.Contains(t, result.Steps, StepProgress{Name: "Approval", State: StepDone, Duration: 10 * time.Minute})
+	})
+
+	t.Run("Skips the deploy without an approval in time", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		mockAllActivitiesSuccess(env)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(DeployApprovalSignalName, nil)
+		}, 20*time.Minute)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, RequireApproval: true, ApprovalTimeout: 15 * time.Minute})
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Empty(t, result.Failures)
+		assert.Equal(t, "deploy not approved within 15m0s", result.DeploySkipped)
+		env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Ignores an approval signalled before the wait", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).After(10*time.Minute).Return(&GoTestResult{}, nil)
+		mockAllActivitiesSuccess(env)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(DeployApprovalSignalName, nil)
+		}, time.Minute)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, RequireApproval: true, ApprovalTimeout: 15 * time.Minute})
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Equal(t, "deploy not approved within 15m0s", result.DeploySkipped)
+		env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Cancelling isn't a missed approval", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		mockAllActivitiesSuccess(env)
+		env.RegisterDelayedCallback(env.CancelWorkflow, 10*time.Minute)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, RequireApproval: true})
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.True(t, temporal.IsCanceledError(env.GetWorkflowError()))
+
+		encoded, err := env.QueryWorkflow(ProgressQueryName)
+		if assert.NoError(t, err) {
+			var progress PipelineProgress
+			assert.NoError(t, encoded.Get(&progress))
+			assert.Contains(t, progress.Steps, StepProgress{Name: "Approval", State: StepSkipped})
+		}
+		env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		params := PipelineParams{GitURL: gitUrl, RequireApproval: true, ApprovalTimeout: 2 * time.Hour}
+		assert.ErrorContains(t, params.Validate(), "must be shorter than the pipeline's MaxDuration")
+
+		params.MaxDuration = 3 * time.Hour
+		assert.NoError(t, params.Validate())
+	})
+}
+
+func TestDeploySmokeTestGate(t *testing.T) {
+	environments := []DeployEnvironment{
+		{Name: "dev", Command: []string{"deploy", "dev"}, SmokeCommand: []string{"smoke", "dev"}},
+		{
+			Name:            "staging",
+			Command:         []string{"deploy", "staging"},
+			SmokeCommand:    []string{"smoke", "staging"},
+			RollbackCommand: []string{"rollback", "staging"},
+		},
+		{Name: "prod", Command: []string{"deploy", "prod"}, SmokeCommand: []string{"smoke", "prod"}},
+	}
+	smokes := func(name string) any {
+		return mock.MatchedBy(func(p SmokeTestParams) bool { return p.Command[1] == name })
+	}
+
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.SmokeTest, mock.Anything, smokes("dev")).Return(&SmokeTestResult{Success: true}, nil)
+	env.OnActivity(pa.SmokeTest, mock.Anything, smokes("staging")).Return(&SmokeTestResult{ErrorMsg: "smoke test exited with status 1: 503"}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, DeployEnvironments: environments})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, []DeploymentResult{
+		{Environment: "dev", Status: DeploymentSucceeded},
+		{Environment: "staging", Status: DeploymentFailed, Error: "smoke test exited with status 1: 503", RolledBack: true},
+		{Environment: "prod", Status: DeploymentSkipped},
+	}, result.Deployments)
+	env.AssertActivityCalled(t, "GoDeploy", mock.Anything, mock.MatchedBy(func(p GoDeployParams) bool {
+		return p.DeployCommand[0] == "rollback"
+	}))
+	env.AssertActivityNotCalled(t, "SmokeTest", mock.Anything, smokes("prod"))
+}
+
+func TestDeployServices(t *testing.T) {
+	services := []DeployService{
+		{Name: "app", Command: []string{"deploy", "app"}, DependsOn: []string{"migrations"}},
+		{Name: "migrations", Command: []string{"deploy", "migrations"}},
+		{Name: "worker", Command: []string{"deploy", "worker"}, DependsOn: []string{"migrations"}},
+		{Name: "docs", Command: []string{"deploy", "docs"}},
+	}
+
+	t.Run("Deploys in dependency order with independent services in parallel", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GoDeploy, mock.Anything, mock.Anything).After(5*time.Second).Return(&GoDeployResult{Success: true}, nil)
+		mockAllActivitiesSuccess(env)
+
+		started := map[string]time.Time{}
+		env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+			if info.ActivityType.Name == "GoDeploy" {
+				var p GoDeployParams
+				assert.NoError(t, args.Get(&p))
+				started[p.DeployCommand[1]] = env.Now()
+			}
+		})
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, DeployServices: services})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Empty(t, result.Failures)
+		assert.Equal(t, []ServiceDeployment{
+			{Service: "app", Status: DeploymentSucceeded},
+			{Service: "migrations", Status: DeploymentSucceeded},
+			{Service: "worker", Status: DeploymentSucceeded},
+			{Service: "docs", Status: DeploymentSucceeded},
+		}, result.Services)
+
+		assert.Equal(t, started["migrations"], started["docs"], "independent services deploy in parallel")
+		assert.True(t, started["app"].After(started["migrations"]), "app waits for migrations")
+		assert.Equal(t, started["app"], started["worker"], "services with the same dependencies deploy in parallel")
+	})
+
+	t.Run("Skips services whose dependency failed", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GoDeploy, mock.Anything, mock.MatchedBy(func(p GoDeployParams) bool {
+			return p.DeployCommand[1] == "migrations"
+		})).Return(&GoDeployResult{ErrorMsg: "migration 42 failed"}, nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, DeployServices: services})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Len(t, result.Failures, 1)
+		assert.Equal(t, []ServiceDeployment{
+			{Service: "app", Status: DeploymentSkipped},
+			{Service: "migrations", Status: DeploymentFailed, Error: "migration 42 failed"},
+			{Service: "worker", Status: DeploymentSkipped},
+			{Service: "docs", Status: DeploymentSucceeded},
+		}, result.Services)
+	})
+
+	t.Run("Rejects dependency cycles", func(t *testing.T) {
+		params := PipelineParams{GitURL: gitUrl, DeployServices: []DeployService{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"a"}},
+		}}
+		assert.ErrorContains(t, params.Validate(), "cycle")
+
+		params.DeployServices = []DeployService{{Name: "a", DependsOn: []string{"missing"}}}
+		assert.ErrorContains(t, params.Validate(), "unknown service")
+	})
+}
+
+func TestBenchmarks(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	mockAllActivitiesSuccess(env)
+	benchmarks := []BenchmarkResult{{Package: "example.com/app", Name: "BenchmarkEncode", NsPerOp: 1052}}
+	env.OnActivity(pa.GoBenchmark, mock.Anything, mock.MatchedBy(func(p GoBenchmarkParams) bool {
+		return p.Pattern == "Encode" && slices.Equal(p.Tags, []string{"integration"})
+	})).Return(&GoBenchmarkResult{Benchmarks: benchmarks}, nil)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, BenchmarkPattern: "Encode", BuildTags: []string{"integration"}})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Empty(t, result.Failures)
+	assert.Equal(t, benchmarks, result.Benchmarks)
+}
+
+func TestMatrix(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoBuild, mock.Anything, mock.MatchedBy(func(p GoBuildParams) bool {
+		return p.GoVersion == "1.21" && p.GOOS == "windows"
+	})).Return(&GoBuildResult{Diagnostics: "undefined: syscall.Flock"}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{
+		GitURL: gitUrl,
+		Matrix: &MatrixParams{Go: []string{"1.21", "1.22"}, OS: []string{"linux", "windows"}, Arch: []string{"amd64"}},
+	})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+
+	type cell struct{ Go, OS, Arch, Status string }
+	var cells []cell
+	for _, c := range result.MatrixResults {
+		cells = append(cells, cell{c.Go, c.OS, c.Arch, c.Status})
+	}
+	assert.Equal(t, []cell{
+		{"1.21", "linux", "amd64", MatrixPassed},
+		{"1.21", "windows", "amd64", MatrixFailed},
+		{"1.22", "linux", "amd64", MatrixPassed},
+		{"1.22", "windows", "amd64", MatrixPassed},
+	}, cells)
+	if assert.Len(t, result.Failures, 1) {
+		assert.Equal(t, "Matrix", result.Failures[0].Activity)
+	}
+	assert.Len(t, result.MatrixResults[1].Failures, 1)
+}
+
+func TestMatrixTargets(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		params := PipelineParams{GitURL: gitUrl, Matrix: &MatrixParams{Targets: []string{"linux/arm64", "windows/amd64"}}}
+		assert.NoError(t, params.Validate())
+
+		params.Matrix.Targets = []string{"linux"}
+		assert.ErrorContains(t, params.Validate(), `Matrix target "linux" must be GOOS/GOARCH`)
+
+		params.Matrix = &MatrixParams{Targets: []string{"linux/arm64"}, OS: []string{"windows"}}
+		assert.ErrorContains(t, params.Validate(), "Matrix Targets cannot be combined with OS or Arch")
+	})
+
+	t.Run("Target environment", func(t *testing.T) {
+		// A go that prints the target it was asked to build for.
+		fakeTool(t, "go", "echo \"$GOOS/$GOARCH $GOFLAGS\"\n")
+
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoBuild)
+
+		val, err := env.ExecuteActivity(pa.GoBuild, GoBuildParams{
+			Metadata: PipelineActivityMetadata{Workdir: t.TempDir(), Env: map[string]string{"GOFLAGS": "-mod=mod"}},
+			GOOS:     "linux",
+			GOARCH:   "arm64",
+			GOFLAGS:  "-tags=netgo",
+		})
+		assert.NoError(t, err)
+
+		var result GoBuildResult
+		assert.NoError(t, val.Get(&result))
+		assert.Equal(t, "linux/arm64 -tags=netgo\n", result.Logs)
+	})
+
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoBuild, mock.Anything, mock.MatchedBy(func(p GoBuildParams) bool {
+		return p.GOOS == "windows"
+	})).Return(nil, temporal.NewNonRetryableApplicationError("undefined: syscall.Flock", "ToolFailed", nil))
+	env.OnActivity(pa.GoBuild, mock.Anything, mock.MatchedBy(func(p GoBuildParams) bool {
+		return p.GOOS == "linux" && p.GOFLAGS != "-tags=netgo"
+	})).Return(nil, temporal.NewNonRetryableApplicationError("matrix GOFLAGS not set", "ToolFailed", nil))
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{
+		GitURL: gitUrl,
+		Matrix: &MatrixParams{Go: []string{"1.22"}, Targets: []string{"linux/arm64", "windows/amd64"}, GOFLAGS: "-tags=netgo"},
+	})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	if assert.Len(t, result.MatrixResults, 2) {
+		assert.Equal(t, MatrixCellResult{Go: "1.22", OS: "linux", Arch: "arm64", Status: MatrixPassed}, result.MatrixResults[0])
+		assert.Equal(t, MatrixFailed, result.MatrixResults[1].Status)
+	}
+	if assert.Len(t, result.Failures, 1) {
+		assert.Equal(t, "Matrix", result.Failures[0].Activity)
+		assert.Equal(t, FailureError, result.Failures[0].Kind)
+	}
+}
+
+func TestMultiRepo(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoTest, mock.Anything, mock.MatchedBy(func(p GoTestParams) bool {
+		return p.Metadata.Workdir == filepath.Join("/tmp/test", "lib")
+	})).Return(&GoTestResult{FailedTests: []GoTestCLIOutput{{Test: "TestLib"}}}, nil)
+	mockAllActivitiesSuccess(env)
+
+	var cloned []GitCloneParams
+	env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		if info.ActivityType.Name == "GitClone" {
+			var p GitCloneParams
+			assert.NoError(t, args.Get(&p))
+			cloned = append(cloned, p)
+		}
+	})
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{Repos: []RepoSpec{
+		{URL: "https://example.com/app.git", Subdir: "app"},
+		{URL: "https://example.com/lib.git", Ref: "v1.2.0", Subdir: "lib"},
+	}})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	if assert.Len(t, cloned, 2) {
+		assert.Equal(t, GitCloneParams{Remote: "https://example.com/app.git", Subdir: "app"}, cloned[0])
+		assert.Equal(t, "/tmp/test", cloned[1].Metadata.Workdir, "later repositories clone into the same workdir")
+		assert.Equal(t, "v1.2.0", cloned[1].Ref)
+		assert.Equal(t, "lib", cloned[1].Subdir)
+	}
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	if assert.Len(t, result.Failures, 1) {
+		assert.Equal(t, "GoTest", result.Failures[0].Activity)
+		assert.Equal(t, "lib", result.Failures[0].Repo)
+	}
+}
+
+func TestBranches(t *testing.T) {
+	branches := []string{"main", "release/*"}
+
+	t.Run("Skips a branch that isn't configured", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, Ref: "refs/heads/feature/login", Branches: branches})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Equal(t, "skipped: branch not configured", result.Skipped)
+		assert.Empty(t, result.Failures)
+		env.AssertNotCalled(t, "GitClone", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Runs a matching branch", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, Ref: "refs/heads/release/1.4", Branches: branches})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Empty(t, result.Skipped)
+		env.AssertCalled(t, "GoTest", mock.Anything, mock.Anything)
+	})
+}
+
+func TestLogs(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoBuild, mock.Anything, mock.Anything).Return(&GoBuildResult{FailedFiles: []string{"main.go"}, Logs: "main.go:3:1: syntax error"}, nil)
+	env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).Return(&GoTestResult{Logs: "ok  \texample.com/pkg"}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, map[string]string{
+		"GoBuild": "main.go:3:1: syntax error",
+		"GoTest":  "ok  \texample.com/pkg",
+	}, result.Logs)
+	if assert.NotNil(t, result.Tests) {
+		assert.Empty(t, result.Tests.Logs, "logs aren't duplicated in the test results")
+	}
+}
+
+func TestTimings(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).Return(&GoTestResult{ElapsedMs: 1500}, nil)
+	// Without a reported run time, the time the workflow waited for the activity is used.
+	env.OnActivity(pa.GoBuild, mock.Anything, mock.Anything).After(2*time.Second).Return(&GoBuildResult{}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, 1500*time.Millisecond, result.Timings["GoTest"])
+	assert.Equal(t, 2*time.Second, result.Timings["GoBuild"])
+	assert.NotContains(t, result.Timings, "GoFmt")
+}
+
+func TestCommitRange(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.ListCommits, mock.Anything, ListCommitsParams{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}, Range: "base..head"}).
+		Return(&ListCommitsResult{Commits: []string{"aaa", "bbb", "ccc"}}, nil)
+
+	// The build breaks from commit bbb onwards.
+	var checkedOut string
+	env.OnActivity(pa.GitCheckout, mock.Anything, mock.Anything).Return(func(_ context.Context, params GitCheckoutParams) (*GitCheckoutResult, error) {
+		checkedOut = params.Commit
+		return &GitCheckoutResult{}, nil
+	})
+	env.OnActivity(pa.GoBuild, mock.Anything, mock.Anything).Return(func(context.Context, GoBuildParams) (*GoBuildResult, error) {
+		if checkedOut == "aaa" {
+			return &GoBuildResult{}, nil
+		}
+		return &GoBuildResult{FailedFiles: []string{"main.go"}}, nil
+	})
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, CommitRange: "base..head", NoCache: true})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, "bbb", result.FirstBadCommit)
+	if assert.Len(t, result.Commits, 2) {
+		assert.Equal(t, "aaa", result.Commits[0].SHA)
+		assert.Empty(t, result.Commits[0].Failures)
+		assert.Equal(t, "bbb", result.Commits[1].SHA)
+		assert.NotEmpty(t, result.Commits[1].Failures)
+	}
+	if assert.Len(t, result.Failures, 1) {
+		assert.Equal(t, "CommitRange", result.Failures[0].Activity)
+	}
+	env.AssertNotCalled(t, "GitCheckout", mock.Anything, GitCheckoutParams{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}, Commit: "ccc"})
+	env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+}
+
+func TestBisect(t *testing.T) {
+	commits := []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8"}
+	run := func(t *testing.T, params PipelineParams) PipelineResult {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.ListCommits, mock.Anything, mock.Anything).Return(&ListCommitsResult{Commits: commits}, nil)
+
+		// c5 breaks the build; base and every commit before c5 pass.
+		var checkedOut string
+		env.OnActivity(pa.GitCheckout, mock.Anything, mock.Anything).Return(func(_ context.Context, params GitCheckoutParams) (*GitCheckoutResult, error) {
+			checkedOut = params.Commit
+			return &GitCheckoutResult{}, nil
+		})
+		env.OnActivity(pa.GoBuild, mock.Anything, mock.Anything).Return(func(context.Context, GoBuildParams) (*GoBuildResult, error) {
+			if checkedOut == "base" || checkedOut < "c5" {
+				return &GoBuildResult{}, nil
+			}
+			return &GoBuildResult{FailedFiles: []string{"main.go"}}, nil
+		})
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, params)
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		return result
+	}
+
+	t.Run("Finds the commit that introduced the failure", func(t *testing.T) {
+		result := run(t, PipelineParams{GitURL: gitUrl, CommitRange: "base..c8", Bisect: true, NoCache: true})
+
+		assert.Equal(t, "c5", result.FirstBadCommit)
+		var checked []string
+		for _, commit := range result.Commits {
+			checked = append(checked, commit.SHA)
+		}
+		assert.Equal(t, []string{"c8", "base", "c4", "c6", "c5"}, checked)
+		if assert.Len(t, result.Failures, 1) {
+			assert.Equal(t, "Bisect", result.Failures[0].Activity)
+		}
+	})
+
+	t.Run("Stops after BisectMaxSteps", func(t *testing.T) {
+		result := run(t, PipelineParams{GitURL: gitUrl, CommitRange: "base..c8", Bisect: true, BisectMaxSteps: 3, NoCache: true})
+
+		assert.Empty(t, result.FirstBadCommit)
+		assert.Len(t, result.Commits, 3)
+		if assert.Len(t, result.Failures, 1) {
+			assert.Equal(t, "Bisect", result.Failures[0].Activity)
+			assert.Equal(t, "stopped after 3 steps: the first failing commit is after c4 and at or before c8", result.Failures[0].Details)
+		}
+	})
+}
+
+func TestFailWorkflowOnChecks(t *testing.T) {
+	t.Run("Fails the workflow when checks fail", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		mockActivitiesWithFailures(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, FailWorkflowOnChecks: true})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, env.GetWorkflowError(), &appErr) {
+			assert.Equal(t, PipelineFailedErrorType, appErr.Type())
+			assert.True(t, appErr.NonRetryable())
+
+			var result PipelineResult
+			assert.NoError(t, appErr.Details(&result))
+			assert.Len(t, result.Failures, 3)
+		}
+	})
+
+	t.Run("Completes the workflow when checks pass", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, FailWorkflowOnChecks: true})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+	})
+}
+
+func TestRepeat(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, Repeat: true, Interval: time.Hour, ContinueAsNewEvery: 2})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.True(t, workflow.IsContinueAsNewError(env.GetWorkflowError()))
+	env.AssertNumberOfCalls(t, "GitClone", 2)
+	env.AssertNumberOfCalls(t, "GoTest", 2)
+}
+
+func TestCancelChecks(t *testing.T) {
+	t.Run("FailFast cancels the remaining checks after the first failure", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).After(5*time.Second).Return(&GoTestResult{}, nil)
+		env.OnActivity(pa.GoBuild, mock.Anything, mock.Anything).Return(&GoBuildResult{Diagnostics: "main.go:1: syntax error"}, nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, FailFast: true})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		if assert.Len(t, result.Failures, 1) {
+			assert.Equal(t, "GoBuild", result.Failures[0].Activity)
+		}
+		assert.Nil(t, result.Tests, "GoTest was cancelled")
+		env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+		env.AssertCalled(t, "DeleteWorkdir", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Abort signal cancels the checks and skips the deploy", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).After(5*time.Second).Return(&GoTestResult{}, nil)
+		mockAllActivitiesSuccess(env)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AbortSignalName, nil)
+		}, time.Second)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		if assert.Len(t, result.Failures, 1) {
+			assert.Equal(t, "Abort", result.Failures[0].Activity)
+			assert.Equal(t, FailureAborted, result.Failures[0].Kind)
+		}
+		assert.Nil(t, result.Tests, "GoTest was cancelled")
+		env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+		env.AssertCalled(t, "DeleteWorkdir", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCancelDeletesWorkdir(t *testing.T) {
+	t.Run("During the checks", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).After(5*time.Second).Return(&GoTestResult{}, nil)
+		mockAllActivitiesSuccess(env)
+		env.RegisterDelayedCallback(env.CancelWorkflow, time.Second)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.True(t, temporal.IsCanceledError(env.GetWorkflowError()))
+		env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+		env.AssertCalled(t, "DeleteWorkdir", mock.Anything, mock.Anything)
+	})
+
+	t.Run("During the clone", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		// The first repository is cloned, creating the workdir, and the second takes a while.
+		env.OnActivity(pa.GitClone, mock.Anything, mock.MatchedBy(func(p GitCloneParams) bool { return p.Subdir == "web" })).
+			After(5*time.Second).Return(&GitCloneResult{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}}, nil)
+		env.OnActivity(pa.GitClone, mock.Anything, mock.Anything).Return(&GitCloneResult{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}}, nil)
+		mockSetupActivities(env)
+		mockAllActivitiesSuccess(env)
+		env.RegisterDelayedCallback(env.CancelWorkflow, time.Second)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{Repos: []RepoSpec{
+			{URL: "https://example.com/api.git", Subdir: "api"},
+			{URL: "https://example.com/web.git", Subdir: "web"},
+		}})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.True(t, temporal.IsCanceledError(env.GetWorkflowError()))
+		env.AssertCalled(t, "DeleteWorkdir", mock.Anything, DeleteWorkdirParams{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}})
+	})
+
+	t.Run("Before the workdir exists", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		env.OnActivity(pa.GitClone, mock.Anything, mock.Anything).After(5*time.Second).Return(&GitCloneResult{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}}, nil)
+		mockSetupActivities(env)
+		env.RegisterDelayedCallback(env.CancelWorkflow, time.Second)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.True(t, temporal.IsCanceledError(env.GetWorkflowError()))
+		// The cancelled clone removes the workdir it created itself.
+		env.AssertNotCalled(t, "DeleteWorkdir", mock.Anything, mock.Anything)
+	})
+}
+
+func TestMaxDuration(t *testing.T) {
+	t.Run("Cancels the checks and still cleans up", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).After(2*time.Hour).Return(&GoTestResult{}, nil)
+		env.OnActivity(pa.NotifyWebhook, mock.Anything, mock.Anything).Return(nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, MaxDuration: time.Hour, CallbackURL: "https://ci.example.com/callback"})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		if assert.Len(t, result.Failures, 1) {
+			assert.Equal(t, "MaxDuration", result.Failures[0].Activity)
+			assert.Equal(t, FailureTimeout, result.Failures[0].Kind)
+		}
+		assert.Nil(t, result.Tests, "GoTest was cancelled")
+		env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+		env.AssertCalled(t, "DeleteWorkdir", mock.Anything, mock.Anything)
+		env.AssertCalled(t, "NotifyWebhook", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Ends the approval wait", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).After(20*time.Minute).Return(&GoTestResult{}, nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, RequireApproval: true, MaxDuration: 40 * time.Minute})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		if assert.Len(t, result.Failures, 1) {
+			assert.Equal(t, "MaxDuration", result.Failures[0].Activity)
+		}
+		assert.Empty(t, result.DeploySkipped, "the approval didn't time out")
+		assert.Contains(t, result.Steps, StepProgress{Name: "Approval", State: StepSkipped})
+		env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+		env.AssertCalled(t, "DeleteWorkdir", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Doesn't interrupt the deploy", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GoDeploy, mock.Anything, mock.Anything).After(2*time.Hour).Return(&GoDeployResult{}, nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, MaxDuration: time.Hour})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Empty(t, result.Failures)
+	})
+}
+
+func TestLintFailSeverity(t *testing.T) {
+	warnings := &GolangCILintResult{Issues: []LintIssue{
+		{Linter: "unused", Severity: "warning", Text: "func `helper` is unused"},
+		{Linter: "godot", Severity: "info", Text: "Comment should end in a period"},
+	}}
+
+	t.Run("Warnings below the threshold do not fail", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GolangCILint, mock.Anything, mock.Anything).Return(warnings, nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, LintFailSeverity: "error"})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Empty(t, result.Failures)
+	})
+
+	t.Run("Any issue fails by default", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.GolangCILint, mock.Anything, mock.Anything).Return(warnings, nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		if assert.Len(t, result.Failures, 1) {
+			assert.Equal(t, "GolangCILint", result.Failures[0].Activity)
+		}
+	})
+}
+
+func TestProgressQuery(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	// GoTest is still running when the query arrives; the other checks have finished.
+	env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).After(5*time.Second).Return(&GoTestResult{}, nil)
+	env.OnActivity(pa.GoBuild, mock.Anything, mock.Anything).Return(&GoBuildResult{Diagnostics: "main.go:1: syntax error"}, nil)
+	mockAllActivitiesSuccess(env)
+
+	stepStates := func(progress PipelineProgress) map[string]StepState {
+		states := map[string]StepState{}
+		for _, step := range progress.Steps {
+			states[step.Name] = step.State
+		}
+		return states
+	}
+
+	env.RegisterDelayedCallback(func() {
+		encoded, err := env.QueryWorkflow(ProgressQueryName)
+		assert.NoError(t, err)
+		var progress PipelineProgress
+		assert.NoError(t, encoded.Get(&progress))
+
+		states := stepStates(progress)
+		assert.Equal(t, StepDone, states["GitClone"])
+		assert.Equal(t, StepRunning, states["GoTest"])
+		assert.Equal(t, StepDone, states["GoFmt"])
+		assert.Equal(t, StepPending, states["Deploy"])
+	}, time.Second)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	encoded, err := env.QueryWorkflow(ProgressQueryName)
+	assert.NoError(t, err)
+	var progress PipelineProgress
+	assert.NoError(t, encoded.Get(&progress))
+
+	assert.Equal(t, "GitClone", progress.Steps[0].Name)
+	states := stepStates(progress)
+	assert.Equal(t, StepDone, states["GoTest"])
+	assert.Equal(t, StepFailed, states["GoBuild"])
+	assert.Equal(t, StepSkipped, states["Deploy"])
+	assert.Equal(t, StepDone, states["DeleteWorkdir"])
+
+	// The result has the final progress, with the duration of each step that ran.
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, progress.Steps, result.Steps)
+	for _, step := range result.Steps {
+		if step.Name == "GoTest" {
+			assert.Equal(t, 5*time.Second, step.Duration)
+		}
+	}
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	policy := newRetryPolicy(RetryParams{
+		InitialInterval:    2 * time.Second,
+		BackoffCoefficient: 3,
+		MaximumInterval:    time.Minute,
+	})
+
+	assert.Equal(t, 2*time.Second, policy.InitialInterval)
+	assert.Equal(t, 3.0, policy.BackoffCoefficient)
+	assert.Equal(t, time.Minute, policy.MaximumInterval)
+	assert.Equal(t, int32(3), policy.MaximumAttempts)
+}
+
+func TestGitCloneActivity(t *testing.T) {
+	// A local repository with two commits on main and one on a feature branch.
+	remote := gitRepo(t, nil)
+	first := git(t, remote, "rev-parse", "HEAD")
+	git(t, remote, "commit", "--allow-empty", "-m", "second")
+	second := git(t, remote, "rev-parse", "HEAD")
+	git(t, remote, "checkout", "-b", "feature")
+	git(t, remote, "commit", "--allow-empty", "-m", "feature")
+	feature := git(t, remote, "rev-parse", "HEAD")
+	git(t, remote, "checkout", "main")
+
+	tests := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{"Default branch", "", second},
+		{"Full commit SHA", first, first},
+		{"Branch", "feature", feature},
+		{"Full branch ref", "refs/heads/feature", feature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestActivityEnvironment()
+			env.RegisterActivity(pa.GitClone)
+
+			val, err := env.ExecuteActivity(pa.GitClone, GitCloneParams{
+				Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+				Remote:   remote,
+				Ref:      tt.ref,
+			})
+			assert.NoError(t, err)
+
+			var result GitCloneResult
+			assert.NoError(t, val.Get(&result))
+			assert.Equal(t, tt.want, result.CommitSHA)
+		})
+	}
+
+	t.Run("Subdir", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GitClone)
+
+		workdir := t.TempDir()
+		val, err := env.ExecuteActivity(pa.GitClone, GitCloneParams{
+			Metadata: PipelineActivityMetadata{Workdir: workdir},
+			Remote:   remote,
+			Ref:      "feature",
+			Subdir:   "lib",
+		})
+		assert.NoError(t, err)
+
+		var result GitCloneResult
+		assert.NoError(t, val.Get(&result))
+		assert.Equal(t, workdir, result.Metadata.Workdir)
+		assert.Equal(t, feature, result.CommitSHA)
+		assert.DirExists(t, filepath.Join(workdir, "lib", ".git"))
+	})
+
+	t.Run("Unknown ref", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GitClone)
+
+		_, err := env.ExecuteActivity(pa.GitClone, GitCloneParams{
+			Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+			Remote:   remote,
+			Ref:      "does-not-exist",
+		})
+		assert.ErrorContains(t, err, `checking out ref "does-not-exist"`)
+	})
+
+	t.Run("Missing Dir", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GitClone)
+
+		_, err := env.ExecuteActivity(pa.GitClone, GitCloneParams{
+			Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+			Remote:   remote,
+			Dir:      "services/foo",
+		})
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.True(t, appErr.NonRetryable())
+			assert.Equal(t, "SubdirNotFound", appErr.Type())
+		}
+	})
+
+	t.Run("WorkdirRoot", func(t *testing.T) {
+		root := t.TempDir()
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(&PipelineActivity{WorkdirRoot: "/does/not/exist"})
+
+		val, err := env.ExecuteActivity(pa.GitClone, GitCloneParams{Remote: remote, WorkdirRoot: root})
+		assert.NoError(t, err)
+
+		var result GitCloneResult
+		assert.NoError(t, val.Get(&result))
+		assert.Equal(t, root, filepath.Dir(result.Metadata.Workdir))
+	})
+
+	t.Run("Failed clone removes the workdir it created", func(t *testing.T) {
+		root := t.TempDir()
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GitClone)
+
+		_, err := env.ExecuteActivity(pa.GitClone, GitCloneParams{Remote: remote, Ref: "does-not-exist", WorkdirRoot: root})
+		assert.Error(t, err)
+
+		entries, err := os.ReadDir(root)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Missing WorkdirRoot", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(&PipelineActivity{WorkdirRoot: "/does/not/exist"})
+
+		_, err := env.ExecuteActivity(pa.GitClone, GitCloneParams{Remote: remote})
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.True(t, appErr.NonRetryable())
+			assert.Contains(t, appErr.Error(), `workdir root "/does/not/exist"`)
+		}
+	})
+
+	// fakeGit puts a git on PATH whose first failures clones print stderr and fail, after leaving
+	// a partial clone behind. It returns a func counting the clones attempted.
+	fakeGit := func(t *testing.T, stderr string, failures int) func() int {
+		realGit, err := exec.LookPath("git")
+		if err != nil {
+			t.Fatal(err)
+		}
+		attempts := filepath.Join(t.TempDir(), "attempts")
+		fakeTool(t, "git", fmt.Sprintf(`if [ "$1" = clone ]; then
+	echo >> %[1]q
+	if [ "$(wc -l < %[1]q)" -le %[2]d ]; then
+		touch "$3/partial"
+		echo %[3]q >&2
+		exit 128
+	fi
+fi
+exec %[4]q "$@"
+`, attempts, failures, stderr, realGit))
+		return func() int {
+			content, _ := os.ReadFile(attempts)
+			return strings.Count(string(content), "\n")
+		}
+	}
+
+	t.Run("Network error is retried", func(t *testing.T) {
+		attempts := fakeGit(t, "fatal: unable to access 'https://example.com/': Could not resolve host: example.com", 1)
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(&PipelineActivity{CloneBackoff: time.Millisecond})
+
+		val, err := env.ExecuteActivity(pa.GitClone, GitCloneParams{
+			Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+			Remote:   remote,
+		})
+		assert.NoError(t, err)
+
+		var result GitCloneResult
+		assert.NoError(t, val.Get(&result))
+		assert.Equal(t, second, result.CommitSHA)
+		assert.Equal(t, 2, attempts())
+		assert.NoFileExists(t, filepath.Join(result.Metadata.Workdir, "partial"))
+	})
+
+	t.Run("Auth error is not retried", func(t *testing.T) {
+		attempts := fakeGit(t, "fatal: Authentication failed for 'https://example.com/'", 1)
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(&PipelineActivity{CloneBackoff: time.Millisecond})
+
+		_, err := env.ExecuteActivity(pa.GitClone, GitCloneParams{
+			Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+			Remote:   remote,
+		})
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.True(t, appErr.NonRetryable())
+			assert.Equal(t, "GitAuthFailed", appErr.Type())
+		}
+		assert.Equal(t, 1, attempts())
+	})
+}
+
+func TestGitEnv(t *testing.T) {
+	assert.Nil(t, gitEnv("", false))
+
+	env := gitEnv("/etc/pki/internal-ca.pem", false)
+	assert.Contains(t, env, "GIT_SSL_CAINFO=/etc/pki/internal-ca.pem")
+	assert.Contains(t, env, "PATH="+os.Getenv("PATH"))
+	assert.NotContains(t, env, "GIT_SSL_NO_VERIFY=true")
+
+	env = gitEnv("", true)
+	assert.Contains(t, env, "GIT_SSL_NO_VERIFY=true")
+
+	params := PipelineParams{GitURL: gitUrl, GitCACertPath: "/etc/pki/internal-ca.pem", GitInsecure: true}
+	assert.ErrorContains(t, params.Validate(), "GitCACertPath and GitInsecure cannot both be set")
+}
+
+func TestGitEnvOfFetches(t *testing.T) {
+	// A git that records the CA bundle of each fetch, in a shallow clone whose diff changes go.mod.
+	fetches := filepath.Join(t.TempDir(), "fetches")
+	fakeTool(t, "git", `case "$1" in
+fetch) echo "$GIT_SSL_CAINFO" >> `+fetches+` ;;
+rev-parse) echo true ;;
+rev-list) echo aaa ;;
+diff) echo go.mod ;;
+esac
+`)
+	const caCert = "/etc/pki/internal-ca.pem"
+
+	t.Run("ListCommits deepens a shallow clone with it", func(t *testing.T) {
+		t.Cleanup(func() { _ = os.Remove(fetches) })
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.ListCommits)
+
+		_, err := env.ExecuteActivity(pa.ListCommits, ListCommitsParams{Metadata: PipelineActivityMetadata{Workdir: t.TempDir()}, Range: "base..head", CACertPath: caCert})
+		assert.NoError(t, err)
+		content, err := os.ReadFile(fetches)
+		assert.NoError(t, err)
+		assert.Equal(t, caCert+"\n", string(content))
+	})
+
+	t.Run("DetectChanges fetches a diff base with it", func(t *testing.T) {
+		t.Cleanup(func() { _ = os.Remove(fetches) })
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.DetectChanges)
+
+		_, err := env.ExecuteActivity(pa.DetectChanges, DetectChangesParams{Metadata: PipelineActivityMetadata{Workdir: t.TempDir()}, Base: strings.Repeat("a", 40), CACertPath: caCert})
+		assert.NoError(t, err)
+		content, err := os.ReadFile(fetches)
+		assert.NoError(t, err)
+		assert.Equal(t, caCert+"\n", string(content))
+	})
+
+	t.Run("The workflow passes it on", func(t *testing.T) {
+		var listed ListCommitsParams
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.ListCommits, mock.Anything, mock.Anything).Return(func(_ context.Context, params ListCommitsParams) (*ListCommitsResult, error) {
+			listed = params
+			return &ListCommitsResult{Commits: []string{"aaa"}}, nil
+		})
+		env.OnActivity(pa.GitCheckout, mock.Anything, mock.Anything).Return(&GitCheckoutResult{}, nil)
+		mockAllActivitiesSuccess(env)
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, CommitRange: "base..head", GitCACertPath: caCert})
+		assert.NoError(t, env.GetWorkflowError())
+		assert.Equal(t, caCert, listed.CACertPath)
+
+		var detected DetectChangesParams
+		env = newTestWorkflowEnvironment()
+		env.OnActivity(pa.DetectChanges, mock.Anything, mock.Anything).Return(func(_ context.Context, params DetectChangesParams) (*DetectChangesResult, error) {
+			detected = params
+			return &DetectChangesResult{}, nil
+		})
+		mockAllActivitiesSuccess(env)
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, DiffBase: "origin/main", GitCACertPath: caCert})
+		assert.NoError(t, env.GetWorkflowError())
+		assert.Equal(t, caCert, detected.CACertPath)
+	})
+}
+
+func TestGoEnv(t *testing.T) {
+	workdir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(workdir, "go.mod"), []byte("module example.com/env\n\ngo 1.22\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(workdir, "gen.go"), []byte("package env\n\n//go:generate sh -c \"printf %s \\\"$CGO_ENABLED $GOPRIVATE\\\" > env.txt\"\n"), 0o644))
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.GoGenerate)
+
+	_, err := env.ExecuteActivity(pa.GoGenerate, GoGenerateParams{
+		Metadata: PipelineActivityMetadata{
+			Workdir: workdir,
+			Env:     map[string]string{"CGO_ENABLED": "0", "GOPRIVATE": "example.com/private"},
+		},
+	})
+	assert.NoError(t, err)
+
+	got, err := os.ReadFile(filepath.Join(workdir, "env.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "0 example.com/private", string(got))
+}
+
+func TestGoGenerateActivity(t *testing.T) {
+	// A repository whose generator writes gen.txt, committed with committed as its content.
+	repo := func(t *testing.T, committed string) string {
+		return gitRepo(t, map[string]string{
+			"go.mod":  "module example.com/gen\n\ngo 1.22\n",
+			"gen.go":  "package gen\n\n//go:generate sh -c \"echo generated > gen.txt\"\n",
+			"gen.txt": committed,
+		})
+	}
+
+	t.Run("Stale generated file", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoGenerate)
+
+		val, err := env.ExecuteActivity(pa.GoGenerate, GoGenerateParams{Metadata: PipelineActivityMetadata{Workdir: repo(t, "stale\n")}})
+		assert.NoError(t, err)
+
+		var result GoGenerateResult
+		assert.NoError(t, val.Get(&result))
+		assert.Equal(t, []string{"gen.txt"}, result.FailedFiles)
+	})
+
+	t.Run("Up to date generated file", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoGenerate)
+
+		val, err := env.ExecuteActivity(pa.GoGenerate, GoGenerateParams{Metadata: PipelineActivityMetadata{Workdir: repo(t, "generated\n")}})
+		assert.NoError(t, err)
+
+		var result GoGenerateResult
+		assert.NoError(t, val.Get(&result))
+		assert.Empty(t, result.FailedFiles)
+	})
+
+	t.Run("Changes to the workdir by other checks", func(t *testing.T) {
+		workdir := repo(t, "generated\n")
+		// As rewritten by GoFmt while go generate runs.
+		assert.NoError(t, os.WriteFile(filepath.Join(workdir, "gen.go"), []byte("package gen\n\n//go:generate sh -c \"echo generated > gen.txt\"\n\n"), 0o644))
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoGenerate)
+
+		val, err := env.ExecuteActivity(pa.GoGenerate, GoGenerateParams{Metadata: PipelineActivityMetadata{Workdir: workdir}})
+		assert.NoError(t, err)
+
+		var result GoGenerateResult
+		assert.NoError(t, val.Get(&result))
+		assert.Empty(t, result.FailedFiles)
+		worktrees, err := runGit(context.Background(), workdir, "worktree", "list")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(worktrees, "\n")+1, "the worktree is removed")
+		siblings, err := os.ReadDir(filepath.Dir(workdir))
+		assert.NoError(t, err)
+		assert.Len(t, siblings, 1)
+	})
+}
+
+func TestRedactEnv(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"CGO_ENABLED":  "0",
+		"GOPRIVATE":    "example.com/private",
+		"GITHUB_TOKEN": "REDACTED",
+		"NPM_AUTH":     "REDACTED",
+		"DB_PASSWORD":  "REDACTED",
+		"AWS_KEY_ID":   "REDACTED",
+	}, redactEnv(map[string]string{
+		"CGO_ENABLED":  "0",
+		"GOPRIVATE":    "example.com/private",
+		"GITHUB_TOKEN": "ghp_123",
+		"NPM_AUTH":     "abc",
+		"DB_PASSWORD":  "hunter2",
+		"AWS_KEY_ID":   "AKIA",
+	}))
+}
+
+func TestGoTestActivity(t *testing.T) {
+	t.Run("Package that does not compile is reported", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoTest)
+
+		val, err := env.ExecuteActivity(pa.GoTest, GoTestParams{
+			Metadata: PipelineActivityMetadata{Workdir: filepath.Join("testdata", "uncompilable")},
+		})
+		assert.NoError(t, err)
+
+		var result GoTestResult
+		assert.NoError(t, val.Get(&result))
+		assert.Empty(t, result.FailedTests)
+		if assert.Len(t, result.FailedPackages, 1) {
+			assert.Equal(t, "example.com/uncompilable", result.FailedPackages[0].Package)
+			assert.Contains(t, result.FailedPackages[0].Output, "cannot use Answer()")
+		}
+		assert.Positive(t, result.ElapsedMs)
+	})
+
+	t.Run("Tests passing on a retry are flaky", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoTest)
+
+		val, err := env.ExecuteActivity(pa.GoTest, GoTestParams{
+			Metadata: PipelineActivityMetadata{
+				Workdir: filepath.Join("testdata", "flaky"),
+				Env:     map[string]string{"FLAKY_STATE": filepath.Join(t.TempDir(), "flaked")},
+			},
+			RetryFailedTests: 2,
+		})
+		assert.NoError(t, err)
+
+		var result GoTestResult
+		assert.NoError(t, val.Get(&result))
+		var failed, flaky []string
+		for _, test := range result.FailedTests {
+			failed = append(failed, test.Test)
+		}
+		for _, test := range result.FlakyTests {
+			flaky = append(flaky, test.Test)
+		}
+		assert.Equal(t, []string{"TestBroken"}, failed)
+		assert.Equal(t, []string{"TestFlaky/case", "TestFlaky"}, flaky)
+		assert.Empty(t, result.FailedPackages, "TestBroken reports the failure of its package")
+	})
+
+	t.Run("Packages are reported once", func(t *testing.T) {
+		fakeTool(t, "go", `echo '{"Action":"fail","Package":"example.com/api","Test":"TestGet"}'
+echo '{"Action":"fail","Package":"example.com/api"}'
+echo '{"Action":"fail","Package":"example.com/store","Output":"FAIL example.com/store [build failed]\\n"}'
+exit 1
+`)
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoTest)
+
+		val, err := env.ExecuteActivity(pa.GoTest, GoTestParams{Metadata: PipelineActivityMetadata{Workdir: t.TempDir()}})
+		assert.NoError(t, err)
+
+		var result GoTestResult
+		assert.NoError(t, val.Get(&result))
+		if assert.Len(t, result.FailedTests, 1) {
+			assert.Equal(t, "TestGet", result.FailedTests[0].Test)
+		}
+		// Only the package failing without a failing test, on a build error.
+		if assert.Len(t, result.FailedPackages, 1) {
+			assert.Equal(t, "example.com/store", result.FailedPackages[0].Package)
+		}
+	})
+
+	t.Run("Failures past the output cap are reported", func(t *testing.T) {
+		defer func(limit int) { MaxCommandOutput = limit }(MaxCommandOutput)
+		MaxCommandOutput = 1000
+		fakeTool(t, "go", `for i in $(seq 1 200); do echo '{"Action":"pass","Package":"example.com/big","Test":"TestPass'$i'"}'; done
+echo '{"Action":"fail","Package":"example.com/big","Test":"TestFail"}'
+for i in $(seq 1 200); do echo '{"Action":"pass","Package":"example.com/big","Test":"TestLate'$i'"}'; done
+exit 1
+`)
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoTest)
+
+		val, err := env.ExecuteActivity(pa.GoTest, GoTestParams{Metadata: PipelineActivityMetadata{Workdir: t.TempDir()}})
+		assert.NoError(t, err)
+
+		var result GoTestResult
+		assert.NoError(t, val.Get(&result))
+		assert.Len(t, result.PassedTests, 400)
+		if assert.Len(t, result.FailedTests, 1) {
+			assert.Equal(t, "TestFail", result.FailedTests[0].Test)
+		}
+	})
+
+	t.Run("Oversized result is capped", func(t *testing.T) {
+		fakeTool(t, "go", `for i in $(seq 1 20000); do echo '{"Action":"pass","Package":"example.com/big","Test":"TestPassingWithAVeryLongNameToFillTheResult'$i'"}'; done
+echo '{"Action":"fail","Package":"example.com/big","Test":"TestFail"}'
+exit 1
+`)
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoTest)
+
+		val, err := env.ExecuteActivity(pa.GoTest, GoTestParams{Metadata: PipelineActivityMetadata{Workdir: t.TempDir()}})
+		assert.NoError(t, err)
+
+		var result GoTestResult
+		assert.NoError(t, val.Get(&result))
+		assert.LessOrEqual(t, goTestResultSize(&result), maxResultBytes)
+		assert.Empty(t, result.PassedTests)
+		assert.Len(t, result.FailedTests, 1)
+		assert.Equal(t, &ResultSummary{PassedTests: 20000, FailedTests: 1}, result.Summary)
+	})
+
+	t.Run("Failing without a fail event is an error", func(t *testing.T) {
+		fakeTool(t, "go", "echo 'flag provided but not defined: -bogus' >&2\nexit 2\n")
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoTest)
+
+		_, err := env.ExecuteActivity(pa.GoTest, GoTestParams{Metadata: PipelineActivityMetadata{Workdir: t.TempDir()}})
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.Equal(t, "ToolFailed", appErr.Type())
+			assert.True(t, appErr.NonRetryable())
+		}
+		assert.ErrorContains(t, err, "flag provided but not defined")
+	})
+}
+
+func TestRetryTestArgs(t *testing.T) {
+	params := GoTestParams{Flags: []string{"-run", "TestAll", "-v"}, Race: true, Packages: []string{"./..."}}
+	assert.Equal(t, []string{"test", "-json", "-race", "example.com/app/api", "-run", "TestAll", "-v", "-count=1", "-run", `^(TestA|TestB\.x)$`},
+		retryTestArgs(params, "example.com/app/api", map[string]bool{"TestB.x": true, "TestA": true}))
+}
+
+func TestMeasureWorkdirActivity(t *testing.T) {
+	workdir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(workdir, "main.go"), []byte("package main\n"), 0o644))
+	modCache := filepath.Join(workdir, "gomodcache")
+	assert.NoError(t, os.MkdirAll(modCache, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(modCache, "module.zip"), make([]byte, 1024), 0o644))
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.MeasureWorkdir)
+
+	val, err := env.ExecuteActivity(pa.MeasureWorkdir, MeasureWorkdirParams{
+		Metadata: PipelineActivityMetadata{Workdir: workdir, GoModCache: modCache},
+	})
+	assert.NoError(t, err)
+
+	var result MeasureWorkdirResult
+	assert.NoError(t, val.Get(&result))
+	assert.Equal(t, int64(len("package main\n")), result.Bytes, "the shared module cache is not counted")
+}
+
+func TestGoModTidyActivity(t *testing.T) {
+	// GOPROXY=off: a module without dependencies needs no downloads.
+	metadata := func(workdir string) PipelineActivityMetadata {
+		return PipelineActivityMetadata{Workdir: workdir, Env: map[string]string{"GOPROXY": "off"}}
+	}
+	module := func(t *testing.T, goMod string) string {
+		workdir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(workdir, "go.mod"), []byte(goMod), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(workdir, "tidy.go"), []byte("package tidy\n"), 0o644))
+		return workdir
+	}
+
+	t.Run("Untidy go.mod", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoModTidy)
+
+		val, err := env.ExecuteActivity(pa.GoModTidy, GoModTidyParams{Metadata: metadata(module(t, "module example.com/tidy\n\ngo 1.22\n\nrequire ()\n"))})
+		assert.NoError(t, err)
+
+		var result GoModTidyResult
+		assert.NoError(t, val.Get(&result))
+		assert.Equal(t, []string{"go.mod"}, result.FailedFiles)
+	})
+
+	t.Run("Tidy go.mod", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoModTidy)
+
+		val, err := env.ExecuteActivity(pa.GoModTidy, GoModTidyParams{Metadata: metadata(module(t, "module example.com/tidy\n\ngo 1.22\n"))})
+		assert.NoError(t, err)
+
+		var result GoModTidyResult
+		assert.NoError(t, val.Get(&result))
+		assert.Empty(t, result.FailedFiles)
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoModVerify)
+
+		val, err := env.ExecuteActivity(pa.GoModVerify, GoModVerifyParams{Metadata: metadata(module(t, "module example.com/tidy\n\ngo 1.22\n"))})
+		assert.NoError(t, err)
+
+		var result GoModVerifyResult
+		assert.NoError(t, val.Get(&result))
+		assert.Empty(t, result.FailedModules)
+		assert.Contains(t, result.Logs, "all modules verified")
+	})
+}
+
+func TestGoFmtActivity(t *testing.T) {
+	t.Run("CheckOnly lists unformatted files without rewriting them", func(t *testing.T) {
+		workdir := filepath.Join("testdata", "unformatted")
+		before, err := os.ReadFile(filepath.Join(workdir, "unformatted.go"))
+		assert.NoError(t, err)
+
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoFmt)
+
+		val, err := env.ExecuteActivity(pa.GoFmt, GoFmtParams{
+			Metadata:  PipelineActivityMetadata{Workdir: workdir},
+			CheckOnly: true,
+		})
+		assert.NoError(t, err)
+
+		var result GoFmtResult
+		assert.NoError(t, val.Get(&result))
+		assert.Equal(t, []string{"unformatted.go"}, result.FailedFiles)
+
+		after, err := os.ReadFile(filepath.Join(workdir, "unformatted.go"))
+		assert.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("Files that don't parse are not retried", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoFmt)
+
+		_, err := env.ExecuteActivity(pa.GoFmt, GoFmtParams{
+			Metadata:  PipelineActivityMetadata{Workdir: filepath.Join("testdata", "unparsable")},
+			CheckOnly: true,
+		})
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.True(t, appErr.NonRetryable())
+			assert.Equal(t, "ToolFailed", appErr.Type())
+			assert.Contains(t, appErr.Error(), "unparsable.go:3:14")
+		}
+	})
+
+	t.Run("A missing gofumpt is not retried", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.GoFmt)
+
+		_, err := env.ExecuteActivity(pa.GoFmt, GoFmtParams{
+			Metadata:  PipelineActivityMetadata{Workdir: t.TempDir()},
+			Formatter: FormatterGofumpt,
+		})
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.True(t, appErr.NonRetryable())
+			assert.Equal(t, "ToolNotFound", appErr.Type())
+			assert.Contains(t, appErr.Error(), "gofumpt not found in PATH")
+		}
+	})
+}
+
+func TestFmtCommand(t *testing.T) {
+	bin := t.TempDir()
+	writeScript(t, filepath.Join(bin, "gofumpt"), "")
+	metadata := PipelineActivityMetadata{BinDir: bin}
+
+	for _, tt := range []struct {
+		params GoFmtParams
+		name   string
+		args   []string
+	}{
+		{GoFmtParams{}, "go", []string{"fmt", "./..."}},
+		{GoFmtParams{Formatter: FormatterGofmt, CheckOnly: true}, "gofmt", []string{"-l", "."}},
+		{GoFmtParams{Formatter: FormatterGofumpt}, "gofumpt", []string{"-l", "-w", "."}},
+		{GoFmtParams{Formatter: FormatterGofumpt, CheckOnly: true}, "gofumpt", []string{"-l", "."}},
+		{GoFmtParams{Metadata: metadata, Formatter: FormatterGofumpt}, filepath.Join(bin, "gofumpt"), []string{"-l", "-w", "."}},
+	} {
+		name, args := fmtCommand(tt.params)
+		assert.Equal(t, tt.name, name, "%+v", tt.params)
+		assert.Equal(t, tt.args, args, "%+v", tt.params)
+	}
+
+	params := PipelineParams{GitURL: gitUrl, Formatter: "goimports"}
+	assert.ErrorContains(t, params.Validate(), `Formatter must be "gofmt" or "gofumpt"`)
+}
+
+func TestChecksNotRetried(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoFmt, mock.Anything, mock.Anything).Return(nil, toolError("gofmt", exec.Command("false").Run(), "x.go:1:1: expected 'package'"))
+	env.OnActivity(pa.GolangCILint, mock.Anything, mock.Anything).Return(&GolangCILintResult{Issues: []LintIssue{{Linter: "errcheck", Text: "unchecked error"}}}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	if assert.Len(t, result.Failures, 2) {
+		assert.Equal(t, FailureError, result.Failures[0].Kind, "GoFmt failed to run")
+		assert.Equal(t, FailureIssues, result.Failures[1].Kind, "GolangCILint found issues")
+	}
+	// Issues found, or a tool failing the same way each time, are reported after one attempt.
+	env.AssertNumberOfCalls(t, "GoFmt", 1)
+	env.AssertNumberOfCalls(t, "GolangCILint", 1)
+}
+
+func TestFailureOrder(t *testing.T) {
+	// The checks of web finish first, and GoVulnCheck before GoBuild, yet the failures are
+	// ordered by activity, then repository.
+	delay := func(subdir string, d time.Duration) time.Duration {
+		if subdir == "web" {
+			return 0
+		}
+		return d
+	}
+	env := newTestWorkflowEnvironment()
+	for _, subdir := range []string{"api", "web"} {
+		workdir := filepath.Join("/tmp/test", subdir)
+		env.OnActivity(pa.GoBuild, mock.Anything, mock.MatchedBy(func(p GoBuildParams) bool { return p.Metadata.Workdir == workdir })).
+			After(delay(subdir, 3*time.Second)+2*time.Second).Return(&GoBuildResult{FailedFiles: []string{subdir + "/main.go"}}, nil)
+		env.OnActivity(pa.GoVulnCheck, mock.Anything, mock.MatchedBy(func(p GoVulnCheckParams) bool { return p.Metadata.Workdir == workdir })).
+			After(delay(subdir, 3*time.Second)).Return(&GoVulnCheckResult{Vulns: []Vulnerability{{ID: "GO-2024-0001"}}}, nil)
+	}
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{Repos: []RepoSpec{
+		{URL: "https://example.com/web.git", Subdir: "web"},
+		{URL: "https://example.com/api.git", Subdir: "api"},
+	}})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	var order []string
+	for _, failure := range result.Failures {
+		order = append(order, checkStepName(failure.Activity, failure.Repo))
+	}
+	assert.Equal(t, []string{"GoBuild (api)", "GoBuild (web)", "GoVulnCheck (api)", "GoVulnCheck (web)"}, order)
+}
+
+func TestCheckTimeout(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoVulnCheck, mock.Anything, mock.Anything).Return(nil, temporal.NewTimeoutError(enumspb.TIMEOUT_TYPE_START_TO_CLOSE, nil))
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	if assert.Len(t, result.Failures, 1) {
+		assert.Equal(t, "GoVulnCheck", result.Failures[0].Activity)
+		assert.Equal(t, FailureTimeout, result.Failures[0].Kind)
+	}
+}
+
+// TestWorkflowLogging checks that the functions running in workflows, those taking a
+// workflow.Context, don't log or print other than with workflow.GetLogger: output written
+// directly would be repeated each time a worker replays the workflow's history.
+func TestWorkflowLogging(t *testing.T) {
+	fset := token.NewFileSet()
+	files, err := filepath.Glob("*.go")
+	assert.NoError(t, err)
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil || !takesWorkflowContext(fn.Type) {
+				continue
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				pkg, ok := sel.X.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				switch {
+				case pkg.Name == "slog", pkg.Name == "log",
+					pkg.Name == "fmt" && (strings.HasPrefix(sel.Sel.Name, "Print") || strings.HasPrefix(sel.Sel.Name, "Fprint")),
+					pkg.Name == "os" && (sel.Sel.Name == "Stdout" || sel.Sel.Name == "Stderr"):
+					t.Errorf("%s: %s uses %s.%s, log with workflow.GetLogger instead", fset.Position(sel.Pos()), fn.Name.Name, pkg.Name, sel.Sel.Name)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// takesWorkflowContext reports whether a function has a workflow.Context parameter.
+func takesWorkflowContext(fn *ast.FuncType) bool {
+	for _, param := range fn.Params.List {
+		if sel, ok := param.Type.(*ast.SelectorExpr); ok && sel.Sel.Name == "Context" {
+			if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "workflow" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newTestWorkflowEnvironment() *testsuite.TestWorkflowEnvironment {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	// Mock GitClone, ReadRepoConfig, ReadEnvFile, GoModDownload, MeasureWorkdir and DeleteWorkdir for all tests
+	env.OnActivity(pa.GitClone, mock.Anything, mock.Anything).Return(&GitCloneResult{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}}, nil)
+	mockSetupActivities(env)
+
+	return env
+}
+
+// mockSetupActivities mocks the activities around the checks other than GitClone, for tests
+// mocking GitClone themselves: the first matching mock wins.
+func mockSetupActivities(env *testsuite.TestWorkflowEnvironment) {
+	env.OnActivity(pa.ReadRepoConfig, mock.Anything, mock.Anything).Return(&ReadRepoConfigResult{}, nil)
+	env.OnActivity(pa.ReadEnvFile, mock.Anything, mock.Anything).Return(&ReadEnvFileResult{}, nil)
+	env.OnActivity(pa.GoModDownload, mock.Anything, mock.Anything).Return(&GoModDownloadResult{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test", GoModCache: "/tmp/gomodcache"}}, nil)
+	env.OnActivity(pa.MeasureWorkdir, mock.Anything, mock.Anything).Return(&MeasureWorkdirResult{Bytes: 4096}, nil)
+	env.OnActivity(pa.DeleteWorkdir, mock.Anything, mock.Anything).Return(nil)
+}
+
+func mockAllActivitiesSuccess(env *testsuite.TestWorkflowEnvironment) {
+	// all 9 passes
+	env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).Return(&GoTestResult{}, nil)
+	env.OnActivity(pa.GoFmt, mock.Anything, mock.Anything).Return(&GoFmtResult{}, nil)
+	env.OnActivity(pa.GoModTidy, mock.Anything, mock.Anything).Return(&GoModTidyResult{}, nil)
+	env.OnActivity(pa.GoModVerify, mock.Anything, mock.Anything).Return(&GoModVerifyResult{}, nil)
+	env.OnActivity(pa.GoBuild, mock.Anything, mock.Anything).Return(&GoBuildResult{}, nil)
+	env.OnActivity(pa.GoGenerate, mock.Anything, mock.Anything).Return(&GoGenerateResult{}, nil)
+	env.OnActivity(pa.GolangCILint, mock.Anything, mock.Anything).Return(&GolangCILintResult{}, nil)
+	env.OnActivity(pa.GoVulnCheck, mock.Anything, mock.Anything).Return(&GoVulnCheckResult{}, nil)
+	env.OnActivity(pa.GoDeploy, mock.Anything, mock.Anything).Return(&GoDeployResult{}, nil)
+}
+
+func mockActivitiesWithFailures(env *testsuite.TestWorkflowEnvironment) {
+	// 5 passes
+	env.OnActivity(pa.GoFmt, mock.Anything, mock.Anything).Return(&GoFmtResult{}, nil)
+	env.OnActivity(pa.GoModTidy, mock.Anything, mock.Anything).Return(&GoModTidyResult{}, nil)
+	env.OnActivity(pa.GoModVerify, mock.Anything, mock.Anything).Return(&GoModVerifyResult{}, nil)
+	env.OnActivity(pa.GolangCILint, mock.Anything, mock.Anything).Return(&GolangCILintResult{}, nil)
+	env.OnActivity(pa.GoVulnCheck, mock.Anything, mock.Anything).Return(&GoVulnCheckResult{}, nil)
+
+	// 3 failures
+	env.OnActivity(pa.GoBuild, mock.Anything, mock.Anything).Return(&GoBuildResult{FailedFiles: []string{"main.go"}}, nil)
+	env.OnActivity(pa.GoGenerate, mock.Anything, mock.Anything).Return(&GoGenerateResult{FailedFiles: []string{"generated.go"}}, nil)
+	env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).Return(&GoTestResult{FailedTests: []GoTestCLIOutput{{Test: "TestFailed"}}}, nil)
+}
+
+func TestSubdir(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		params := PipelineParams{GitURL: gitUrl, Subdir: "../foo"}
+		assert.ErrorContains(t, params.Validate(), "must be a relative path")
+
+		params = PipelineParams{Repos: []RepoSpec{{URL: gitUrl, Subdir: "a"}}, Subdir: "services/foo"}
+		assert.ErrorContains(t, params.Validate(), "Subdir cannot be used with Repos")
+	})
+
+	env := newTestWorkflowEnvironment()
+	var testedIn string
+	env.OnActivity(pa.GoTest, mock.Anything, mock.Anything).Return(func(_ context.Context, params GoTestParams) (*GoTestResult, error) {
+		testedIn = params.Metadata.Workdir
+		return &GoTestResult{}, nil
+	})
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, Subdir: "services/foo"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	assert.Equal(t, "/tmp/test/services/foo", testedIn)
+	env.AssertCalled(t, "GitClone", mock.Anything, GitCloneParams{Remote: gitUrl, Dir: "services/foo"})
+	// The whole workdir is cleaned up, not just the subdirectory.
+	env.AssertCalled(t, "DeleteWorkdir", mock.Anything, mock.MatchedBy(func(params DeleteWorkdirParams) bool {
+		return params.Metadata.Workdir == "/tmp/test"
+	}))
 }
@@ -0,0 +1,178 @@
+package pipeline
+
+import "time"
+
+// ProgressQueryName is the query that returns the PipelineProgress of a running PipelineWorkflow.
+const ProgressQueryName = "progress"
+
+type StepState string
+
+const (
+	StepPending StepState = "pending"
+	StepRunning StepState = "running"
+	StepDone    StepState = "done"
+	StepFailed  StepState = "failed"
+	StepSkipped StepState = "skipped"
+)
+
+type StepProgress struct {
+	Name  string    `json:"name"`
+	State StepState `json:"state"`
+	// Duration is how long the step ran, once it finished.
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// PipelineProgress lists every step of a pipeline run, in execution order, with its current state.
+type PipelineProgress struct {
+	Steps []StepProgress `json:"steps"`
+
+	// now returns the current time, workflow.Now in a workflow. When nil, durations aren't recorded.
+	now     func() time.Time
+	started map[string]time.Time
+}
+
+// newPipelineProgress returns the steps the workflow will run for params, all pending.
+func newPipelineProgress(params PipelineParams) *PipelineProgress {
+	var names []string
+	if params.MinFreeBytes > 0 {
+		names = append(names, "CheckDiskSpace")
+	}
+	if params.TarballURL != "" {
+		names = append(names, "FetchTarball")
+	} else {
+		names = append(names, "GitClone")
+	}
+	names = append(names, "ReadRepoConfig", "ReadEnvFile")
+	if !params.NoCache {
+		names = append(names, "GoModDownload")
+	}
+	if len(params.Tools) > 0 {
+		names = append(names, "GoInstallTools")
+	}
+	if params.DiffBase != "" {
+		names = append(names, "DetectChanges")
+	}
+	if params.CommitRange != "" {
+		names = append(names, "CommitRange", "DeleteWorkdir")
+		if hasNotifications(params) {
+			names = append(names, "Notify")
+		}
+		return newProgress(names)
+	}
+	repos := params.RepoSpecs()
+	for _, repo := range repos {
+		for _, name := range []string{"GoTest", "GoFmt", "GoModTidy", "GoModVerify", "GoBuild", "GoGenerate", "GolangCILint", "GoVulnCheck"} {
+			names = append(names, checkStepName(name, repo.Subdir))
+		}
+		if params.Staticcheck {
+			names = append(names, checkStepName("GoStaticcheck", repo.Subdir))
+		}
+		if len(params.AllowedLicenses) > 0 {
+			names = append(names, checkStepName("GoLicenseCheck", repo.Subdir))
+		}
+		if params.DocCheck {
+			names = append(names, checkStepName("GoDocCheck", repo.Subdir))
+		}
+	}
+	if params.BenchmarkPattern != "" {
+		names = append(names, checkStepName("GoBenchmark", repos[0].Subdir))
+	}
+	for _, step := range params.CustomSteps {
+		names = append(names, checkStepName(step.Name, repos[0].Subdir))
+	}
+	if params.Matrix != nil {
+		names = append(names, "Matrix")
+	}
+	if len(params.PreDeployCommand) > 0 {
+		names = append(names, "PreDeploy")
+	}
+	if params.Sign != nil {
+		names = append(names, "Sign")
+	}
+	if params.DeployGate != nil {
+		names = append(names, "DeployGate")
+	}
+	if params.RequireApproval {
+		names = append(names, "Approval")
+	}
+	names = append(names, "Deploy")
+	if params.AutoRollback {
+		names = append(names, "Rollback")
+	}
+	names = append(names, "DeleteWorkdir")
+	if hasNotifications(params) {
+		names = append(names, "Notify")
+	}
+	return newProgress(names)
+}
+
+// newProgress returns the named steps, all pending.
+func newProgress(names []string) *PipelineProgress {
+	progress := &PipelineProgress{Steps: make([]StepProgress, 0, len(names))}
+	for _, name := range names {
+		progress.Steps = append(progress.Steps, StepProgress{Name: name, State: StepPending})
+	}
+	return progress
+}
+
+// set updates the state of the named step, adding it if it isn't known yet.
+func (p *PipelineProgress) set(name string, state StepState) {
+	i := 0
+	for i < len(p.Steps) && p.Steps[i].Name != name {
+		i++
+	}
+	if i == len(p.Steps) {
+		p.Steps = append(p.Steps, StepProgress{Name: name})
+	}
+	p.Steps[i].State = state
+
+	if p.now == nil {
+		return
+	}
+	switch state {
+	case StepRunning:
+		if p.started == nil {
+			p.started = map[string]time.Time{}
+		}
+		p.started[name] = p.now()
+	case StepDone, StepFailed:
+		if started, ok := p.started[name]; ok {
+			p.Steps[i].Duration = p.now().Sub(started)
+		}
+	}
+}
+
+// skipPending marks every step that hasn't started as skipped.
+func (p *PipelineProgress) skipPending() {
+	for i := range p.Steps {
+		if p.Steps[i].State == StepPending {
+			p.Steps[i].State = StepSkipped
+		}
+	}
+}
+
+// skip marks the named step skipped if it hasn't started.
+func (p *PipelineProgress) skip(name string) {
+	for i := range p.Steps {
+		if p.Steps[i].Name == name && p.Steps[i].State == StepPending {
+			p.Steps[i].State = StepSkipped
+		}
+	}
+}
+
+// finish marks the named step done, or failed if err is not nil.
+func (p *PipelineProgress) finish(name string, err error) {
+	if err != nil {
+		p.set(name, StepFailed)
+		return
+	}
+	p.set(name, StepDone)
+}
+
+// stepStateFor returns the state of a step that just ran: failed once the pipeline has any failure.
+func stepStateFor(result *PipelineResult) StepState {
+	if hasErrors(result) {
+		return StepFailed
+	}
+	return StepDone
+}
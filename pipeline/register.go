@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"go.temporal.io/sdk/worker"
+)
+
+// RegisterAll registers every activity of pa: the resource-intensive ones, which the workflow
+// schedules on HeavyTaskQueue, with heavy, and all the others with w. It fails when an activity
+// isn't registered exactly once, so a missing registration fails the worker at startup rather
+// than a pipeline midway.
+func (pa *PipelineActivity) RegisterAll(w, heavy worker.ActivityRegistry) error {
+	registered := &recordingRegistry{ActivityRegistry: w}
+	for _, activity := range pa.activities() {
+		registered.RegisterActivity(activity)
+	}
+	heavyRegistered := &recordingRegistry{ActivityRegistry: heavy}
+	for _, activity := range pa.heavyActivities() {
+		heavyRegistered.RegisterActivity(activity)
+	}
+	return checkRegistrations(append(registered.names, heavyRegistered.names...))
+}
+
+// activities are the activities run on the pipeline's own task queue.
+func (pa *PipelineActivity) activities() []any {
+	return []any{
+		pa.CheckDiskSpace,
+		pa.GitClone,
+		pa.FetchTarball,
+		pa.ListCommits,
+		pa.GitCheckout,
+		pa.ReadRepoConfig,
+		pa.ReadEnvFile,
+		pa.GoModDownload,
+		pa.GoInstallTools,
+		pa.DetectChanges,
+		pa.GoFmt,
+		pa.GoGenerate,
+		pa.GoDocCheck,
+		pa.GoModTidy,
+		pa.GoModVerify,
+		pa.PreDeploy,
+		pa.SignArtifacts,
+		pa.CheckDeployGate,
+		pa.GoDeploy,
+		pa.K8sDeploy,
+		pa.Rollback,
+		pa.SmokeTest,
+		pa.MeasureWorkdir,
+		pa.DeleteWorkdir,
+		pa.Notify,
+		pa.NotifyEmail,
+		pa.NotifyWebhook,
+		pa.GitHubStatus,
+		pa.TriggerIncident,
+		pa.UploadArtifact,
+		pa.ArchiveResult,
+	}
+}
+
+// heavyActivities are the build, test and analysis activities, and the custom steps, run on
+// HeavyTaskQueue.
+func (pa *PipelineActivity) heavyActivities() []any {
+	return []any{
+		pa.GoTest,
+		pa.GoBuild,
+		pa.GolangCILint,
+		pa.GoStaticcheck,
+		pa.GoLicenseCheck,
+		pa.GoVulnCheck,
+		pa.GoBenchmark,
+		pa.RunScript,
+	}
+}
+
+// recordingRegistry records the names of the activities registered with its ActivityRegistry.
+type recordingRegistry struct {
+	worker.ActivityRegistry
+	names []string
+}
+
+func (r *recordingRegistry) RegisterActivity(a interface{}) {
+	r.ActivityRegistry.RegisterActivity(a)
+	r.names = append(r.names, activityName(a))
+}
+
+// activityName is the name Temporal registers the activity function a under, e.g. GoTest for
+// the method value pa.GoTest.
+func activityName(a interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(a).Pointer()).Name()
+	return strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+}
+
+// activityNames are the names of the activities of PipelineActivity: its exported methods taking
+// a context first.
+func activityNames() []string {
+	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
+	paType := reflect.TypeOf(&PipelineActivity{})
+	var names []string
+	for i := range paType.NumMethod() {
+		method := paType.Method(i)
+		if method.Type.NumIn() >= 2 && method.Type.In(1) == contextType {
+			names = append(names, method.Name)
+		}
+	}
+	return names
+}
+
+// checkRegistrations verifies that every activity of PipelineActivity is among the registered
+// names exactly once, across both task queues.
+func checkRegistrations(registered []string) error {
+	counts := map[string]int{}
+	for _, name := range registered {
+		counts[name]++
+	}
+	var problems []string
+	for _, name := range activityNames() {
+		switch counts[name] {
+		case 0:
+			problems = append(problems, fmt.Sprintf("%s is not registered", name))
+		case 1:
+		default:
+			problems = append(problems, fmt.Sprintf("%s is registered %d times", name, counts[name]))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
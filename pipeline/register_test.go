@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.temporal.io/sdk/activity"
+)
+
+// nopRegistry discards the activities registered with it.
+type nopRegistry struct{}
+
+func (nopRegistry) RegisterActivity(interface{})                                      {}
+func (nopRegistry) RegisterActivityWithOptions(interface{}, activity.RegisterOptions) {}
+
+func TestRegisterAll(t *testing.T) {
+	w, heavy := &recordingRegistry{ActivityRegistry: nopRegistry{}}, &recordingRegistry{ActivityRegistry: nopRegistry{}}
+	assert.NoError(t, (&PipelineActivity{}).RegisterAll(w, heavy))
+	assert.Contains(t, w.names, "GitClone")
+	assert.Contains(t, heavy.names, "GoTest")
+
+	// Every activity the workflows schedule, found in their source, is registered.
+	registered := map[string]bool{}
+	for _, name := range append(w.names, heavy.names...) {
+		registered[name] = true
+	}
+	for _, name := range scheduledActivities(t) {
+		assert.True(t, registered[name], "activity %s", name)
+	}
+}
+
+func TestCheckRegistrations(t *testing.T) {
+	names := activityNames()
+	assert.Contains(t, names, "GitClone")
+	assert.NotContains(t, names, "RegisterAll")
+	assert.NoError(t, checkRegistrations(names))
+
+	var withoutGitClone []string
+	for _, name := range names {
+		if name != "GitClone" {
+			withoutGitClone = append(withoutGitClone, name)
+		}
+	}
+	assert.EqualError(t, checkRegistrations(withoutGitClone), "GitClone is not registered")
+	assert.EqualError(t, checkRegistrations(append(names, "GoTest")), "GoTest is registered 2 times")
+}
+
+// scheduledActivities returns the names of the activities passed as pa.<Name> to
+// workflow.ExecuteActivity in the package's source.
+func scheduledActivities(t *testing.T) []string {
+	fset := token.NewFileSet()
+	files, err := filepath.Glob("*.go")
+	assert.NoError(t, err)
+	var names []string
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) < 2 {
+				return true
+			}
+			if fn, ok := call.Fun.(*ast.SelectorExpr); !ok || fn.Sel.Name != "ExecuteActivity" {
+				return true
+			}
+			if sel, ok := call.Args[1].(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == "pa" {
+					names = append(names, sel.Sel.Name)
+				}
+			}
+			return true
+		})
+	}
+	assert.NotEmpty(t, names)
+	return names
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.temporal.io/sdk/activity"
+	"gopkg.in/yaml.v3"
+)
+
+// RepoConfigFile is the optional pipeline config read from the root of the cloned repository.
+const RepoConfigFile = ".pipeline.yaml"
+
+// RepoConfig is the subset of PipelineParams a repository may set for itself.
+type RepoConfig struct {
+	TestFlags     []string `json:"test_flags" yaml:"test_flags"`
+	BuildFlags    []string `json:"build_flags" yaml:"build_flags"`
+	GenerateFlags []string `json:"generate_flags" yaml:"generate_flags"`
+	LintFlags     []string `json:"lint_flags" yaml:"lint_flags"`
+}
+
+// ReadRepoConfig params and results
+type ReadRepoConfigParams struct {
+	Metadata PipelineActivityMetadata
+}
+
+type ReadRepoConfigResult struct {
+	// Found is false when the repository has no RepoConfigFile.
+	Found  bool
+	Config RepoConfig
+}
+
+// ReadRepoConfig reads RepoConfigFile from the workdir, if present.
+func (pa *PipelineActivity) ReadRepoConfig(ctx context.Context, params ReadRepoConfigParams) (*ReadRepoConfigResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &ReadRepoConfigResult{}
+
+	path := filepath.Join(params.Metadata.Workdir, RepoConfigFile)
+	activityLogger(ctx).Info("Reading repository pipeline config", "path", path)
+
+	f, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.Info("No repository pipeline config found")
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", RepoConfigFile, err)
+	}
+	if err := yaml.Unmarshal(f, &result.Config); err != nil {
+		return nil, fmt.Errorf("unmarshalling %s: %w", RepoConfigFile, err)
+	}
+	result.Found = true
+
+	return result, nil
+}
+
+// mergeRepoConfig fills in step flags from the repository config. The externally provided
+// params take precedence: a repository value is only used when params leaves that field empty.
+func mergeRepoConfig(params PipelineParams, config RepoConfig) PipelineParams {
+	if len(params.TestFlags) == 0 {
+		params.TestFlags = config.TestFlags
+	}
+	if len(params.BuildFlags) == 0 {
+		params.BuildFlags = config.BuildFlags
+	}
+	if len(params.GenerateFlags) == 0 {
+		params.GenerateFlags = config.GenerateFlags
+	}
+	if len(params.LintFlags) == 0 {
+		params.LintFlags = config.LintFlags
+	}
+	return params
+}
@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestReadRepoConfig(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.ReadRepoConfig)
+
+	t.Run("Repository with a config", func(t *testing.T) {
+		val, err := env.ExecuteActivity(pa.ReadRepoConfig, ReadRepoConfigParams{
+			Metadata: PipelineActivityMetadata{Workdir: filepath.Join("testdata", "repoconfig")},
+		})
+		assert.NoError(t, err)
+
+		var result ReadRepoConfigResult
+		assert.NoError(t, val.Get(&result))
+		assert.True(t, result.Found)
+
+		// External params win; fields they leave empty come from the repository.
+		merged := mergeRepoConfig(PipelineParams{
+			GitURL:     gitUrl,
+			BuildFlags: []string{"-race"},
+		}, result.Config)
+		assert.Equal(t, []string{"-tags", "integration"}, merged.TestFlags)
+		assert.Equal(t, []string{"-race"}, merged.BuildFlags)
+		assert.Empty(t, merged.GenerateFlags)
+		assert.Equal(t, []string{"--timeout", "5m"}, merged.LintFlags)
+	})
+
+	t.Run("Repository without a config", func(t *testing.T) {
+		val, err := env.ExecuteActivity(pa.ReadRepoConfig, ReadRepoConfigParams{
+			Metadata: PipelineActivityMetadata{Workdir: filepath.Join("testdata", "uncompilable")},
+		})
+		assert.NoError(t, err)
+
+		var result ReadRepoConfigResult
+		assert.NoError(t, val.Get(&result))
+		assert.False(t, result.Found)
+	})
+}
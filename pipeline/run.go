@@ -3,54 +3,188 @@ package pipeline
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"net/smtp"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 )
 
 // PipelineActivity is a collection of Temporal Activities invokeable by PipelineWorkflow.
-type PipelineActivity struct{}
+type PipelineActivity struct {
+	// SMTPUsername and SMTPPassword authenticate NotifyEmail. They are worker configuration,
+	// so credentials never travel through workflow history.
+	SMTPUsername string
+	SMTPPassword string
+	// SendMail sends NotifyEmail messages. Nil uses smtp.SendMail.
+	SendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+	// GitHubToken authenticates GitHubStatus. Like the SMTP credentials, it is worker configuration.
+	GitHubToken string
+	// ArtifactDir is where UploadArtifact stores details too large for a workflow result.
+	ArtifactDir string
+	// WorkdirRoot is where GitClone creates workdirs, unless the pipeline sets its own. Defaults
+	// to os.TempDir().
+	WorkdirRoot string
+	// CloneAttempts is how many times GitClone tries a clone that fails on a network error,
+	// waiting CloneBackoff after the first failure and doubling it after each next one. Defaults
+	// to DefaultCloneAttempts and DefaultCloneBackoff.
+	CloneAttempts int
+	CloneBackoff  time.Duration
+	// CloneLimiter caps the concurrent clones per git host. Nil doesn't limit them.
+	CloneLimiter *CloneLimiter
+	// OpenDB opens the database of ArchiveResult. Nil uses sql.Open.
+	OpenDB func(driverName, dataSourceName string) (*sql.DB, error)
+
+	// migrated holds the DSNs of the databases the archive migrations were applied to.
+	migrated sync.Map
+}
+
+const (
+	DefaultCloneAttempts = 3
+	DefaultCloneBackoff  = 2 * time.Second
+)
 
 type PipelineActivityMetadata struct {
 	Workdir string
+	// GoModCache is the module cache shared by the Go activities of a run, if any.
+	GoModCache string
+	// BinDir holds the tools installed by GoInstallTools, if any. It takes precedence over PATH.
+	BinDir string
+	// Env is added to the environment of the Go commands, e.g. CGO_ENABLED=0 or GOPRIVATE.
+	Env map[string]string
+	// EnvFile is the path of the env file found by ReadEnvFile, if any, whose variables are added
+	// below Env. Each command reads it, which keeps its values out of the workflow history.
+	EnvFile string
 }
 
 // GitClone params and results
 type GitCloneParams struct {
 	Metadata PipelineActivityMetadata
 	Remote   string
+	// Ref is a branch, tag, or full commit SHA to check out after cloning. Empty keeps the default branch.
+	Ref string
+	// Subdir clones into this directory of the workdir instead of the workdir itself.
+	Subdir string
+	// Dir, when set, is a directory of the repository that must exist after checkout.
+	Dir string
+	// WorkdirRoot overrides the worker's WorkdirRoot when creating the workdir.
+	WorkdirRoot string
+	// CACertPath is a CA bundle on the worker that git trusts over HTTPS instead of the system's,
+	// for git hosts with certificates from an internal PKI. It sets GIT_SSL_CAINFO.
+	CACertPath string
+	// Insecure disables the verification of the git host's certificate with GIT_SSL_NO_VERIFY,
+	// which leaves the clone open to man-in-the-middle attacks. Prefer CACertPath.
+	Insecure bool
 }
 
 type GitCloneResult struct {
 	Metadata PipelineActivityMetadata
+	// CommitSHA is the commit checked out in the workdir.
+	CommitSHA string
 }
 
 // GoDeploy params and results
 type GoDeployParams struct {
 	Metadata PipelineActivityMetadata
+	// DeployCommand is run in the workdir to deploy. When empty, the deploy is simulated.
+	DeployCommand []string
+	// DeployEnv is added to the environment of DeployCommand.
+	DeployEnv map[string]string
 }
 
 type GoDeployResult struct {
 	Success bool
-	Error   error
+	// ErrorMsg describes why the deploy failed. It is a string because error values
+	// do not round-trip through Temporal's JSON data converter.
+	ErrorMsg string
+}
+
+// PreDeploy params and results
+type PreDeployParams struct {
+	Metadata PipelineActivityMetadata
+	Command  []string
+	Env      map[string]string
+}
+
+type PreDeployResult struct {
+	Success  bool
+	ErrorMsg string
+	// Output is the combined stdout and stderr of the verification command.
+	Output string
+}
+
+// SmokeTest params and results
+type SmokeTestParams struct {
+	Metadata PipelineActivityMetadata
+	Command  []string
+	Env      map[string]string
+}
+
+type SmokeTestResult struct {
+	Success  bool
+	ErrorMsg string
+}
+
+// GoModDownload params and results
+type GoModDownloadParams struct {
+	Metadata PipelineActivityMetadata
+}
+
+type GoModDownloadResult struct {
+	Metadata PipelineActivityMetadata
 }
 
 // GoTest params and results
 type GoTestParams struct {
 	Metadata PipelineActivityMetadata
 	Flags    []string
+	// Tags are the build tags, passed as -tags.
+	Tags []string
+	// Race runs the tests with the race detector, which needs cgo.
+	Race bool
+	// Packages, when set, are the package patterns tested instead of ./..., e.g. "./internal/...".
+	Packages []string
+	// TestP and TestParallel, when positive, are passed as -p, the packages built and tested
+	// at once, and -parallel, the parallel tests run at once in each package.
+	TestP        int
+	TestParallel int
+	// RetryFailedTests re-runs the failed tests up to this many times. Tests that pass on a
+	// retry are reported as flaky rather than failed.
+	RetryFailedTests int
 }
 
 type GoTestResult struct {
 	Metadata    PipelineActivityMetadata
+	PassedTests []GoTestCLIOutput
 	FailedTests []GoTestCLIOutput
+	// FlakyTests holds the failures of the tests that passed when retried with RetryFailedTests.
+	FlakyTests []GoTestCLIOutput
+	// FailedPackages holds the packages failing without a failing test, e.g. a test package that
+	// does not compile.
+	FailedPackages []GoTestCLIOutput
+	// Diagnostics holds the stack dump of a test run that timed out.
+	Diagnostics string
+	// Logs is the tail of the command's combined stdout and stderr, capped at maxLogBytes.
+	Logs      string
+	ElapsedMs int64
+	// Summary has the number of tests of each kind when the result was too large to return
+	// and the activity dropped some of them.
+	Summary *ResultSummary `json:",omitempty"`
 }
 
 type GoTestCLIOutput struct {
@@ -58,17 +192,37 @@ type GoTestCLIOutput struct {
 	Package string
 	Test    string
 	Elapsed float64
+	// Output of a failed package is the output explaining the failure, e.g. compiler errors.
+	Output string `json:",omitempty"`
+	// ImportPath identifies the package of build-output events; FailedBuild, on a package fail
+	// event, the build that failed.
+	ImportPath  string `json:",omitempty"`
+	FailedBuild string `json:",omitempty"`
 }
 
 // GoBuild params and results
 type GoBuildParams struct {
 	Metadata PipelineActivityMetadata
 	Flags    []string
+	Tags     []string
+	// GoVersion, GOOS and GOARCH select the toolchain and target of a build matrix cell.
+	// Empty values keep the worker's defaults.
+	GoVersion string
+	GOOS      string
+	GOARCH    string
+	// GOFLAGS, when set, overrides GOFLAGS of the metadata's Env.
+	GOFLAGS string
+	// Packages, when set, are the package patterns built instead of ./....
+	Packages []string
 }
 
 type GoBuildResult struct {
 	Metadata    PipelineActivityMetadata
 	FailedFiles []string
+	// Diagnostics holds the stack dump of a build that timed out.
+	Diagnostics string
+	Logs        string
+	ElapsedMs   int64
 }
 
 // GoModTidy params and results
@@ -76,174 +230,833 @@ type GoModTidyParams struct {
 	Metadata PipelineActivityMetadata
 }
 type GoModTidyResult struct {
-	Metadata    PipelineActivityMetadata
+	Metadata PipelineActivityMetadata
+	// FailedFiles lists go.mod and go.sum when `go mod tidy` changed them.
 	FailedFiles []string
+	Logs        string
+	ElapsedMs   int64
+}
+
+// GoModVerify params and results
+type GoModVerifyParams struct {
+	Metadata PipelineActivityMetadata
+}
+
+type GoModVerifyResult struct {
+	// FailedModules has a line per module whose downloaded copy doesn't match go.sum.
+	FailedModules []string
+	Logs          string
+	ElapsedMs     int64
 }
 
 // GoGenerate params and results
 type GoGenerateParams struct {
 	Metadata PipelineActivityMetadata
 	Flags    []string
+	Tags     []string
 }
 
 type GoGenerateResult struct {
-	Metadata    PipelineActivityMetadata
+	Metadata PipelineActivityMetadata
+	// FailedFiles lists the files `go generate` changed or created, i.e. that weren't committed
+	// up to date.
 	FailedFiles []string
+	Logs        string
+	ElapsedMs   int64
 }
 
 // GolangCILint params and results
 type GolangCILintParams struct {
 	Metadata PipelineActivityMetadata
+	// Flags are added after `run`, e.g. to select a config file or linters.
+	Flags []string
 }
 
 type GolangCILintResult struct {
-	Issues []string
+	Issues    []LintIssue
+	Logs      string
+	ElapsedMs int64
+}
+
+// LintIssue is a single issue reported by a linter.
+type LintIssue struct {
+	Linter   string
+	Severity string
+	Text     string
+	File     string
+	Line     int
+	Column   int
+}
+
+// golangCILintOutput is the subset of `golangci-lint run --out-format json` output we consume.
+type golangCILintOutput struct {
+	Issues []struct {
+		FromLinter string
+		Text       string
+		Severity   string
+		Pos        struct {
+			Filename string
+			Line     int
+			Column   int
+		}
+	}
 }
 
 // GoFmt params and results
 type GoFmtParams struct {
 	Metadata PipelineActivityMetadata
+	// CheckOnly lists unformatted files with `gofmt -l` instead of rewriting them with `go fmt`.
+	CheckOnly bool
+	// Formatter is FormatterGofmt, the default when empty, or FormatterGofumpt.
+	Formatter string
 }
 
+// The formatters of GoFmtParams.Formatter.
+const (
+	FormatterGofmt   = "gofmt"
+	FormatterGofumpt = "gofumpt"
+)
+
 type GoFmtResult struct {
 	Metadata    PipelineActivityMetadata
 	FailedFiles []string
+	Logs        string
+	ElapsedMs   int64
+}
+
+// GoVulnCheck params and results
+type GoVulnCheckParams struct {
+	Metadata PipelineActivityMetadata
+}
+
+type GoVulnCheckResult struct {
+	Metadata  PipelineActivityMetadata
+	Vulns     []Vulnerability
+	Logs      string
+	ElapsedMs int64
+}
+
+// Vulnerability is a known vulnerability reachable from the checked code.
+type Vulnerability struct {
+	ID           string
+	Module       string
+	Version      string
+	FixedVersion string
+}
+
+// govulncheckMessage is the subset of a `govulncheck -json` stream message we consume.
+type govulncheckMessage struct {
+	Finding *struct {
+		OSV          string `json:"osv"`
+		FixedVersion string `json:"fixed_version"`
+		Trace        []struct {
+			Module   string `json:"module"`
+			Version  string `json:"version"`
+			Function string `json:"function"`
+		} `json:"trace"`
+	} `json:"finding"`
 }
 
 // DeleteWorkdir params
 type DeleteWorkdirParams struct {
 	Metadata PipelineActivityMetadata
+	// WorkdirRoot is the root the workdir was created in, as passed to GitClone.
+	WorkdirRoot string
+}
+
+// MeasureWorkdir params and results
+type MeasureWorkdirParams struct {
+	Metadata PipelineActivityMetadata
+}
+
+type MeasureWorkdirResult struct {
+	Bytes int64
 }
 
 // GitClone clones a git repository to a directory. If not specified, it will be cloned to a temporary directory.
-func (pa *PipelineActivity) GitClone(ctx context.Context, params GitCloneParams) (*GitCloneResult, error) {
+func (pa *PipelineActivity) GitClone(ctx context.Context, params GitCloneParams) (_ *GitCloneResult, err error) {
 	logger := activity.GetLogger(ctx)
+	// Waiting for a clone slot and cloning a large repository can take a while.
+	defer heartbeat(ctx, heartbeatInterval)()
 
 	result := &GitCloneResult{
 		Metadata: params.Metadata,
 	}
 
 	if params.Metadata.Workdir == "" {
-		wfInfo := activity.GetInfo(ctx)
+		var workdir string
+		workdir, err = pa.createWorkdir(ctx, params.WorkdirRoot)
+		if err != nil {
+			return nil, err
+		}
+		result.Metadata.Workdir = workdir
+		activityLogger(ctx).Info("No workdir specified, creating one", "workdir", result.Metadata.Workdir)
+		defer removeWorkdirOnError(ctx, workdir, &err)
+	}
+
+	// Clone the repository to current directory, instead of creating a new folder based on the repository name.
+	target := "."
+	if params.Subdir != "" {
+		target = params.Subdir
+	}
+	if params.Insecure {
+		logger.Warn("INSECURE: cloning without verifying the TLS certificate of the git host", "remote", params.Remote)
+	}
+	env := gitEnv(params.CACertPath, params.Insecure)
+	if err := pa.cloneWithRetry(ctx, result.Metadata.Workdir, env, params.Remote, target); err != nil {
+		return nil, err
+	}
+
+	repoDir := filepath.Join(result.Metadata.Workdir, params.Subdir)
+	if params.Ref != "" {
+		if err := checkoutRef(ctx, repoDir, env, params.Ref); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkDir(repoDir, params.Dir); err != nil {
+		return nil, err
+	}
+
+	sha, err := runGitEnv(ctx, repoDir, env, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("resolving checked out commit: %w", err)
+	}
+	result.CommitSHA = sha
+	logger.Info("Checked out commit", "ref", params.Ref, "sha", sha)
+
+	return result, nil
+}
+
+// cloneWithRetry clones remote into target, relative to workdir. Clones that fail on a network
+// error are cleaned up and tried again after a backoff, within the same activity attempt, so a
+// blip doesn't cost a whole activity retry. Authentication errors fail without retrying.
+func (pa *PipelineActivity) cloneWithRetry(ctx context.Context, workdir string, env []string, remote, target string) error {
+	logger := activity.GetLogger(ctx)
+
+	attempts := pa.CloneAttempts
+	if attempts <= 0 {
+		attempts = DefaultCloneAttempts
+	}
+	backoff := pa.CloneBackoff
+	if backoff <= 0 {
+		backoff = DefaultCloneBackoff
+	}
 
-		tempDir, err := os.MkdirTemp(os.TempDir(), wfInfo.WorkflowExecution.ID)
+	args := []string{"clone", remote, target}
+	for attempt := 1; ; attempt++ {
+		release, err := pa.CloneLimiter.acquire(ctx, remote)
 		if err != nil {
-			return nil, fmt.Errorf("creating temporary directory: %w", err)
+			return fmt.Errorf("waiting for a clone slot: %w", err)
+		}
+		stdout, stderr, err := runCommand(ctx, workdir, commandOptions{Env: env}, "git", args...)
+		release()
+		if err == nil {
+			logger.Info("Git clone command ran successfully", "attempt", attempt, "stdout", stdout)
+			return nil
+		}
+
+		err = fmt.Errorf("running git clone command: %w: %s", err, strings.TrimSpace(stderr))
+		switch {
+		case gitAuthError.MatchString(stderr):
+			// Credentials won't appear between attempts.
+			return temporal.NewNonRetryableApplicationError(err.Error(), "GitAuthFailed", err)
+		case !gitNetworkError.MatchString(stderr):
+			return err
+		case attempt >= attempts:
+			return err
 		}
 
-		result.Metadata.Workdir = tempDir
-		slog.Info("No workdir specified, creating one", "workdir", result.Metadata.Workdir)
+		// git removes a failed clone's directory it created, but not the contents of one that
+		// already existed, like the workdir.
+		if err := clearDir(filepath.Join(workdir, target)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("cleaning up failed clone: %w", err)
+		}
+		logger.Warn("Retrying git clone after network error", "attempt", attempt, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
 	}
+}
 
-	// Clone the repository to current directory, instead of creating a new folder based on the repository name.
-	args := []string{"clone", params.Remote, "."}
-	slog.Info("Running command", "command", "git", "args", args, "dir", result.Metadata.Workdir)
+var (
+	gitAuthError    = regexp.MustCompile(`(?i)authentication failed|could not read (username|password)|permission denied \(publickey|repository not found|terminal prompts disabled|returned error: 40[13]`)
+	gitNetworkError = regexp.MustCompile(`(?i)could not resolve host|connection (timed out|refused|reset)|operation timed out|failed to connect|early eof|rpc failed|unexpected disconnect|remote end hung up|ssl|gnutls|tls handshake|returned error: 5\d\d`)
+)
 
-	cmd := exec.CommandContext(ctx, "git", args...)
+// checkDir returns a non-retryable error if dir, relative to root, isn't a directory, so a
+// mistyped subdirectory fails the pipeline right after fetching the sources.
+func checkDir(root, dir string) error {
+	if dir == "" {
+		return nil
+	}
+	fi, err := os.Stat(filepath.Join(root, dir))
+	if err == nil && !fi.IsDir() {
+		err = errors.New("not a directory")
+	}
+	if err != nil {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("subdirectory %q: %s", dir, err), "SubdirNotFound", err)
+	}
+	return nil
+}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	cmd.Dir = result.Metadata.Workdir
-	if err := cmd.Run(); err != nil {
-		logger.Error("Error running git clone command", "error", err, "stderr", stderr.String(), "stdout", stdout.String())
-		return nil, fmt.Errorf("running git clone command: %w", err)
+// createWorkdir creates the workdir of the workflow run in root, or the worker's WorkdirRoot.
+func (pa *PipelineActivity) createWorkdir(ctx context.Context, root string) (string, error) {
+	root = pa.workdirRoot(root)
+	if err := checkWorkdirRoot(root); err != nil {
+		// A missing or read-only root won't fix itself between attempts.
+		return "", temporal.NewNonRetryableApplicationError(err.Error(), "InvalidWorkdirRoot", err)
 	}
-	logger.Info("Git clone command ran successfully", "stdout", stdout.String())
 
+	workdir, err := os.MkdirTemp(root, activity.GetInfo(ctx).WorkflowExecution.ID)
+	if err != nil {
+		return "", fmt.Errorf("creating temporary directory: %w", err)
+	}
+	return workdir, nil
+}
+
+// removeWorkdirOnError removes workdir, created by a failing activity, when *err is set: the
+// workflow only learns of the workdir from the activity's result, so it can't delete it itself,
+// e.g. when the pipeline is cancelled midway through the clone.
+func removeWorkdirOnError(ctx context.Context, workdir string, err *error) {
+	if *err == nil {
+		return
+	}
+	if rmErr := os.RemoveAll(workdir); rmErr != nil {
+		activityLogger(ctx).Warn("Failed to remove workdir", "workdir", workdir, "error", rmErr)
+	}
+}
+
+// checkWorkdir verifies that workdir is a directory created in root, rather than e.g. empty, /,
+// or a symlink to a directory elsewhere.
+func checkWorkdir(workdir, root string) error {
+	if workdir == "" {
+		return errors.New("workdir is empty")
+	}
+	if !filepath.IsAbs(workdir) {
+		return fmt.Errorf("workdir %q is not an absolute path", workdir)
+	}
+	fi, err := os.Lstat(workdir)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		return fmt.Errorf("workdir %q is a symlink", workdir)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("workdir %q is not a directory", workdir)
+	}
+
+	// Compare real paths, as the root itself may be behind a symlink, e.g. /tmp on macOS.
+	realWorkdir, err := filepath.EvalSymlinks(workdir)
+	if err != nil {
+		return err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("workdir root %q: %w", root, err)
+	}
+	if rel, err := filepath.Rel(realRoot, realWorkdir); err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return fmt.Errorf("workdir %q is not in the workdir root %q", workdir, root)
+	}
+	return nil
+}
+
+// checkWorkdirRoot verifies that root is a directory workdirs can be created in.
+func checkWorkdirRoot(root string) error {
+	fi, err := os.Stat(root)
+	if err != nil {
+		return fmt.Errorf("workdir root %q: %w", root, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("workdir root %q is not a directory", root)
+	}
+	probe, err := os.MkdirTemp(root, ".probe")
+	if err != nil {
+		return fmt.Errorf("workdir root %q is not writable: %w", root, err)
+	}
+	return os.Remove(probe)
+}
+
+var fullSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// checkoutRef checks out ref in the cloned workdir. A full commit SHA is fetched first, since
+// it may not be reachable from the branches fetched by the clone.
+func checkoutRef(ctx context.Context, workdir string, env []string, ref string) error {
+	if fullSHA.MatchString(ref) {
+		if _, err := runGitEnv(ctx, workdir, env, "fetch", "origin", ref); err != nil {
+			return fmt.Errorf("fetching ref %q: %w", ref, err)
+		}
+	}
+	// Webhook payloads carry full ref names; git checkout expects the branch name to create a
+	// local tracking branch.
+	ref = strings.TrimPrefix(ref, "refs/heads/")
+	if _, err := runGitEnv(ctx, workdir, env, "checkout", ref); err != nil {
+		return fmt.Errorf("checking out ref %q: %w", ref, err)
+	}
+	return nil
+}
+
+// runGit runs a git command in dir and returns its trimmed stdout. Errors include stderr.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	return runGitEnv(ctx, dir, nil, args...)
+}
+
+// runGitEnv is runGit with env as the environment of the command, nil inheriting the worker's.
+func runGitEnv(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	stdout, stderr, err := runCommand(ctx, dir, commandOptions{Env: env}, "git", args...)
+	if err != nil {
+		return "", fmt.Errorf("running git %s command: %w: %s", args[0], err, strings.TrimSpace(stderr))
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// gitEnv returns the environment for git commands in a clone of a remote whose certificate is
+// verified against caCertPath, or not at all when insecure. It is nil to inherit the worker's
+// when both keep the system's certificate verification.
+func gitEnv(caCertPath string, insecure bool) []string {
+	switch {
+	case insecure:
+		return append(os.Environ(), "GIT_SSL_NO_VERIFY=true")
+	case caCertPath != "":
+		return append(os.Environ(), "GIT_SSL_CAINFO="+caCertPath)
+	}
+	return nil
+}
+
+// sharedGoModCache is the module cache shared by all runs on a worker, so modules are
+// downloaded once instead of by every activity of every run.
+var sharedGoModCache = filepath.Join(os.TempDir(), "pipeline-gomodcache")
+
+// goEnv returns the environment for Go commands run against metadata's workdir. The env file
+// and then metadata.Env augment the worker's environment; the module cache and tools set up by
+// the pipeline win.
+func goEnv(metadata PipelineActivityMetadata) []string {
+	env := os.Environ()
+	if metadata.EnvFile != "" {
+		fileEnv, err := readEnvFile(metadata.EnvFile)
+		if err != nil {
+			// ReadEnvFile parsed it already, so it was changed or removed since.
+			slog.Error("Failed to read env file", "path", metadata.EnvFile, "error", err)
+		}
+		for k, v := range fileEnv {
+			env = append(env, k+"="+v)
+		}
+	}
+	for k, v := range metadata.Env {
+		env = append(env, k+"="+v)
+	}
+	if metadata.GoModCache != "" {
+		env = append(env, "GOMODCACHE="+metadata.GoModCache)
+	}
+	if metadata.BinDir != "" {
+		env = append(env, "PATH="+metadata.BinDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
+	return env
+}
+
+// goTestArgs returns the arguments of the `go test` command run by GoTest.
+func goTestArgs(params GoTestParams) []string {
+	args := append([]string{"test", "-json"}, tagsArgs(params.Tags)...)
+	if params.Race {
+		args = append(args, "-race")
+	}
+	if params.TestP > 0 {
+		args = append(args, "-p", strconv.Itoa(params.TestP))
+	}
+	if params.TestParallel > 0 {
+		args = append(args, "-parallel", strconv.Itoa(params.TestParallel))
+	}
+	if len(params.Packages) > 0 {
+		args = append(args, params.Packages...)
+	} else {
+		args = append(args, "./...")
+	}
+	return append(args, params.Flags...)
+}
+
+// goBuildArgs returns the arguments of the `go build` command run by GoBuild.
+func goBuildArgs(params GoBuildParams) []string {
+	args := append([]string{"build"}, tagsArgs(params.Tags)...)
+	args = append(args, params.Flags...)
+	if len(params.Packages) > 0 {
+		return append(args, params.Packages...)
+	}
+	return append(args, "./...")
+}
+
+// goGenerateArgs returns the arguments of the `go generate` command run by GoGenerate.
+func goGenerateArgs(params GoGenerateParams) []string {
+	args := append([]string{"generate"}, tagsArgs(params.Tags)...)
+	args = append(args, params.Flags...)
+	return append(args, "./...")
+}
+
+// tagsArgs returns the -tags flag of the go command for tags, placed before the packages since
+// `go build` stops parsing flags at the first package.
+func tagsArgs(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return []string{"-tags", strings.Join(tags, ",")}
+}
+
+// raceUnsupported matches the go command's errors when this worker can't build with the race
+// detector: an unsupported platform, or no C compiler for cgo.
+var raceUnsupported = regexp.MustCompile(`-race is not supported on \S+|C compiler "[^"]*" not found`)
+
+var sensitiveEnvKey = regexp.MustCompile(`(?i)token|secret|passw(or)?d|key|credential|auth`)
+
+// redactEnv returns env with the values of sensitive-looking variables replaced, for logging.
+func redactEnv(env map[string]string) map[string]string {
+	redacted := make(map[string]string, len(env))
+	for k, v := range env {
+		if sensitiveEnvKey.MatchString(k) {
+			v = "REDACTED"
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
+
+// GoModDownload runs `go mod download` into the shared module cache and records it in the metadata.
+func (pa *PipelineActivity) GoModDownload(ctx context.Context, params GoModDownloadParams) (*GoModDownloadResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &GoModDownloadResult{
+		Metadata: params.Metadata,
+	}
+	result.Metadata.GoModCache = sharedGoModCache
+
+	if err := os.MkdirAll(result.Metadata.GoModCache, 0o755); err != nil {
+		return nil, fmt.Errorf("creating module cache directory: %w", err)
+	}
+
+	_, _, err := runCommand(ctx, result.Metadata.Workdir, commandOptions{Env: goEnv(result.Metadata)}, "go", "mod", "download")
+	if err != nil {
+		return nil, fmt.Errorf("running go mod download command: %w", err)
+	}
+
+	logger.Info("Go mod download ran successfully", "gomodcache", result.Metadata.GoModCache)
 	return result, nil
 }
 
-// GoFmt runs `go fmt` in the specified directory.
+// GoFmt runs `go fmt`, or gofumpt when it is the formatter, in the specified directory.
 func (pa *PipelineActivity) GoFmt(ctx context.Context, params GoFmtParams) (*GoFmtResult, error) {
-	logger := activity.GetLogger(ctx)
 	result := &GoFmtResult{
 		Metadata:    params.Metadata,
 		FailedFiles: []string{},
 	}
 
-	args := []string{"fmt", "./..."}
-	slog.Info("Running command", "command", "go", "args", args, "dir", result.Metadata.Workdir)
-
-	cmd := exec.CommandContext(ctx, "go", args...)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	cmd.Dir = result.Metadata.Workdir
-	if err := cmd.Run(); err != nil {
-		logger.Error("Error running go fmt command", "error", err, "stderr", stderr.String(), "stdout", stdout.String())
-		return nil, fmt.Errorf("running go fmt command: %w", err)
+	name, args := fmtCommand(params)
+	if params.Formatter == FormatterGofumpt {
+		if _, err := exec.LookPath(name); err != nil {
+			return nil, temporal.NewNonRetryableApplicationError(
+				"gofumpt not found in PATH, install it with `go install mvdan.cc/gofumpt@latest` or PipelineParams.Tools",
+				"ToolNotFound", err)
+		}
+	}
+	logs := &logTail{}
+	start := time.Now()
+	stdout, stderr, err := runCommand(ctx, result.Metadata.Workdir, commandOptions{Env: goEnv(result.Metadata), Logs: logs}, name, args...)
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	result.Logs = logs.String()
+	if err != nil {
+		return nil, toolError(name, err, stderr)
 	}
 
-	files := bytes.Split(stdout.Bytes(), []byte{'\n'})
-	for _, file := range files {
-		if len(file) > 0 {
-			result.FailedFiles = append(result.FailedFiles, string(file))
+	for _, file := range strings.Split(stdout, "\n") {
+		if file != "" {
+			result.FailedFiles = append(result.FailedFiles, file)
 		}
 	}
 
 	return result, nil
 }
 
+// fmtCommand returns the command GoFmt runs for its formatter. Each prints the files that are
+// (or would be) changed, one per line.
+func fmtCommand(params GoFmtParams) (name string, args []string) {
+	switch {
+	case params.Formatter == FormatterGofumpt && params.CheckOnly:
+		return lookTool(params.Metadata, "gofumpt"), []string{"-l", "."}
+	case params.Formatter == FormatterGofumpt:
+		return lookTool(params.Metadata, "gofumpt"), []string{"-l", "-w", "."}
+	case params.CheckOnly:
+		return "gofmt", []string{"-l", "."}
+	}
+	return "go", []string{"fmt", "./..."}
+}
+
 // GoTest runs `go test` in the specified directory.
 func (pa *PipelineActivity) GoTest(ctx context.Context, params GoTestParams) (*GoTestResult, error) {
 	logger := activity.GetLogger(ctx)
 	result := &GoTestResult{
-		Metadata:    params.Metadata,
-		FailedTests: []GoTestCLIOutput{},
+		Metadata:       params.Metadata,
+		PassedTests:    []GoTestCLIOutput{},
+		FailedTests:    []GoTestCLIOutput{},
+		FlakyTests:     []GoTestCLIOutput{},
+		FailedPackages: []GoTestCLIOutput{},
 	}
 
-	args := []string{"test", "./..."}
-	args = append(args, params.Flags...)
-	// args = append(args, "./...")
-	slog.Info("Running command", "command", "go", "args", args, "dir", result.Metadata.Workdir)
-
-	cmd := exec.CommandContext(ctx, "go", args...)
+	env := goEnv(result.Metadata)
+	if params.Race {
+		// The race detector needs cgo, even when the pipeline's Env disables it.
+		env = append(env, "CGO_ENABLED=1")
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	cmd.Dir = result.Metadata.Workdir
-	if err := cmd.Run(); err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			// If the command exits with a non-zero status, assume it's failing tests.
-			logger.Info("Command exited with non-zero status", "status", exitErr.ExitCode())
-			// Parse the JSON output of `go test -json` to get the failed tests.
-			body := []byte{'['}
-			lines := strings.Split(stdout.String(), "\n")
-			for i, line := range lines {
-				body = append(body, []byte(line)...)
-				if i < len(lines)-2 {
-					body = append(body, byte(','))
-				}
+	cmdCtx, cancel := withStackDumpDeadline(ctx)
+	defer cancel()
+	logs := &logTail{}
+	// The events are decoded as they are written, as the whole stream of a large module can
+	// exceed MaxCommandOutput.
+	var testOutput []GoTestCLIOutput
+	events := &lineWriter{fn: func(line string) {
+		if event, ok := parseGoTestEvent(line); ok {
+			testOutput = append(testOutput, event)
+		} else if line = strings.TrimSpace(line); line != "" {
+			logger.Debug("Skipping non-JSON go test output line", "line", line)
+		}
+	}}
+	start := time.Now()
+	_, stderr, err := runCommand(cmdCtx, result.Metadata.Workdir, commandOptions{Env: env, Logs: logs, StackDump: true, Stdout: events}, "go", goTestArgs(params)...)
+	events.Flush()
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	result.Logs = logs.String()
+	var exitErr *exec.ExitError
+	if err != nil {
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("running go test command: %w", err)
+		}
+		if params.Race {
+			if msg := raceUnsupported.FindString(stderr); msg != "" {
+				return nil, temporal.NewNonRetryableApplicationError("running go test -race: "+msg, "RaceUnsupported", err)
 			}
-			body = append(body, ']')
-			var testOutput []GoTestCLIOutput
-			if err := json.Unmarshal(body, &testOutput); err != nil {
-				logger.Error("Error unmarshalling JSON output", "error", err, "body", string(body))
-				return nil, fmt.Errorf("unmarshalling JSON output: %w", err)
+		}
+		// If the command exits with a non-zero status, assume it's failing tests.
+		logger.Info("Command exited with non-zero status", "status", exitErr.ExitCode())
+	}
+
+	if cmdCtx.Err() != nil && ctx.Err() == nil {
+		// The test binary writes its stack dump to its output, which test2json wraps in output events.
+		var dump strings.Builder
+		for _, line := range testOutput {
+			dump.WriteString(line.Output)
+		}
+		dump.WriteString(stderr)
+		result.Diagnostics = dump.String()
+		logger.Error("Go test timed out, captured stack dump")
+	}
+	var packageFailures []GoTestCLIOutput
+	for _, line := range withFailureOutput(testOutput) {
+		switch {
+		case line.Action == "pass" && line.Test != "":
+			result.PassedTests = append(result.PassedTests, line)
+		case line.Action == "fail" && line.Test != "":
+			result.FailedTests = append(result.FailedTests, line)
+		case line.Action == "fail":
+			packageFailures = append(packageFailures, line)
+		}
+	}
+	// A package with failing tests fails too, which its tests already report.
+	failedTestPackages := map[string]bool{}
+	for _, test := range result.FailedTests {
+		failedTestPackages[test.Package] = true
+	}
+	for _, pkg := range packageFailures {
+		if !failedTestPackages[pkg.Package] {
+			result.FailedPackages = append(result.FailedPackages, pkg)
+		}
+	}
+	// go test failing without a fail event, e.g. on an invalid flag, would otherwise pass.
+	if exitErr != nil && len(result.FailedTests) == 0 && len(result.FailedPackages) == 0 && result.Diagnostics == "" {
+		msg := fmt.Sprintf("go test exited with status %d without reporting a failure: %s", exitErr.ExitCode(), lastLine(stderr))
+		return nil, temporal.NewNonRetryableApplicationError(msg, "ToolFailed", err)
+	}
+
+	// A run that timed out has no reliable list of failures to retry.
+	if params.RetryFailedTests > 0 && len(result.FailedTests) > 0 && result.Diagnostics == "" {
+		if err := retryFailedTests(ctx, params, env, result); err != nil {
+			return nil, err
+		}
+		logger.Info("Retried failed tests", "flaky", len(result.FlakyTests), "failed", len(result.FailedTests))
+	}
+	capGoTestResult(result)
+	return result, nil
+}
+
+// retryFailedTests re-runs the failed tests of result, package by package, up to
+// params.RetryFailedTests times, and moves the failures of those that pass to FlakyTests.
+func retryFailedTests(ctx context.Context, params GoTestParams, env []string, result *GoTestResult) error {
+	// The failing top-level tests of each package; a failed subtest retries its whole test.
+	failing := map[string]map[string]bool{}
+	for _, test := range result.FailedTests {
+		if failing[test.Package] == nil {
+			failing[test.Package] = map[string]bool{}
+		}
+		failing[test.Package][topLevelTest(test.Test)] = true
+	}
+	packages := make([]string, 0, len(failing))
+	for pkg := range failing {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+
+	for attempt := 1; attempt <= params.RetryFailedTests; attempt++ {
+		for _, pkg := range packages {
+			if len(failing[pkg]) == 0 {
+				continue
 			}
-			for _, line := range testOutput {
-				if line.Action == "fail" && line.Test != "" {
-					result.FailedTests = append(result.FailedTests, line)
+			events := &lineWriter{fn: func(line string) {
+				event, ok := parseGoTestEvent(line)
+				if ok && event.Action == "pass" && event.Test != "" && !strings.Contains(event.Test, "/") {
+					delete(failing[pkg], event.Test)
 				}
+			}}
+			_, _, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: env, Stdout: events}, "go", retryTestArgs(params, pkg, failing[pkg])...)
+			events.Flush()
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				return fmt.Errorf("retrying failed tests of %s: %w", pkg, err)
 			}
+		}
+	}
+
+	var failed []GoTestCLIOutput
+	for _, test := range result.FailedTests {
+		if failing[test.Package][topLevelTest(test.Test)] {
+			failed = append(failed, test)
 		} else {
-			logger.Error("Error running go test command", "error", err, "stderr", stderr.String(), "stdout", stdout.String())
-			return nil, fmt.Errorf("running go test command: %w", err)
+			result.FlakyTests = append(result.FlakyTests, test)
+		}
+	}
+	result.FailedTests = append(result.FailedTests[:0], failed...)
+	return nil
+}
+
+// retryTestArgs returns the arguments of the `go test` command re-running tests of pkg.
+func retryTestArgs(params GoTestParams, pkg string, tests map[string]bool) []string {
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	sort.Strings(names)
+	params.Packages = []string{pkg}
+	// The flags come last so -run overrides any of params.Flags, and -count=1 skips the test
+	// cache, which could otherwise replay a result.
+	return append(goTestArgs(params), "-count=1", "-run", "^("+strings.Join(names, "|")+")$")
+}
+
+// topLevelTest returns the name of the top-level test of a test or subtest, e.g. TestA for
+// TestA/case_1.
+func topLevelTest(name string) string {
+	top, _, _ := strings.Cut(name, "/")
+	return top
+}
+
+// withFailureOutput sets the Output of each package fail event to the output explaining it: the
+// compiler errors of the build that failed, or else the package-level output events before it.
+func withFailureOutput(events []GoTestCLIOutput) []GoTestCLIOutput {
+	buildOutput := map[string]string{}
+	packageOutput := map[string]string{}
+	for i, event := range events {
+		switch {
+		case event.Action == "build-output":
+			buildOutput[event.ImportPath] += event.Output
+		case event.Action == "output" && event.Test == "":
+			packageOutput[event.Package] += event.Output
+		case event.Action == "fail" && event.Test == "":
+			if output, ok := buildOutput[event.FailedBuild]; ok && event.FailedBuild != "" {
+				events[i].Output = output
+			} else {
+				events[i].Output = packageOutput[event.Package]
+			}
 		}
 	}
+	return events
+}
+
+// parseGoTestOutput decodes the event stream of `go test -json`, one JSON object per line.
+// Lines that are not JSON objects, such as build output or `go:` notices interleaved on stdout,
+// are returned separately instead of failing the whole parse.
+func parseGoTestOutput(output string) (events []GoTestCLIOutput, skipped []string) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		event, ok := parseGoTestEvent(line)
+		if !ok {
+			skipped = append(skipped, line)
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, skipped
+}
+
+// parseGoTestEvent decodes a line of `go test -json` output, reporting whether it is an event.
+func parseGoTestEvent(line string) (GoTestCLIOutput, bool) {
+	var event GoTestCLIOutput
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil {
+		return event, false
+	}
+	return event, true
+}
+
+// MeasureWorkdir reports the disk space used by the workdir, excluding the module cache when
+// it is shared with other runs.
+func (pa *PipelineActivity) MeasureWorkdir(ctx context.Context, params MeasureWorkdirParams) (*MeasureWorkdirResult, error) {
+	result := &MeasureWorkdirResult{}
+
+	activityLogger(ctx).Info("Measuring workdir", "workdir", params.Metadata.Workdir)
+	err := filepath.WalkDir(params.Metadata.Workdir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && params.Metadata.GoModCache != "" && path == params.Metadata.GoModCache {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		result.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("measuring workdir: %w", err)
+	}
+
+	activity.GetLogger(ctx).Info("Workdir measured", "bytes", result.Bytes)
 	return result, nil
 }
 
-// DeleteWorkdir deletes the directory specified in the metadata.
+// DeleteWorkdir deletes the directory specified in the metadata. It refuses to delete anything
+// but a directory in the workdir root, so corrupt metadata can't wipe the worker's disk.
 func (pa *PipelineActivity) DeleteWorkdir(ctx context.Context, params DeleteWorkdirParams) error {
 	logger := activity.GetLogger(ctx)
 
-	slog.Info("Deleting workdir", "workdir", params.Metadata.Workdir)
-	if err := os.RemoveAll(params.Metadata.Workdir); err != nil {
+	workdir := params.Metadata.Workdir
+	if workdir != "" {
+		if _, err := os.Lstat(workdir); errors.Is(err, fs.ErrNotExist) {
+			logger.Info("Workdir already deleted", "workdir", workdir)
+			return nil
+		}
+	}
+	if err := checkWorkdir(workdir, pa.workdirRoot(params.WorkdirRoot)); err != nil {
+		logger.Error("Refusing to delete workdir", "workdir", workdir, "error", err)
+		return temporal.NewNonRetryableApplicationError(err.Error(), "UnsafeWorkdir", err)
+	}
+
+	activityLogger(ctx).Info("Deleting workdir", "workdir", workdir)
+	if err := os.RemoveAll(workdir); err != nil {
 		logger.Error("Error deleting workdir", "error", err)
 		return fmt.Errorf("deleting workdir: %w", err)
 	}
@@ -260,21 +1073,79 @@ func (pa *PipelineActivity) GoModTidy(ctx context.Context, params GoModTidyParam
 		FailedFiles: []string{},
 	}
 
-	args := []string{"mod", "tidy"}
-	slog.Info("Running command", "command", "go", "args", args, "dir", params.Metadata.Workdir)
-
-	cmd := exec.CommandContext(ctx, "go", args...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	cmd.Dir = params.Metadata.Workdir
+	// The files are compared rather than diffed with git, so tarball sources are checked too.
+	before, err := readModFiles(params.Metadata.Workdir)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := cmd.Run(); err != nil {
-		logger.Error("Error running go mod tidy command", "error", err, "stderr", stderr.String(), "stdout", stdout.String())
+	logs := &logTail{}
+	start := time.Now()
+	stdout, _, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: goEnv(params.Metadata), Logs: logs}, "go", "mod", "tidy")
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	result.Logs = logs.String()
+	if err != nil {
 		return nil, fmt.Errorf("running go mod tidy command: %w", err)
 	}
 
-	logger.Info("Go mod tidy ran successfully", "stdout", stdout.String())
+	after, err := readModFiles(params.Metadata.Workdir)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range modFiles {
+		if !bytes.Equal(before[name], after[name]) {
+			result.FailedFiles = append(result.FailedFiles, name)
+		}
+	}
+
+	logger.Info("Go mod tidy ran successfully", "changed", result.FailedFiles, "stdout", stdout)
+	return result, nil
+}
+
+var modFiles = []string{"go.mod", "go.sum"}
+
+// readModFiles returns the contents of the modFiles of dir. A missing go.sum reads as empty.
+func readModFiles(dir string) (map[string][]byte, error) {
+	contents := map[string][]byte{}
+	for _, name := range modFiles {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		contents[name] = content
+	}
+	return contents, nil
+}
+
+// GoModVerify runs `go mod verify` in the specified directory, checking the downloaded
+// dependencies against go.sum.
+func (pa *PipelineActivity) GoModVerify(ctx context.Context, params GoModVerifyParams) (*GoModVerifyResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &GoModVerifyResult{
+		FailedModules: []string{},
+	}
+
+	logs := &logTail{}
+	start := time.Now()
+	stdout, stderr, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: goEnv(params.Metadata), Logs: logs}, "go", "mod", "verify")
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	result.Logs = logs.String()
+	if err == nil {
+		logger.Info("Go mod verify ran successfully")
+		return result, nil
+	}
+
+	// go mod verify reports each mismatching module on its own line, e.g.
+	// `example.com/dep v1.2.3: dir has been modified (/go/pkg/mod/example.com/dep@v1.2.3)`.
+	for _, line := range strings.Split(stdout+stderr, "\n") {
+		if strings.Contains(line, "has been modified") || strings.Contains(line, "missing ziphash") {
+			result.FailedModules = append(result.FailedModules, strings.TrimSpace(line))
+		}
+	}
+	if len(result.FailedModules) == 0 {
+		return nil, fmt.Errorf("running go mod verify command: %w", err)
+	}
+	logger.Info("Go mod verify found modified modules", "modules", len(result.FailedModules))
 	return result, nil
 }
 
@@ -286,22 +1157,38 @@ func (pa *PipelineActivity) GoBuild(ctx context.Context, params GoBuildParams) (
 		FailedFiles: []string{},
 	}
 
-	args := []string{"build", "./..."}
-	args = append(args, params.Flags...)
-	slog.Info("Running command", "command", "go", "args", args, "dir", params.Metadata.Workdir)
-
-	cmd := exec.CommandContext(ctx, "go", args...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	cmd.Dir = params.Metadata.Workdir
+	args := goBuildArgs(params)
+	env := goEnv(params.Metadata)
+	if params.GoVersion != "" {
+		env = append(env, "GOTOOLCHAIN=go"+params.GoVersion)
+	}
+	if params.GOOS != "" {
+		env = append(env, "GOOS="+params.GOOS)
+	}
+	if params.GOARCH != "" {
+		env = append(env, "GOARCH="+params.GOARCH)
+	}
+	if params.GOFLAGS != "" {
+		env = append(env, "GOFLAGS="+params.GOFLAGS)
+	}
 
-	if err := cmd.Run(); err != nil {
-		logger.Error("Error running go build command", "error", err, "stderr", stderr.String(), "stdout", stdout.String())
+	cmdCtx, cancel := withStackDumpDeadline(ctx)
+	defer cancel()
+	logs := &logTail{}
+	start := time.Now()
+	stdout, stderr, err := runCommand(cmdCtx, params.Metadata.Workdir, commandOptions{Env: env, Logs: logs, StackDump: true}, "go", args...)
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	result.Logs = logs.String()
+	if err != nil {
+		if cmdCtx.Err() != nil && ctx.Err() == nil {
+			result.Diagnostics = stderr
+			logger.Error("Go build timed out, captured stack dump")
+			return result, nil
+		}
 		return nil, fmt.Errorf("running go build command: %w", err)
 	}
 
-	logger.Info("Go build ran successfully", "stdout", stdout.String())
+	logger.Info("Go build ran successfully", "stdout", stdout)
 	return result, nil
 }
 
@@ -313,65 +1200,279 @@ func (pa *PipelineActivity) GoGenerate(ctx context.Context, params GoGeneratePar
 		FailedFiles: []string{},
 	}
 
-	args := []string{"generate", "./..."}
-	args = append(args, params.Flags...)
-	slog.Info("Running command", "command", "go", "args", args, "dir", params.Metadata.Workdir)
-
-	cmd := exec.CommandContext(ctx, "go", args...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	cmd.Dir = params.Metadata.Workdir
+	// go generate runs in a worktree of the checked out commit, as GoFmt, GoModTidy and the
+	// builds modify the workdir meanwhile: their changes would pass for stale generated files.
+	// Sources without git history, like tarballs, can't be checked and are generated in place.
+	dir, removeWorktree, worktreeErr := addWorktree(ctx, params.Metadata.Workdir)
+	if worktreeErr != nil {
+		logger.Warn("Not checking for stale generated files", "error", worktreeErr)
+		dir = params.Metadata.Workdir
+	} else {
+		defer removeWorktree()
+	}
 
-	if err := cmd.Run(); err != nil {
-		logger.Error("Error running go generate command", "error", err, "stderr", stderr.String(), "stdout", stdout.String())
+	args := goGenerateArgs(params)
+	logs := &logTail{}
+	start := time.Now()
+	stdout, _, err := runCommand(ctx, dir, commandOptions{Env: goEnv(params.Metadata), Logs: logs}, "go", args...)
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	result.Logs = logs.String()
+	if err != nil {
 		return nil, fmt.Errorf("running go generate command: %w", err)
 	}
 
-	logger.Info("Go generate ran successfully", "stdout", stdout.String())
+	if worktreeErr == nil {
+		result.FailedFiles, err = dirtyFiles(ctx, dir)
+		if err != nil {
+			return nil, fmt.Errorf("listing generated files: %w", err)
+		}
+	}
+
+	logger.Info("Go generate ran successfully", "changed", result.FailedFiles, "stdout", stdout)
 	return result, nil
 }
 
+// addWorktree checks out the commit of the git checkout containing workdir in a new worktree
+// next to it, so relative paths to sibling repositories still resolve, and returns the
+// directory of the worktree matching workdir. remove deletes the worktree.
+func addWorktree(ctx context.Context, workdir string) (dir string, remove func(), err error) {
+	top, err := runGit(ctx, workdir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", nil, err
+	}
+	prefix, err := runGit(ctx, workdir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", nil, err
+	}
+	worktree, err := os.MkdirTemp(filepath.Dir(top), "."+filepath.Base(top)+"-worktree-")
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := runGit(ctx, top, "worktree", "add", "--detach", worktree, "HEAD"); err != nil {
+		_ = os.RemoveAll(worktree)
+		return "", nil, err
+	}
+	return filepath.Join(worktree, prefix), func() {
+		// The context may be done, e.g. on cancellation, and the worktree must go regardless.
+		if _, err := runGit(context.Background(), top, "worktree", "remove", "--force", worktree); err != nil {
+			activityLogger(ctx).Warn("Failed to remove worktree", "worktree", worktree, "error", err)
+			_ = os.RemoveAll(worktree)
+		}
+	}, nil
+}
+
+// dirtyFiles returns the files under dir that differ from the checked out commit or are
+// untracked, relative to dir, sorted.
+func dirtyFiles(ctx context.Context, dir string) ([]string, error) {
+	modified, err := runGit(ctx, dir, "diff", "--name-only", "--relative", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := runGit(ctx, dir, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, name := range strings.Split(modified+"\n"+untracked, "\n") {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
 // GolangCILint runs `golangci-lint run` in the specified directory.
 func (pa *PipelineActivity) GolangCILint(ctx context.Context, params GolangCILintParams) (*GolangCILintResult, error) {
 	logger := activity.GetLogger(ctx)
 	result := &GolangCILintResult{
-		Issues: []string{},
+		Issues: []LintIssue{},
 	}
 
-	args := []string{"run"}
-	slog.Info("Running command", "command", "golangci-lint", "args", args, "dir", params.Metadata.Workdir)
-
-	cmd := exec.CommandContext(ctx, "golangci-lint", args...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	cmd.Dir = params.Metadata.Workdir
-
-	if err := cmd.Run(); err != nil {
+	args := append([]string{"run"}, params.Flags...)
+	// The issues are parsed from the JSON output, so it comes last to win over any Flags.
+	args = append(args, "--out-format", "json")
+	logs := &logTail{}
+	start := time.Now()
+	stdout, stderr, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: goEnv(params.Metadata), Logs: logs}, lookTool(params.Metadata, "golangci-lint"), args...)
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	result.Logs = logs.String()
+	if err != nil {
 		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			// If there are lint issues, capture them from stdout.
-			logger.Info("Command exited with non-zero status due to lint issues")
-			lines := strings.Split(stdout.String(), "\n")
-			for _, line := range lines {
-				if len(line) > 0 {
-					result.Issues = append(result.Issues, line)
-				}
-			}
-			return result, nil // Return issues without treating it as a hard failure.
-		} else {
-			logger.Error("Error running golangci-lint command", "error", err, "stderr", stderr.String(), "stdout", stdout.String())
+		if !errors.As(err, &exitErr) {
 			return nil, fmt.Errorf("running golangci-lint command: %w", err)
 		}
+		// If there are lint issues, capture them from stdout.
+		logger.Info("Command exited with non-zero status due to lint issues")
+		var output golangCILintOutput
+		if jsonErr := json.Unmarshal([]byte(stdout), &output); jsonErr != nil {
+			// No JSON means golangci-lint failed before linting, e.g. on an invalid config.
+			logger.Error("Error unmarshalling golangci-lint output", "error", jsonErr)
+			return nil, toolError("golangci-lint", err, stderr)
+		}
+		for _, issue := range output.Issues {
+			result.Issues = append(result.Issues, LintIssue{
+				Linter:   issue.FromLinter,
+				Severity: issue.Severity,
+				Text:     issue.Text,
+				File:     issue.Pos.Filename,
+				Line:     issue.Pos.Line,
+				Column:   issue.Pos.Column,
+			})
+		}
+		return result, nil // Return issues without treating it as a hard failure.
 	}
 
 	logger.Info("GolangCI-Lint ran successfully with no issues")
 	return result, nil
 }
 
-// Deploy simulates a deployment process
+// GoVulnCheck runs `govulncheck -json ./...` in the specified directory.
+func (pa *PipelineActivity) GoVulnCheck(ctx context.Context, params GoVulnCheckParams) (*GoVulnCheckResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &GoVulnCheckResult{
+		Metadata: params.Metadata,
+		Vulns:    []Vulnerability{},
+	}
+
+	govulncheck := lookTool(params.Metadata, "govulncheck")
+	if _, err := exec.LookPath(govulncheck); err != nil {
+		return nil, fmt.Errorf("govulncheck not found in PATH, install it with `go install golang.org/x/vuln/cmd/govulncheck@latest`: %w", err)
+	}
+
+	// With -json, govulncheck exits zero when vulnerabilities are found, so any error is a hard failure.
+	logs := &logTail{}
+	start := time.Now()
+	stdout, _, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: goEnv(params.Metadata), Logs: logs}, govulncheck, "-json", "./...")
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	result.Logs = logs.String()
+	if err != nil {
+		return nil, fmt.Errorf("running govulncheck command: %w", err)
+	}
+
+	vulns, err := parseGovulncheckOutput(strings.NewReader(stdout))
+	if err != nil {
+		logger.Error("Error decoding govulncheck output", "error", err, "stdout", stdout)
+		return nil, fmt.Errorf("decoding govulncheck output: %w", err)
+	}
+	result.Vulns = append(result.Vulns, vulns...)
+
+	logger.Info("Govulncheck ran successfully", "vulns", len(result.Vulns))
+	return result, nil
+}
+
+// parseGovulncheckOutput decodes the JSON message stream of govulncheck. Only findings whose
+// vulnerable function is actually called are reported, matching govulncheck's text output,
+// and each advisory is reported once per module.
+func parseGovulncheckOutput(r io.Reader) ([]Vulnerability, error) {
+	vulns := []Vulnerability{}
+	seen := map[string]bool{}
+
+	dec := json.NewDecoder(r)
+	for {
+		var msg govulncheckMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return vulns, nil
+			}
+			return nil, err
+		}
+		if msg.Finding == nil || len(msg.Finding.Trace) == 0 || msg.Finding.Trace[0].Function == "" {
+			continue
+		}
+
+		frame := msg.Finding.Trace[0]
+		key := msg.Finding.OSV + "@" + frame.Module
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		vulns = append(vulns, Vulnerability{
+			ID:           msg.Finding.OSV,
+			Module:       frame.Module,
+			Version:      frame.Version,
+			FixedVersion: msg.Finding.FixedVersion,
+		})
+	}
+}
+
+// GoDeploy runs the configured deploy command, or simulates a deployment process when none is configured.
 func (pa *PipelineActivity) GoDeploy(ctx context.Context, params GoDeployParams) (*GoDeployResult, error) {
+	if len(params.DeployCommand) == 0 {
+		return simulateDeploy(ctx, params)
+	}
+
+	logger := activity.GetLogger(ctx)
+
+	name, args := params.DeployCommand[0], params.DeployCommand[1:]
+	stdout, stderr, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: commandEnv(params.DeployEnv)}, name, args...)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			// A killed deploy may have deployed part way, and one that couldn't start would fail again.
+			return nil, temporal.NewNonRetryableApplicationError("running deploy command: "+err.Error(), "DeployFailed", err)
+		}
+		// The deploy ran and failed; report it as a pipeline failure rather than retrying it.
+		return &GoDeployResult{
+			Success:  false,
+			ErrorMsg: fmt.Sprintf("deploy command exited with status %d: %s", exitErr.ExitCode(), strings.TrimSpace(stderr)),
+		}, nil
+	}
+
+	logger.Info("Deployment completed successfully", "stdout", stdout)
+	return &GoDeployResult{
+		Success: true,
+	}, nil
+}
+
+// PreDeploy runs a verification command (e.g. `terraform plan`) that must pass before deploying.
+func (pa *PipelineActivity) PreDeploy(ctx context.Context, params PreDeployParams) (*PreDeployResult, error) {
+	logger := activity.GetLogger(ctx)
+
+	name, args := params.Command[0], params.Command[1:]
+	// The output of a plan reads best with stdout and stderr interleaved, as on a terminal.
+	output := &logTail{}
+	_, _, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: commandEnv(params.Env), Logs: output}, name, args...)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("running pre-deploy command: %w", err)
+		}
+		return &PreDeployResult{
+			Success:  false,
+			ErrorMsg: fmt.Sprintf("pre-deploy command exited with status %d", exitErr.ExitCode()),
+			Output:   output.String(),
+		}, nil
+	}
+
+	logger.Info("Pre-deploy verification passed", "output", output.String())
+	return &PreDeployResult{Success: true, Output: output.String()}, nil
+}
+
+// SmokeTest runs a smoke test command against a freshly deployed environment.
+func (pa *PipelineActivity) SmokeTest(ctx context.Context, params SmokeTestParams) (*SmokeTestResult, error) {
+	logger := activity.GetLogger(ctx)
+
+	name, args := params.Command[0], params.Command[1:]
+	stdout, stderr, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: commandEnv(params.Env)}, name, args...)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("running smoke test command: %w", err)
+		}
+		return &SmokeTestResult{
+			Success:  false,
+			ErrorMsg: fmt.Sprintf("smoke test exited with status %d: %s", exitErr.ExitCode(), strings.TrimSpace(stderr)),
+		}, nil
+	}
+
+	logger.Info("Smoke test passed", "stdout", stdout)
+	return &SmokeTestResult{Success: true}, nil
+}
+
+// simulateDeploy simulates a deployment process, so demos work without a real deploy target.
+func simulateDeploy(ctx context.Context, params GoDeployParams) (*GoDeployResult, error) {
 	logger := activity.GetLogger(ctx)
 
 	// Simulate deployment process
@@ -393,6 +1494,17 @@ func (pa *PipelineActivity) GoDeploy(ctx context.Context, params GoDeployParams)
 
 	return &GoDeployResult{
 		Success: true,
-		Error:   nil,
 	}, nil
 }
+
+// toolError wraps the error of a check tool that didn't produce a result. A tool that exited with
+// a status, e.g. gofmt on a file that doesn't parse, would fail the same way again, so it isn't
+// retried. Other errors, such as the tool failing to start or being killed, are.
+func toolError(name string, err error, stderr string) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("running %s command: %s: %s", name, err, strings.TrimSpace(stderr)), "ToolFailed", err)
+	}
+	return fmt.Errorf("running %s command: %w", name, err)
+}
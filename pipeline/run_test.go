@@ -0,0 +1,192 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestParseGoTestOutput(t *testing.T) {
+	output := `go: downloading github.com/stretchr/testify v1.9.0
+{"Action":"run","Package":"example.com/app","Test":"TestPass"}
+{"Action":"pass","Package":"example.com/app","Test":"TestPass","Elapsed":0.01}
+# example.com/app
+warning: some linker notice
+{"Action":"fail","Package":"example.com/app","Test":"TestFail","Elapsed":0.02}
+{"Action":"fail","Package":"example.com/app","Elapsed":0.05}
+{not json at all
+
+`
+
+	events, skipped := parseGoTestOutput(output)
+
+	assert.Equal(t, []GoTestCLIOutput{
+		{Action: "run", Package: "example.com/app", Test: "TestPass"},
+		{Action: "pass", Package: "example.com/app", Test: "TestPass", Elapsed: 0.01},
+		{Action: "fail", Package: "example.com/app", Test: "TestFail", Elapsed: 0.02},
+		{Action: "fail", Package: "example.com/app", Elapsed: 0.05},
+	}, events)
+	assert.Equal(t, []string{
+		"go: downloading github.com/stretchr/testify v1.9.0",
+		"# example.com/app",
+		"warning: some linker notice",
+		"{not json at all",
+	}, skipped)
+}
+
+func TestWithFailureOutput(t *testing.T) {
+	output := `{"ImportPath":"example.com/app [example.com/app.test]","Action":"build-output","Output":"# example.com/app [example.com/app.test]\n"}
+{"ImportPath":"example.com/app [example.com/app.test]","Action":"build-output","Output":"./app_test.go:6:17: undefined: Answer\n"}
+{"ImportPath":"example.com/app [example.com/app.test]","Action":"build-fail"}
+{"Action":"start","Package":"example.com/app"}
+{"Action":"output","Package":"example.com/app","Output":"FAIL\texample.com/app [build failed]\n"}
+{"Action":"fail","Package":"example.com/app","Elapsed":0,"FailedBuild":"example.com/app [example.com/app.test]"}
+{"Action":"output","Package":"example.com/setup","Output":"panic: missing config\n"}
+{"Action":"fail","Package":"example.com/setup","Elapsed":0.01}
+`
+
+	events, _ := parseGoTestOutput(output)
+	var failed []GoTestCLIOutput
+	for _, event := range withFailureOutput(events) {
+		if event.Action == "fail" {
+			failed = append(failed, event)
+		}
+	}
+
+	if assert.Len(t, failed, 2) {
+		assert.Equal(t, "# example.com/app [example.com/app.test]\n./app_test.go:6:17: undefined: Answer\n", failed[0].Output)
+		assert.Equal(t, "panic: missing config\n", failed[1].Output)
+	}
+}
+
+func TestParseGovulncheckOutput(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "govulncheck", "findings.json"))
+	assert.NoError(t, err)
+	defer f.Close()
+
+	vulns, err := parseGovulncheckOutput(f)
+	assert.NoError(t, err)
+	assert.Equal(t, []Vulnerability{
+		{ID: "GO-2023-2102", Module: "golang.org/x/net", Version: "v0.15.0", FixedVersion: "v0.17.0"},
+	}, vulns)
+}
+
+func TestGoTestArgs(t *testing.T) {
+	assert.Equal(t, []string{"test", "-json", "./...", "-count=1"}, goTestArgs(GoTestParams{Flags: []string{"-count=1"}}))
+	assert.Equal(t, []string{"test", "-json", "-race", "./...", "-count=1"}, goTestArgs(GoTestParams{Flags: []string{"-count=1"}, Race: true}))
+	assert.Equal(t, []string{"test", "-json", "./api/...", "./internal/store", "-count=1"},
+		goTestArgs(GoTestParams{Flags: []string{"-count=1"}, Packages: []string{"./api/...", "./internal/store"}}))
+}
+
+func TestGoTestParallelism(t *testing.T) {
+	assert.Equal(t, []string{"test", "-json", "-p", "2", "-parallel", "4", "./..."}, goTestArgs(GoTestParams{TestP: 2, TestParallel: 4}))
+	assert.Equal(t, []string{"test", "-json", "-parallel", "1", "./..."}, goTestArgs(GoTestParams{TestParallel: 1}))
+	assert.Equal(t, []string{"test", "-json", "./..."}, goTestArgs(GoTestParams{}), "zero values keep the go command's defaults")
+
+	params := PipelineParams{GitURL: gitUrl, TestP: -1}
+	assert.ErrorContains(t, params.Validate(), "must not be negative")
+}
+
+func TestBuildTags(t *testing.T) {
+	tags := []string{"integration", "netgo"}
+	assert.Equal(t, []string{"test", "-json", "-tags", "integration,netgo", "./...", "-v"},
+		goTestArgs(GoTestParams{Flags: []string{"-v"}, Tags: tags}))
+	assert.Equal(t, []string{"build", "-tags", "integration,netgo", "-v", "./api/..."},
+		goBuildArgs(GoBuildParams{Flags: []string{"-v"}, Tags: tags, Packages: []string{"./api/..."}}))
+	assert.Equal(t, []string{"generate", "-tags", "integration,netgo", "-v", "./..."},
+		goGenerateArgs(GoGenerateParams{Flags: []string{"-v"}, Tags: tags}))
+	assert.Equal(t, []string{"build", "./..."}, goBuildArgs(GoBuildParams{}))
+
+	params := PipelineParams{GitURL: gitUrl, BuildTags: tags}
+	assert.NoError(t, params.Validate())
+	for _, tag := range []string{"", "a,b", "a b"} {
+		params.BuildTags = []string{tag}
+		assert.ErrorContains(t, params.Validate(), "must be a single non-empty tag", tag)
+	}
+}
+
+func TestTestPackages(t *testing.T) {
+	params := PipelineParams{GitURL: gitUrl, TestPackages: []string{".", "./api/...", "./internal/store"}}
+	assert.NoError(t, params.Validate())
+
+	for _, pkg := range []string{"github.com/afanwang/go-sample/api", "api/...", "../other/...", "./api/../../other", "/abs/path"} {
+		params.TestPackages = []string{pkg}
+		assert.ErrorContains(t, params.Validate(), "must be relative to the repository", pkg)
+	}
+}
+
+func TestRaceUnsupported(t *testing.T) {
+	assert.Equal(t, "-race is not supported on linux/riscv64", raceUnsupported.FindString("go: -race is not supported on linux/riscv64\n"))
+	assert.Equal(t, `C compiler "gcc" not found`, raceUnsupported.FindString(`# runtime/cgo
+cgo: C compiler "gcc" not found: exec: "gcc": executable file not found in $PATH`))
+	assert.Empty(t, raceUnsupported.FindString("FAIL\texample.com/app [build failed]"))
+}
+
+func TestGolangCILintFlags(t *testing.T) {
+	// A golangci-lint that records its arguments and reports no issues.
+	argsFile := filepath.Join(t.TempDir(), "args")
+	fakeTool(t, "golangci-lint", "echo \"$@\" > "+argsFile+"\necho '{\"Issues\":[]}'\n")
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.GolangCILint)
+
+	_, err := env.ExecuteActivity(pa.GolangCILint, GolangCILintParams{
+		Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+		Flags:    []string{"--config", ".golangci.yml", "--timeout", "5m"},
+	})
+	assert.NoError(t, err)
+
+	args, err := os.ReadFile(argsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "run --config .golangci.yml --timeout 5m --out-format json\n", string(args))
+}
+
+func TestDeleteWorkdirActivity(t *testing.T) {
+	root := t.TempDir()
+	activities := &PipelineActivity{WorkdirRoot: root}
+	deleteWorkdir := func(workdir string) error {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(activities)
+		_, err := env.ExecuteActivity(activities.DeleteWorkdir, DeleteWorkdirParams{Metadata: PipelineActivityMetadata{Workdir: workdir}})
+		return err
+	}
+
+	t.Run("Workdir in root", func(t *testing.T) {
+		workdir, err := os.MkdirTemp(root, "run")
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(workdir, "go.mod"), []byte("module example.com/app\n"), 0o644))
+
+		assert.NoError(t, deleteWorkdir(workdir))
+		assert.NoDirExists(t, workdir)
+		// Deleting it again, e.g. on a retry, succeeds.
+		assert.NoError(t, deleteWorkdir(workdir))
+	})
+
+	t.Run("Empty workdir", func(t *testing.T) {
+		assert.ErrorContains(t, deleteWorkdir(""), "workdir is empty")
+	})
+
+	t.Run("Workdir outside root", func(t *testing.T) {
+		outside := t.TempDir()
+		assert.ErrorContains(t, deleteWorkdir(outside), "is not in the workdir root")
+		assert.DirExists(t, outside)
+
+		assert.ErrorContains(t, deleteWorkdir(root), "is not in the workdir root")
+		assert.ErrorContains(t, deleteWorkdir("/"), "is not in the workdir root")
+		assert.DirExists(t, root)
+	})
+
+	t.Run("Symlink to outside root", func(t *testing.T) {
+		outside := t.TempDir()
+		link := filepath.Join(root, "link")
+		assert.NoError(t, os.Symlink(outside, link))
+
+		assert.ErrorContains(t, deleteWorkdir(link), "is a symlink")
+		assert.DirExists(t, outside)
+	})
+}
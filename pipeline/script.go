@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// CustomStep is a check of the repository's own, e.g. ./scripts/ci.sh, run alongside the
+// built-in checks. It fails when its command exits with a non-zero status.
+type CustomStep struct {
+	// Name identifies the step in the progress, logs and failures of the pipeline.
+	Name string `json:"name" yaml:"name"`
+	// Command is run in the repository. A path to the program, as opposed to the name of one on
+	// the worker's PATH, must be within the repository unless AllowOutsideWorkdir is set.
+	Command             []string `json:"command" yaml:"command"`
+	AllowOutsideWorkdir bool     `json:"allow_outside_workdir" yaml:"allow_outside_workdir"`
+}
+
+// builtinSteps are the names of the pipeline's own steps, which custom steps can't take.
+var builtinSteps = map[string]bool{
+	"CheckDiskSpace": true, "FetchTarball": true, "GitClone": true, "ReadRepoConfig": true,
+	"ReadEnvFile": true, "GoModDownload": true, "GoInstallTools": true, "DetectChanges": true,
+	"CommitRange": true, "GoTest": true, "GoFmt": true, "GoModTidy": true, "GoModVerify": true,
+	"GoBuild": true, "GoGenerate": true, "GolangCILint": true, "GoVulnCheck": true,
+	"GoStaticcheck": true, "GoLicenseCheck": true, "GoDocCheck": true, "GoBenchmark": true,
+	"Matrix": true, "PreDeploy": true, "Sign": true, "DeployGate": true,
+	"Approval": true, "Deploy": true, "Rollback": true, "DeleteWorkdir": true, "Notify": true,
+	"Abort": true,
+}
+
+func validateCustomSteps(steps []CustomStep) error {
+	seen := map[string]bool{}
+	for _, step := range steps {
+		if step.Name == "" {
+			return fmt.Errorf("CustomSteps name is required")
+		}
+		if builtinSteps[step.Name] {
+			return fmt.Errorf("CustomSteps name %q is the name of a built-in step", step.Name)
+		}
+		if seen[step.Name] {
+			return fmt.Errorf("CustomSteps name %q is duplicated", step.Name)
+		}
+		seen[step.Name] = true
+		if len(step.Command) == 0 || step.Command[0] == "" {
+			return fmt.Errorf("CustomSteps %q command is required", step.Name)
+		}
+	}
+	return nil
+}
+
+// RunScript params and results
+type RunScriptParams struct {
+	Metadata            PipelineActivityMetadata
+	Name                string
+	Command             []string
+	AllowOutsideWorkdir bool
+}
+
+type RunScriptResult struct {
+	Success  bool
+	ErrorMsg string
+	// Logs is the combined stdout and stderr of the command.
+	Logs      string
+	ElapsedMs int64
+}
+
+// RunScript runs the command of a CustomStep in the workdir. A non-zero exit status is a
+// failed result, with the command's output in its logs.
+func (pa *PipelineActivity) RunScript(ctx context.Context, params RunScriptParams) (*RunScriptResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &RunScriptResult{}
+
+	if err := checkScriptPath(params.Command[0], params.Metadata.Workdir, params.AllowOutsideWorkdir); err != nil {
+		// The command and the checkout don't change between attempts.
+		return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("step %s: %s", params.Name, err), "UnsafeScript", err)
+	}
+
+	name, args := params.Command[0], params.Command[1:]
+	logs := &logTail{}
+	start := time.Now()
+	_, _, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: goEnv(params.Metadata), Logs: logs}, name, args...)
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	result.Logs = logs.String()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("running step %s: %w", params.Name, err)
+		}
+		result.ErrorMsg = fmt.Sprintf("%s exited with status %d", strings.Join(params.Command, " "), exitErr.ExitCode())
+		return result, nil
+	}
+
+	logger.Info("Custom step passed", "step", params.Name)
+	result.Success = true
+	return result, nil
+}
+
+// checkScriptPath verifies that program, when a path rather than a name looked up on PATH, is
+// within workdir, with relative paths resolved against workdir, unless allowOutside is set.
+func checkScriptPath(program, workdir string, allowOutside bool) error {
+	if !strings.ContainsRune(program, '/') && !strings.ContainsRune(program, filepath.Separator) {
+		return nil
+	}
+	if !filepath.IsAbs(program) {
+		program = filepath.Join(workdir, program)
+	}
+	resolved, err := filepath.EvalSymlinks(program)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", program, err)
+	}
+	if allowOutside {
+		return nil
+	}
+	root, err := filepath.EvalSymlinks(workdir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("%s is outside of the workdir, set allow_outside_workdir to run it", program)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestRunScriptActivity(t *testing.T) {
+	workdir := t.TempDir()
+	script := "echo checking $1\nif [ \"$1\" = bad ]; then echo 'lint failed' >&2; exit 3; fi\n"
+	assert.NoError(t, os.MkdirAll(filepath.Join(workdir, "scripts"), 0o755))
+	writeScript(t, filepath.Join(workdir, "scripts", "ci.sh"), script)
+	outside := filepath.Join(t.TempDir(), "ci.sh")
+	writeScript(t, outside, script)
+
+	run := func(t *testing.T, params RunScriptParams) (*RunScriptResult, error) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.RunScript)
+
+		params.Metadata = PipelineActivityMetadata{Workdir: workdir}
+		val, err := env.ExecuteActivity(pa.RunScript, params)
+		if err != nil {
+			return nil, err
+		}
+		result := &RunScriptResult{}
+		assert.NoError(t, val.Get(result))
+		return result, nil
+	}
+
+	t.Run("Passed", func(t *testing.T) {
+		result, err := run(t, RunScriptParams{Name: "ci", Command: []string{"./scripts/ci.sh", "good"}})
+		assert.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, "checking good\n", result.Logs)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		result, err := run(t, RunScriptParams{Name: "ci", Command: []string{"./scripts/ci.sh", "bad"}})
+		assert.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Equal(t, "./scripts/ci.sh bad exited with status 3", result.ErrorMsg)
+		// stdout and stderr come through separate pipes, so their lines may arrive in either order.
+		assert.Contains(t, result.Logs, "checking bad\n")
+		assert.Contains(t, result.Logs, "lint failed\n")
+	})
+
+	t.Run("Program on PATH", func(t *testing.T) {
+		result, err := run(t, RunScriptParams{Name: "ci", Command: []string{"sh", "scripts/ci.sh", "good"}})
+		assert.NoError(t, err)
+		assert.True(t, result.Success)
+	})
+
+	t.Run("Outside of the workdir", func(t *testing.T) {
+		for _, program := range []string{outside, "../" + filepath.Base(filepath.Dir(outside)) + "/ci.sh"} {
+			_, err := run(t, RunScriptParams{Name: "ci", Command: []string{program, "good"}})
+			var appErr *temporal.ApplicationError
+			if assert.ErrorAs(t, err, &appErr) {
+				assert.Equal(t, "UnsafeScript", appErr.Type())
+				assert.True(t, appErr.NonRetryable())
+			}
+		}
+
+		result, err := run(t, RunScriptParams{Name: "ci", Command: []string{outside, "good"}, AllowOutsideWorkdir: true})
+		assert.NoError(t, err)
+		assert.True(t, result.Success)
+	})
+}
+
+func TestCustomSteps(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		params := PipelineParams{GitURL: gitUrl, CustomSteps: []CustomStep{{Name: "GoTest", Command: []string{"make", "test"}}}}
+		assert.ErrorContains(t, params.Validate(), "name of a built-in step")
+
+		params.CustomSteps = []CustomStep{{Name: "ci", Command: []string{"make"}}, {Name: "ci", Command: []string{"make"}}}
+		assert.ErrorContains(t, params.Validate(), `"ci" is duplicated`)
+
+		params.CustomSteps = []CustomStep{{Name: "ci"}}
+		assert.ErrorContains(t, params.Validate(), "command is required")
+	})
+
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.RunScript, mock.Anything, mock.MatchedBy(func(p RunScriptParams) bool { return p.Name == "schema" })).
+		Return(&RunScriptResult{Success: true, Logs: "schema ok\n", ElapsedMs: 1200}, nil)
+	env.OnActivity(pa.RunScript, mock.Anything, mock.MatchedBy(func(p RunScriptParams) bool { return p.Name == "ci" })).
+		Return(&RunScriptResult{ErrorMsg: "./scripts/ci.sh exited with status 1", Logs: "lint failed\n"}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, CustomSteps: []CustomStep{
+		{Name: "schema", Command: []string{"make", "schema"}},
+		{Name: "ci", Command: []string{"./scripts/ci.sh"}},
+	}})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, []PipelineFailure{{Activity: "ci", Kind: FailureIssues, Details: "./scripts/ci.sh exited with status 1"}}, result.Failures)
+	assert.Equal(t, "lint failed\n", result.Logs["ci"])
+	assert.Equal(t, "schema ok\n", result.Logs["schema"])
+	states := map[string]StepState{}
+	for _, step := range result.Steps {
+		states[step.Name] = step.State
+	}
+	assert.Equal(t, StepFailed, states["ci"])
+	assert.Equal(t, StepDone, states["schema"])
+	env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+}
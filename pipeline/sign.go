@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// signTimeout bounds SignArtifacts, which for keyless signing also waits on the OIDC provider,
+// Fulcio and Rekor.
+const signTimeout = 5 * time.Minute
+
+// SignConfig signs container images with cosign once the pre-deploy command, which typically
+// builds and pushes them, has passed. A failed signature blocks the deploy.
+type SignConfig struct {
+	// Images are the image references to sign, preferably by digest, e.g.
+	// "registry.example.com/api@sha256:...".
+	Images []string `json:"images" yaml:"images"`
+	// Key is the cosign key to sign with, a path on the worker or a KMS URI. Its password, if
+	// any, is read from COSIGN_PASSWORD on the worker. Empty signs keyless.
+	Key string `json:"key" yaml:"key"`
+}
+
+func (c *SignConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if len(c.Images) == 0 {
+		return fmt.Errorf("Sign images are required")
+	}
+	for _, image := range c.Images {
+		if image == "" || strings.HasPrefix(image, "-") {
+			return fmt.Errorf("Sign image %q is not an image reference", image)
+		}
+	}
+	return nil
+}
+
+// SignArtifacts params and results
+type SignArtifactsParams struct {
+	Metadata PipelineActivityMetadata
+	Config   SignConfig
+}
+
+type SignArtifactsResult struct {
+	Success  bool
+	ErrorMsg string
+	// Signatures has the signature of each image signed, in the order of the config's images.
+	Signatures []ArtifactSignature
+	Logs       string
+}
+
+// ArtifactSignature is the cosign signature of an image.
+type ArtifactSignature struct {
+	Image string `json:"image"`
+	// Signature is the reference of the signature in the registry, as printed by
+	// `cosign triangulate`, e.g. "registry.example.com/api:sha256-....sig".
+	Signature string `json:"signature"`
+}
+
+// SignArtifacts signs each image of params.Config with `cosign sign` and looks up the reference
+// of its signature. An image cosign fails to sign is a failed result.
+func (pa *PipelineActivity) SignArtifacts(ctx context.Context, params SignArtifactsParams) (*SignArtifactsResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &SignArtifactsResult{Signatures: []ArtifactSignature{}}
+
+	cosign := lookTool(params.Metadata, "cosign")
+	if _, err := exec.LookPath(cosign); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"cosign not found in PATH, install it with `go install github.com/sigstore/cosign/v2/cmd/cosign@latest` or PipelineParams.Tools",
+			"ToolNotFound", err)
+	}
+
+	logs := &logTail{}
+	defer func() { result.Logs = logs.String() }()
+	for _, image := range params.Config.Images {
+		// --yes skips the confirmation keyless signing asks for before uploading to Rekor.
+		args := []string{"sign", "--yes"}
+		if params.Config.Key != "" {
+			args = append(args, "--key", params.Config.Key)
+		}
+		for _, args := range [][]string{append(args, image), {"triangulate", image}} {
+			stdout, stderr, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Logs: logs}, cosign, args...)
+			if err != nil {
+				var exitErr *exec.ExitError
+				if !errors.As(err, &exitErr) {
+					return nil, fmt.Errorf("running cosign %s: %w", args[0], err)
+				}
+				result.ErrorMsg = fmt.Sprintf("cosign %s %s exited with status %d: %s", args[0], image, exitErr.ExitCode(), lastLine(stderr))
+				return result, nil
+			}
+			if args[0] == "triangulate" {
+				result.Signatures = append(result.Signatures, ArtifactSignature{Image: image, Signature: lastLine(stdout)})
+			}
+		}
+	}
+
+	logger.Info("Signed images", "signatures", result.Signatures)
+	result.Success = true
+	return result, nil
+}
+
+// signArtifacts runs SignArtifacts and records its signatures, or its failure, in result.
+func signArtifacts(ctx workflow.Context, params PipelineParams, metadata PipelineActivityMetadata, result *PipelineResult, progress *PipelineProgress) {
+	progress.set("Sign", StepRunning)
+	ctx = workflow.WithStartToCloseTimeout(ctx, signTimeout)
+	rSign := &SignArtifactsResult{}
+	err := workflow.ExecuteActivity(ctx, pa.SignArtifacts, SignArtifactsParams{Metadata: metadata, Config: *params.Sign}).Get(ctx, rSign)
+	switch {
+	case err != nil:
+		result.Failures = append(result.Failures, errorFailure("Sign", err))
+	case !rSign.Success:
+		result.Failures = append(result.Failures, PipelineFailure{Activity: "Sign", Kind: FailureIssues, Details: rSign.ErrorMsg})
+	}
+	result.Signatures = rSign.Signatures
+	progress.set("Sign", stepStateFor(result))
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// fakeCosign installs a cosign that records its arguments in the returned file, and fails to
+// sign the images containing "unsigned".
+func fakeCosign(t *testing.T) (calls string) {
+	calls = filepath.Join(t.TempDir(), "calls")
+	fakeTool(t, "cosign", `echo "$@" >> `+calls+`
+case "$*" in
+*unsigned*) echo "error: signing: UNAUTHORIZED" >&2; exit 1 ;;
+triangulate*) echo "registry.example.com/api:sha256-abc.sig" ;;
+esac
+`)
+	return calls
+}
+
+func TestSignArtifactsActivity(t *testing.T) {
+	t.Run("Signs each image", func(t *testing.T) {
+		calls := fakeCosign(t)
+
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.SignArtifacts)
+
+		val, err := env.ExecuteActivity(pa.SignArtifacts, SignArtifactsParams{
+			Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+			Config:   SignConfig{Images: []string{"registry.example.com/api@sha256:abc"}, Key: "cosign.key"},
+		})
+		assert.NoError(t, err)
+
+		var result SignArtifactsResult
+		assert.NoError(t, val.Get(&result))
+		assert.True(t, result.Success)
+		assert.Equal(t, []ArtifactSignature{
+			{Image: "registry.example.com/api@sha256:abc", Signature: "registry.example.com/api:sha256-abc.sig"},
+		}, result.Signatures)
+
+		got, err := os.ReadFile(calls)
+		assert.NoError(t, err)
+		assert.Equal(t, "sign --yes --key cosign.key registry.example.com/api@sha256:abc\n"+
+			"triangulate registry.example.com/api@sha256:abc\n", string(got))
+	})
+
+	t.Run("A rejected signature fails the result", func(t *testing.T) {
+		fakeCosign(t)
+
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.SignArtifacts)
+
+		val, err := env.ExecuteActivity(pa.SignArtifacts, SignArtifactsParams{
+			Metadata: PipelineActivityMetadata{Workdir: t.TempDir()},
+			Config:   SignConfig{Images: []string{"registry.example.com/unsigned:v1"}},
+		})
+		assert.NoError(t, err)
+
+		var result SignArtifactsResult
+		assert.NoError(t, val.Get(&result))
+		assert.False(t, result.Success)
+		assert.Equal(t, "cosign sign registry.example.com/unsigned:v1 exited with status 1: error: signing: UNAUTHORIZED", result.ErrorMsg)
+		assert.Empty(t, result.Signatures)
+	})
+
+	t.Run("A missing cosign is not retried", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.SignArtifacts)
+
+		_, err := env.ExecuteActivity(pa.SignArtifacts, SignArtifactsParams{Config: SignConfig{Images: []string{"api:v1"}}})
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.True(t, appErr.NonRetryable())
+			assert.Equal(t, "ToolNotFound", appErr.Type())
+		}
+	})
+}
+
+func TestSign(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		params := PipelineParams{GitURL: gitUrl, Sign: &SignConfig{}}
+		assert.ErrorContains(t, params.Validate(), "Sign images are required")
+
+		params.Sign.Images = []string{"--key=other"}
+		assert.ErrorContains(t, params.Validate(), "is not an image reference")
+	})
+
+	t.Run("A failed signature blocks the deploy", func(t *testing.T) {
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.SignArtifacts, mock.Anything, mock.Anything).Return(&SignArtifactsResult{ErrorMsg: "cosign sign exited with status 1"}, nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, Sign: &SignConfig{Images: []string{"api:v1"}}})
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Equal(t, []PipelineFailure{{Activity: "Sign", Kind: FailureIssues, Details: "cosign sign exited with status 1"}}, result.Failures)
+		env.AssertNotCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Signatures are in the result", func(t *testing.T) {
+		signatures := []ArtifactSignature{{Image: "api:v1", Signature: "api:sha256-abc.sig"}}
+		env := newTestWorkflowEnvironment()
+		env.OnActivity(pa.SignArtifacts, mock.Anything, mock.Anything).Return(&SignArtifactsResult{Success: true, Signatures: signatures}, nil)
+		mockAllActivitiesSuccess(env)
+
+		env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, Sign: &SignConfig{Images: []string{"api:v1"}}})
+		assert.True(t, env.IsWorkflowCompleted())
+
+		var result PipelineResult
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Empty(t, result.Failures)
+		assert.Equal(t, signatures, result.Signatures)
+		env.AssertCalled(t, "GoDeploy", mock.Anything, mock.Anything)
+	})
+}
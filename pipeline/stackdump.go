@@ -0,0 +1,20 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// stackDumpGrace is how long a cancelled command gets to print its stack dump before it is killed.
+const stackDumpGrace = 500 * time.Millisecond
+
+// withStackDumpDeadline returns a context for running a command that expires shortly before ctx
+// does. This leaves time to collect the stack dump of a hung command and report it in the
+// activity result before Temporal times out the activity itself.
+func withStackDumpDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-3*stackDumpGrace))
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package pipeline
+
+import "os/exec"
+
+// stackDumpOnCancel is a no-op on platforms without SIGQUIT; the command is killed outright.
+func stackDumpOnCancel(cmd *exec.Cmd) {}
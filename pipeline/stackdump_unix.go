@@ -0,0 +1,22 @@
+//go:build unix
+
+package pipeline
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// stackDumpOnCancel makes cmd send SIGQUIT to its whole process group when its context is done,
+// so hung Go programs (including test binaries started by `go test`) print their goroutine stacks.
+// Anything still running after stackDumpGrace is killed.
+func stackDumpOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pgid := -cmd.Process.Pid
+		time.AfterFunc(stackDumpGrace, func() { _ = syscall.Kill(pgid, syscall.SIGKILL) })
+		return syscall.Kill(pgid, syscall.SIGQUIT)
+	}
+	cmd.WaitDelay = 2 * stackDumpGrace
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStackDumpOnCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Mirror GoTest: with -json the test binary's output is streamed rather than buffered by `go test`.
+	cmd := exec.CommandContext(ctx, "go", "test", "-json", "./...")
+	stackDumpOnCancel(cmd)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Dir = filepath.Join("testdata", "hanging")
+
+	assert.Error(t, cmd.Run())
+
+	events, _ := parseGoTestOutput(stdout.String())
+	var dump strings.Builder
+	for _, event := range events {
+		dump.WriteString(event.Output)
+	}
+	assert.Contains(t, dump.String(), "SIGQUIT: quit")
+	assert.Contains(t, dump.String(), "hanging.TestHang")
+}
@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// GoStaticcheck params and results
+type GoStaticcheckParams struct {
+	Metadata PipelineActivityMetadata
+}
+
+type GoStaticcheckResult struct {
+	Issues    []LintIssue
+	Logs      string
+	ElapsedMs int64
+}
+
+// GoStaticcheck runs `staticcheck ./...` in the specified directory. The repository's own
+// staticcheck.conf, if any, selects the checks.
+func (pa *PipelineActivity) GoStaticcheck(ctx context.Context, params GoStaticcheckParams) (*GoStaticcheckResult, error) {
+	logger := activity.GetLogger(ctx)
+	result := &GoStaticcheckResult{
+		Issues: []LintIssue{},
+	}
+
+	staticcheck := lookTool(params.Metadata, "staticcheck")
+	if _, err := exec.LookPath(staticcheck); err != nil {
+		// Retrying won't install it, so fail the step right away.
+		return nil, temporal.NewNonRetryableApplicationError(
+			"staticcheck not found in PATH, install it with `go install honnef.co/go/tools/cmd/staticcheck@latest` or PipelineParams.Tools",
+			"ToolNotFound", err)
+	}
+
+	logs := &logTail{}
+	start := time.Now()
+	stdout, stderr, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: goEnv(params.Metadata), Logs: logs}, staticcheck, "./...")
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	result.Logs = logs.String()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("running staticcheck command: %w", err)
+		}
+		// staticcheck exits non-zero when it reports issues.
+		logger.Info("Command exited with non-zero status due to staticcheck issues")
+	}
+
+	issues, parseErr := parseStaticcheckOutput(strings.NewReader(stdout))
+	if parseErr != nil {
+		return nil, fmt.Errorf("parsing staticcheck output: %w", parseErr)
+	}
+	// A run that failed without reporting any issue, e.g. a package that doesn't type check.
+	if len(issues) == 0 && err != nil {
+		return nil, toolError("staticcheck", err, stderr+stdout)
+	}
+	result.Issues = append(result.Issues, issues...)
+
+	logger.Info("Staticcheck ran", "issues", len(result.Issues))
+	return result, nil
+}
+
+// staticcheckLine matches the default text output of staticcheck: `file:line:col: message (check)`.
+var staticcheckLine = regexp.MustCompile(`^(.+?):(\d+):(\d+): (.*) \(([A-Z]+\d+)\)$`)
+
+// parseStaticcheckOutput parses the text output of staticcheck into lint issues named after the
+// check that reported them, e.g. SA4006. Other lines are ignored.
+func parseStaticcheckOutput(r io.Reader) ([]LintIssue, error) {
+	issues := []LintIssue{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		m := staticcheckLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		line, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+		issues = append(issues, LintIssue{
+			Linter: m[5],
+			Text:   m[4],
+			File:   m[1],
+			Line:   line,
+			Column: column,
+		})
+	}
+	return issues, scanner.Err()
+}
@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestParseStaticcheckOutput(t *testing.T) {
+	output := `main.go:12:2: this value of err is never used (SA4006)
+internal/db/db.go:40:9: should use errors.New(...) instead of fmt.Errorf(...) (S1028)
+-: could not analyze dependency example.com/broken
+`
+
+	issues, err := parseStaticcheckOutput(strings.NewReader(output))
+	assert.NoError(t, err)
+	assert.Equal(t, []LintIssue{
+		{Linter: "SA4006", Text: "this value of err is never used", File: "main.go", Line: 12, Column: 2},
+		{Linter: "S1028", Text: "should use errors.New(...) instead of fmt.Errorf(...)", File: "internal/db/db.go", Line: 40, Column: 9},
+	}, issues)
+}
+
+func TestGoStaticcheckActivity(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.GoStaticcheck)
+
+	_, err := env.ExecuteActivity(pa.GoStaticcheck, GoStaticcheckParams{Metadata: PipelineActivityMetadata{Workdir: t.TempDir()}})
+	var appErr *temporal.ApplicationError
+	if assert.ErrorAs(t, err, &appErr) {
+		assert.True(t, appErr.NonRetryable())
+		assert.Contains(t, appErr.Error(), "staticcheck not found in PATH")
+	}
+}
+
+func TestGoStaticcheckActivityToolFailed(t *testing.T) {
+	// A staticcheck that fails without reporting any issue, like on a package that doesn't type check.
+	fakeTool(t, "staticcheck", "echo 'main.go:3:1: expected declaration' >&2\nexit 1\n")
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.RegisterActivity(pa.GoStaticcheck)
+
+	_, err := env.ExecuteActivity(pa.GoStaticcheck, GoStaticcheckParams{Metadata: PipelineActivityMetadata{Workdir: t.TempDir()}})
+	var appErr *temporal.ApplicationError
+	if assert.ErrorAs(t, err, &appErr) {
+		assert.True(t, appErr.NonRetryable())
+		assert.Equal(t, "ToolFailed", appErr.Type())
+		assert.Contains(t, appErr.Error(), "expected declaration")
+	}
+}
+
+func TestStaticcheck(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoStaticcheck, mock.Anything, mock.Anything).Return(&GoStaticcheckResult{
+		Issues: []LintIssue{{Linter: "SA4006", Text: "this value of err is never used", File: "main.go", Line: 12, Column: 2}},
+	}, nil)
+	mockAllActivitiesSuccess(env)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{GitURL: gitUrl, Staticcheck: true})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	if assert.Len(t, result.Failures, 1) {
+		assert.Equal(t, "GoStaticcheck", result.Failures[0].Activity)
+	}
+}
@@ -0,0 +1,216 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// maxResultBytes is the JSON size a PipelineResult is summarized down to. It is half of
+	// Temporal's 2MB payload limit, leaving headroom for the encoding overhead.
+	maxResultBytes = 1 << 20
+	// maxResultTests caps each list of tests kept in a summarized result.
+	maxResultTests = 100
+	// maxSummarizedLogBytes is the tail of each check's logs kept in a summarized result.
+	maxSummarizedLogBytes = 4 << 10
+)
+
+// ResultSummary replaces the details dropped from a PipelineResult too large to return.
+type ResultSummary struct {
+	PassedTests    int `json:"passed_tests"`
+	FailedTests    int `json:"failed_tests"`
+	FlakyTests     int `json:"flaky_tests,omitempty"`
+	FailedPackages int `json:"failed_packages"`
+	// Artifact is where the full test results were stored, if uploading them succeeded.
+	Artifact string `json:"artifact,omitempty"`
+	// LogsTruncated is set when the logs were cut down to their last lines, or dropped.
+	LogsTruncated bool `json:"logs_truncated,omitempty"`
+}
+
+// UploadArtifact params and results
+type UploadArtifactParams struct {
+	// Name is the file name of the artifact, unique within the workflow run.
+	Name    string
+	Content json.RawMessage
+}
+
+type UploadArtifactResult struct {
+	Location string
+}
+
+// UploadArtifact stores details too large for a workflow result under the worker's ArtifactDir,
+// in a directory per workflow run.
+func (pa *PipelineActivity) UploadArtifact(ctx context.Context, params UploadArtifactParams) (*UploadArtifactResult, error) {
+	if pa.ArtifactDir == "" {
+		return nil, temporal.NewNonRetryableApplicationError("no artifact directory configured", "NoArtifactDir", nil)
+	}
+
+	info := activity.GetInfo(ctx)
+	dir := filepath.Join(pa.ArtifactDir, info.WorkflowExecution.ID, info.WorkflowExecution.RunID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating artifact directory: %w", err)
+	}
+	location := filepath.Join(dir, filepath.Base(params.Name))
+	if err := os.WriteFile(location, params.Content, 0o644); err != nil {
+		return nil, fmt.Errorf("writing artifact: %w", err)
+	}
+
+	activity.GetLogger(ctx).Info("Artifact uploaded", "location", location, "bytes", len(params.Content))
+	return &UploadArtifactResult{Location: location}, nil
+}
+
+// summarizeResult keeps result under maxResultBytes, so the workflow can complete. The full test
+// results are uploaded as an artifact first; failing to upload them doesn't fail the pipeline.
+func summarizeResult(ctx workflow.Context, result *PipelineResult) {
+	if resultSize(result) <= maxResultBytes {
+		return
+	}
+	logger := workflow.GetLogger(ctx)
+
+	artifact := ""
+	if result.Tests != nil {
+		content, err := json.Marshal(result.Tests)
+		if err == nil {
+			rUpload := &UploadArtifactResult{}
+			err = workflow.ExecuteActivity(ctx, pa.UploadArtifact, UploadArtifactParams{Name: "tests.json", Content: content}).Get(ctx, rUpload)
+			artifact = rUpload.Location
+		}
+		if err != nil {
+			logger.Warn("Failed to upload the full test results", "error", err)
+		}
+	}
+
+	capResult(result, artifact)
+	logger.Warn("Pipeline result too large, summarized it", "bytes", resultSize(result))
+}
+
+// capResult drops details from result until it fits in maxResultBytes: first it caps the lists
+// of tests, then the logs of each check, then it drops the logs altogether.
+func capResult(result *PipelineResult, artifact string) {
+	summary := &ResultSummary{Artifact: artifact}
+	if result.Tests != nil {
+		counts := testCounts(result.Tests)
+		summary.PassedTests = counts.PassedTests
+		summary.FailedTests = counts.FailedTests
+		summary.FlakyTests = counts.FlakyTests
+		summary.FailedPackages = counts.FailedPackages
+
+		tests := *result.Tests
+		tests.Summary = nil
+		tests.PassedTests = []GoTestCLIOutput{}
+		tests.FailedTests = capTests(tests.FailedTests)
+		tests.FlakyTests = capTests(tests.FlakyTests)
+		tests.FailedPackages = capTests(tests.FailedPackages)
+		result.Tests = &tests
+	}
+	result.Summary = summary
+
+	// GoTest failures carry the failed tests as their details.
+	for i, failure := range result.Failures {
+		if tests, ok := failure.Details.([]GoTestCLIOutput); ok {
+			result.Failures[i].Details = capTests(tests)
+		}
+	}
+	if resultSize(result) <= maxResultBytes {
+		return
+	}
+
+	logs := make(map[string]string, len(result.Logs))
+	for name, log := range result.Logs {
+		if len(log) > maxSummarizedLogBytes {
+			log = log[len(log)-maxSummarizedLogBytes:]
+		}
+		logs[name] = log
+	}
+	result.Logs = logs
+	summary.LogsTruncated = len(logs) > 0
+	if resultSize(result) <= maxResultBytes {
+		return
+	}
+	result.Logs = nil
+}
+
+// capGoTestResult keeps a GoTest activity result under maxResultBytes, as Temporal fails an
+// activity whose result is over its payload limit. It first cuts the output of each event down to
+// its last maxSummarizedLogBytes, then drops the passed tests, then caps the other lists.
+func capGoTestResult(result *GoTestResult) {
+	if goTestResultSize(result) <= maxResultBytes {
+		return
+	}
+	counts := testCounts(result)
+	result.Summary = &counts
+	for _, tests := range [][]GoTestCLIOutput{result.PassedTests, result.FailedTests, result.FlakyTests, result.FailedPackages} {
+		for i := range tests {
+			if output := tests[i].Output; len(output) > maxSummarizedLogBytes {
+				tests[i].Output = output[len(output)-maxSummarizedLogBytes:]
+				result.Summary.LogsTruncated = true
+			}
+		}
+	}
+	if len(result.Diagnostics) > maxLogBytes {
+		result.Diagnostics = result.Diagnostics[len(result.Diagnostics)-maxLogBytes:]
+		result.Summary.LogsTruncated = true
+	}
+	if goTestResultSize(result) <= maxResultBytes {
+		return
+	}
+
+	result.PassedTests = []GoTestCLIOutput{}
+	if goTestResultSize(result) <= maxResultBytes {
+		return
+	}
+	result.FailedTests = capTests(result.FailedTests)
+	result.FlakyTests = capTests(result.FlakyTests)
+	result.FailedPackages = capTests(result.FailedPackages)
+}
+
+// testCounts returns the number of tests of each kind of result, including those
+// capGoTestResult dropped.
+func testCounts(result *GoTestResult) ResultSummary {
+	if result.Summary != nil {
+		return ResultSummary{
+			PassedTests:    result.Summary.PassedTests,
+			FailedTests:    result.Summary.FailedTests,
+			FlakyTests:     result.Summary.FlakyTests,
+			FailedPackages: result.Summary.FailedPackages,
+		}
+	}
+	return ResultSummary{
+		PassedTests:    len(result.PassedTests),
+		FailedTests:    len(result.FailedTests),
+		FlakyTests:     len(result.FlakyTests),
+		FailedPackages: len(result.FailedPackages),
+	}
+}
+
+func capTests(tests []GoTestCLIOutput) []GoTestCLIOutput {
+	if len(tests) > maxResultTests {
+		return tests[:maxResultTests]
+	}
+	return tests
+}
+
+// goTestResultSize returns the size of result encoded as JSON.
+func goTestResultSize(result *GoTestResult) int {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// resultSize returns the size of result encoded as JSON, as Temporal's default data converter does.
+func resultSize(result *PipelineResult) int {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestCapResult(t *testing.T) {
+	t.Run("Summarizes a huge result", func(t *testing.T) {
+		tests := &GoTestResult{}
+		output := strings.Repeat("x", 200)
+		for i := 0; i < 20000; i++ {
+			test := GoTestCLIOutput{Package: "example.com/pkg", Test: fmt.Sprintf("Test%d", i), Output: output}
+			tests.PassedTests = append(tests.PassedTests, test)
+			tests.FailedTests = append(tests.FailedTests, test)
+		}
+		result := &PipelineResult{
+			Failures: []PipelineFailure{{Activity: "GoTest", Details: tests.FailedTests}},
+			Tests:    tests,
+			Logs: map[string]string{
+				"GoTest":  strings.Repeat("y", maxLogBytes),
+				"GoBuild": strings.Repeat("z", maxLogBytes),
+			},
+		}
+		assert.Greater(t, resultSize(result), 2<<20)
+
+		capResult(result, "/artifacts/tests.json")
+
+		assert.LessOrEqual(t, resultSize(result), maxResultBytes)
+		assert.Equal(t, &ResultSummary{
+			PassedTests: 20000,
+			FailedTests: 20000,
+			Artifact:    "/artifacts/tests.json",
+		}, result.Summary)
+		assert.Empty(t, result.Tests.PassedTests)
+		assert.Len(t, result.Tests.FailedTests, maxResultTests)
+		assert.Len(t, result.Failures[0].Details, maxResultTests)
+		assert.Len(t, result.Logs["GoTest"], maxLogBytes, "logs are kept while the result fits")
+	})
+
+	t.Run("Truncates logs when capping tests isn't enough", func(t *testing.T) {
+		logs := map[string]string{}
+		for i := 0; i < 20; i++ {
+			logs[fmt.Sprintf("GoTest (repo%d)", i)] = strings.Repeat("y", maxLogBytes)
+		}
+		result := &PipelineResult{Logs: logs}
+
+		capResult(result, "")
+
+		assert.LessOrEqual(t, resultSize(result), maxResultBytes)
+		assert.True(t, result.Summary.LogsTruncated)
+		assert.Len(t, result.Logs["GoTest (repo0)"], maxSummarizedLogBytes)
+	})
+}
+
+func TestCapGoTestResult(t *testing.T) {
+	t.Run("Cuts the output of failures down", func(t *testing.T) {
+		result := &GoTestResult{
+			PassedTests:    []GoTestCLIOutput{{Package: "example.com/pkg", Test: "TestPass"}},
+			FailedPackages: []GoTestCLIOutput{{Package: "example.com/pkg", Output: strings.Repeat("x", 2<<20)}},
+			Diagnostics:    strings.Repeat("y", 2<<20),
+		}
+
+		capGoTestResult(result)
+
+		assert.LessOrEqual(t, goTestResultSize(result), maxResultBytes)
+		assert.Len(t, result.FailedPackages[0].Output, maxSummarizedLogBytes)
+		assert.Len(t, result.Diagnostics, maxLogBytes)
+		assert.Len(t, result.PassedTests, 1, "passed tests are kept while the result fits")
+		assert.Equal(t, &ResultSummary{PassedTests: 1, FailedPackages: 1, LogsTruncated: true}, result.Summary)
+	})
+
+	t.Run("Drops passed tests, then caps failures", func(t *testing.T) {
+		result := &GoTestResult{}
+		output := strings.Repeat("x", 200)
+		for i := 0; i < 20000; i++ {
+			test := GoTestCLIOutput{Package: "example.com/pkg", Test: fmt.Sprintf("Test%d", i), Output: output}
+			result.PassedTests = append(result.PassedTests, test)
+			result.FailedTests = append(result.FailedTests, test)
+		}
+
+		capGoTestResult(result)
+
+		assert.LessOrEqual(t, goTestResultSize(result), maxResultBytes)
+		assert.Empty(t, result.PassedTests)
+		assert.Len(t, result.FailedTests, maxResultTests)
+		assert.Equal(t, &ResultSummary{PassedTests: 20000, FailedTests: 20000}, result.Summary)
+
+		// The pipeline result counts the tests the activity dropped.
+		pipelineResult := &PipelineResult{Tests: result}
+		capResult(pipelineResult, "")
+		assert.Equal(t, 20000, pipelineResult.Summary.PassedTests)
+		assert.Nil(t, pipelineResult.Tests.Summary)
+	})
+
+	t.Run("Small results are left alone", func(t *testing.T) {
+		result := &GoTestResult{PassedTests: []GoTestCLIOutput{{Package: "example.com/pkg", Test: "TestPass"}}}
+		capGoTestResult(result)
+		assert.Nil(t, result.Summary)
+		assert.Len(t, result.PassedTests, 1)
+	})
+}
+
+func TestUploadArtifactActivity(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	a := &PipelineActivity{ArtifactDir: t.TempDir()}
+	env.RegisterActivity(a.UploadArtifact)
+
+	val, err := env.ExecuteActivity(a.UploadArtifact, UploadArtifactParams{Name: "tests.json", Content: json.RawMessage(`{"FailedTests":[]}`)})
+	assert.NoError(t, err)
+
+	var result UploadArtifactResult
+	assert.NoError(t, val.Get(&result))
+	content, err := os.ReadFile(result.Location)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"FailedTests":[]}`, string(content))
+}
@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// ANSI colors of the step states in WriteTable. Every cell of the status column is colored, if
+// only with the default color, so they all have the same width overhead and the columns stay aligned.
+var stepColors = map[StepState]string{
+	StepDone:    "\x1b[32m",
+	StepFailed:  "\x1b[31m",
+	StepSkipped: "\x1b[33m",
+	StepPending: "\x1b[39m",
+	StepRunning: "\x1b[39m",
+}
+
+const colorReset = "\x1b[0m"
+
+// WriteTable renders the steps of a PipelineResult as a table of their state, duration, and
+// number of failures, followed by the totals. With color, states are colored for a terminal.
+func WriteTable(w io.Writer, result *PipelineResult, color bool) error {
+	failures := map[string]int{}
+	for _, failure := range result.Failures {
+		failures[checkStepName(failure.Activity, failure.Repo)]++
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "STEP\t%s\tDURATION\tFAILURES\n", paint("STATUS", stepColors[StepPending], color))
+	var total time.Duration
+	counts := map[StepState]int{}
+	listed := 0
+	for _, step := range result.Steps {
+		total += step.Duration
+		counts[step.State]++
+		listed += failures[step.Name]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", step.Name, paint(string(step.State), stepColors[step.State], color), formatDuration(step.Duration), failures[step.Name])
+	}
+	// Failures of no step, e.g. an abort signal, only count towards the total.
+	if other := len(result.Failures) - listed; other > 0 {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", "(other)", paint(string(StepFailed), stepColors[StepFailed], color), formatDuration(0), other)
+	}
+	states := fmt.Sprintf("%d done, %d failed, %d skipped", counts[StepDone], counts[StepFailed], counts[StepSkipped])
+	fmt.Fprintf(tw, "TOTAL\t%s\t%s\t%d\n", paint(states, stepColors[StepPending], color), formatDuration(total), len(result.Failures))
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing result table: %w", err)
+	}
+
+	if result.Skipped != "" {
+		if _, err := fmt.Fprintln(w, result.Skipped); err != nil {
+			return fmt.Errorf("writing result table: %w", err)
+		}
+	}
+	return nil
+}
+
+// paint colors text with an ANSI color code, when color is set.
+func paint(text, code string, color bool) string {
+	if !color {
+		return text
+	}
+	return code + text + colorReset
+}
+
+// formatDuration rounds d for display, or returns "-" for steps that didn't run.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
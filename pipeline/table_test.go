@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTable(t *testing.T) {
+	result := &PipelineResult{
+		Failures: []PipelineFailure{
+			{Activity: "GoTest", Details: []GoTestCLIOutput{{Test: "TestFail"}}},
+			{Activity: "GoBuild", Repo: "api", Details: []string{"main.go"}},
+			{Activity: "Abort", Details: "pipeline aborted by signal"},
+		},
+		Steps: []StepProgress{
+			{Name: "GitClone", State: StepDone, Duration: 2 * time.Second},
+			{Name: "GoTest", State: StepFailed, Duration: 12340 * time.Millisecond},
+			{Name: "GoBuild (api)", State: StepFailed, Duration: 5 * time.Second},
+			{Name: "GoFmt", State: StepDone, Duration: 300 * time.Millisecond},
+			{Name: "Deploy", State: StepSkipped},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteTable(&buf, result, false))
+	assert.Equal(t, `STEP           STATUS                       DURATION  FAILURES
+GitClone       done                         2s        0
+GoTest         failed                       12.34s    1
+GoBuild (api)  failed                       5s        1
+GoFmt          done                         300ms     0
+Deploy         skipped                      -         0
+(other)        failed                       -         1
+TOTAL          2 done, 2 failed, 1 skipped  19.64s    3
+`, buf.String())
+
+	t.Run("Color", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, WriteTable(&buf, result, true))
+		assert.Contains(t, buf.String(), "\x1b[32mdone\x1b[0m")
+		assert.Contains(t, buf.String(), "\x1b[31mfailed\x1b[0m")
+
+		// Without the colors, the duration column still starts at the same offset on every line.
+		lines := strings.Split(regexp.MustCompile("\x1b\\[[0-9]+m").ReplaceAllString(buf.String(), ""), "\n")
+		column := strings.Index(lines[0], "DURATION")
+		for _, line := range lines[1 : len(lines)-1] {
+			assert.Equal(t, " ", line[column-1:column], line)
+			assert.NotEqual(t, " ", line[column:column+1], line)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// WriteTAP renders a GoTestResult as a TAP version 13 report, one line per test.
+// Failed tests and failed packages carry a YAML diagnostic block.
+func WriteTAP(w io.Writer, result *GoTestResult) error {
+	type tapLine struct {
+		ok     bool
+		output GoTestCLIOutput
+	}
+
+	lines := make([]tapLine, 0, len(result.PassedTests)+len(result.FailedTests)+len(result.FailedPackages))
+	for _, test := range result.PassedTests {
+		lines = append(lines, tapLine{ok: true, output: test})
+	}
+	for _, test := range result.FailedTests {
+		lines = append(lines, tapLine{ok: false, output: test})
+	}
+	for _, pkg := range result.FailedPackages {
+		lines = append(lines, tapLine{ok: false, output: pkg})
+	}
+	// Sort so the report is stable regardless of the order `go test` emitted events in.
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].output.Package != lines[j].output.Package {
+			return lines[i].output.Package < lines[j].output.Package
+		}
+		return lines[i].output.Test < lines[j].output.Test
+	})
+
+	if _, err := fmt.Fprintf(w, "TAP version 13\n1..%d\n", len(lines)); err != nil {
+		return fmt.Errorf("writing TAP header: %w", err)
+	}
+	for i, line := range lines {
+		name := line.output.Package
+		if line.output.Test != "" {
+			name = fmt.Sprintf("%s/%s", line.output.Package, line.output.Test)
+		}
+
+		status := "ok"
+		if !line.ok {
+			status = "not ok"
+		}
+		if _, err := fmt.Fprintf(w, "%s %d - %s\n", status, i+1, name); err != nil {
+			return fmt.Errorf("writing TAP test line: %w", err)
+		}
+		if line.ok {
+			continue
+		}
+
+		message := "test failed"
+		if line.output.Test == "" {
+			message = "package failed"
+		}
+		if _, err := fmt.Fprintf(w, "  ---\n  message: %q\n  package: %q\n  elapsed: %g\n  ...\n", message, line.output.Package, line.output.Elapsed); err != nil {
+			return fmt.Errorf("writing TAP diagnostics: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package pipeline
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTAP(t *testing.T) {
+	result := &GoTestResult{
+		PassedTests: []GoTestCLIOutput{
+			{Action: "pass", Package: "example.com/app", Test: "TestPass", Elapsed: 0.01},
+			{Action: "pass", Package: "example.com/app/util", Test: "TestHelper", Elapsed: 0},
+		},
+		FailedTests: []GoTestCLIOutput{
+			{Action: "fail", Package: "example.com/app", Test: "TestFail", Elapsed: 0.25},
+		},
+		FailedPackages: []GoTestCLIOutput{
+			{Action: "fail", Package: "example.com/broken", Elapsed: 0},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteTAP(&buf, result))
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "tap", "mixed.golden"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(golden), buf.String())
+}
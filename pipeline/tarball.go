@@ -0,0 +1,217 @@
+package pipeline
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// MaxTarballSize caps the bytes of the files extracted from a tarball, so that a tarball bomb
+// can't fill the worker's disk.
+var MaxTarballSize int64 = 4 << 30
+
+// FetchTarball params and results
+type FetchTarballParams struct {
+	Metadata PipelineActivityMetadata
+	URL      string
+	// StripComponents drops this many leading directories from the paths in the tarball, like
+	// `tar --strip-components`, e.g. 1 for tarballs that wrap the sources in a versioned directory.
+	StripComponents int
+	// Dir, when set, is a directory of the extracted sources that must exist, as for GitClone.
+	Dir string
+	// WorkdirRoot overrides the worker's WorkdirRoot when creating the workdir, as for GitClone.
+	WorkdirRoot string
+}
+
+type FetchTarballResult struct {
+	Metadata PipelineActivityMetadata
+}
+
+// FetchTarball downloads a .tar.gz (or uncompressed .tar) and extracts it into a new workdir,
+// in place of GitClone for sources that aren't published as a git repository.
+func (pa *PipelineActivity) FetchTarball(ctx context.Context, params FetchTarballParams) (_ *FetchTarballResult, err error) {
+	logger := activity.GetLogger(ctx)
+	result := &FetchTarballResult{
+		Metadata: params.Metadata,
+	}
+
+	if params.Metadata.Workdir == "" {
+		var workdir string
+		workdir, err = pa.createWorkdir(ctx, params.WorkdirRoot)
+		if err != nil {
+			return nil, err
+		}
+		result.Metadata.Workdir = workdir
+		activityLogger(ctx).Info("No workdir specified, creating one", "workdir", result.Metadata.Workdir)
+		defer removeWorkdirOnError(ctx, workdir, &err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params.URL, nil)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("creating tarball request: %s", err), "InvalidTarballURL", err)
+	}
+	activityLogger(ctx).Info("Fetching tarball", "url", params.URL, "workdir", result.Metadata.Workdir)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching tarball: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, fmt.Errorf("fetching tarball: unexpected status %s", resp.Status)
+	case resp.StatusCode >= 300:
+		return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("fetching tarball: unexpected status %s", resp.Status), "TarballNotFound", nil)
+	}
+
+	// A retried attempt extracts into the same workdir, so start from an empty one.
+	if err := clearDir(result.Metadata.Workdir); err != nil {
+		return nil, fmt.Errorf("clearing workdir: %w", err)
+	}
+	files, err := extractTarball(activityLogger(ctx), resp.Body, result.Metadata.Workdir, params.StripComponents)
+	if err != nil {
+		return nil, fmt.Errorf("extracting tarball: %w", err)
+	}
+
+	logger.Info("Tarball extracted", "files", files)
+	if err := checkDir(result.Metadata.Workdir, params.Dir); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// extractTarball extracts the tar stream r, gzip-compressed or not, into dir and returns the
+// number of files written. Entries that would land outside dir, including through a symlink
+// extracted before them, are rejected, as are tarballs larger than MaxTarballSize.
+func extractTarball(logger *slog.Logger, r io.Reader, dir string, stripComponents int) (int, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return 0, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	files := 0
+	remaining := MaxTarballSize
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+
+		parts := strings.Split(strings.Trim(path.Clean(hdr.Name), "/"), "/")
+		if len(parts) <= stripComponents {
+			continue
+		}
+		name := filepath.FromSlash(path.Join(parts[stripComponents:]...))
+		if !filepath.IsLocal(name) {
+			return files, fmt.Errorf("entry %q is outside the workdir", hdr.Name)
+		}
+		target := filepath.Join(dir, name)
+		// The symlink check below is lexical, so chained links, e.g. x/b -> .. and a -> x/b/..,
+		// can still lead out of dir: nothing is written through a symlink instead.
+		if err := checkNoSymlinks(dir, name); err != nil {
+			return files, fmt.Errorf("entry %q: %w", hdr.Name, err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return files, err
+			}
+		case tar.TypeReg:
+			if remaining -= hdr.Size; remaining < 0 {
+				return files, fmt.Errorf("tarball is larger than %d bytes", MaxTarballSize)
+			}
+			if err := writeTarFile(tr, target, hdr.FileInfo().Mode().Perm()); err != nil {
+				return files, err
+			}
+			files++
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) || !filepath.IsLocal(filepath.Join(filepath.Dir(name), hdr.Linkname)) {
+				return files, fmt.Errorf("symlink %q points outside the workdir", hdr.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return files, err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return files, err
+			}
+		default:
+			// Hard links, devices and the like have no place in a source tarball.
+			logger.Warn("Skipping unsupported tarball entry", "name", hdr.Name, "type", hdr.Typeflag)
+		}
+	}
+}
+
+// checkNoSymlinks returns an error if name, or any of its parent directories, is an existing
+// symlink within dir.
+func checkNoSymlinks(dir, name string) error {
+	current := dir
+	for _, part := range strings.Split(name, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			rel, _ := filepath.Rel(dir, current)
+			return fmt.Errorf("path goes through symlink %q", rel)
+		}
+	}
+	return nil
+}
+
+func writeTarFile(r io.Reader, target string, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// clearDir removes the contents of dir, keeping dir itself.
+func clearDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
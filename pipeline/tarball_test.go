@@ -0,0 +1,166 @@
+package pipeline
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// testTarball returns a gzipped tarball of files, keyed by path.
+func testTarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for name, content := range files {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestFetchTarballActivity(t *testing.T) {
+	tarballs := map[string][]byte{
+		"/escape.tar.gz": testTarball(t, map[string]string{
+			"go.mod":       "module example.com/sample\n",
+			"../escape.go": "package main\n",
+		}),
+		"/go-sample.tar.gz": testTarball(t, map[string]string{
+			"go-sample-1.0/go.mod":      "module example.com/sample\n",
+			"go-sample-1.0/cmd/main.go": "package main\n",
+		}),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tarball, ok := tarballs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(tarball)
+	}))
+	defer server.Close()
+
+	newEnv := func() *testsuite.TestActivityEnvironment {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestActivityEnvironment()
+		env.RegisterActivity(pa.FetchTarball)
+		return env
+	}
+
+	t.Run("Strip components", func(t *testing.T) {
+		env := newEnv()
+		val, err := env.ExecuteActivity(pa.FetchTarball, FetchTarballParams{URL: server.URL + "/go-sample.tar.gz", StripComponents: 1, WorkdirRoot: t.TempDir()})
+		assert.NoError(t, err)
+
+		var result FetchTarballResult
+		assert.NoError(t, val.Get(&result))
+		content, err := os.ReadFile(filepath.Join(result.Metadata.Workdir, "cmd", "main.go"))
+		assert.NoError(t, err)
+		assert.Equal(t, "package main\n", string(content))
+		assert.FileExists(t, filepath.Join(result.Metadata.Workdir, "go.mod"))
+	})
+
+	t.Run("Entry outside the workdir", func(t *testing.T) {
+		env := newEnv()
+		_, err := env.ExecuteActivity(pa.FetchTarball, FetchTarballParams{URL: server.URL + "/escape.tar.gz", WorkdirRoot: t.TempDir()})
+		assert.ErrorContains(t, err, "outside the workdir")
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		env := newEnv()
+		_, err := env.ExecuteActivity(pa.FetchTarball, FetchTarballParams{URL: server.URL + "/missing.tar.gz", WorkdirRoot: t.TempDir()})
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.True(t, appErr.NonRetryable())
+			assert.Equal(t, "TarballNotFound", appErr.Type())
+		}
+	})
+}
+
+func TestExtractTarball(t *testing.T) {
+	// extract writes entries as a tarball and extracts it into a new dir within a parent dir.
+	extract := func(t *testing.T, entries ...*tar.Header) (string, error) {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range entries {
+			assert.NoError(t, tw.WriteHeader(hdr))
+			_, err := tw.Write(make([]byte, hdr.Size))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, tw.Close())
+		parent := t.TempDir()
+		dir := filepath.Join(parent, "workdir")
+		assert.NoError(t, os.Mkdir(dir, 0o755))
+		_, err := extractTarball(slog.Default(), &buf, dir, 0)
+		return parent, err
+	}
+
+	t.Run("Chained symlinks", func(t *testing.T) {
+		parent, err := extract(t,
+			&tar.Header{Name: "x/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			&tar.Header{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "x/b/.."},
+			&tar.Header{Name: "a/evil", Typeflag: tar.TypeReg, Mode: 0o644, Size: 4},
+		)
+		assert.ErrorContains(t, err, `path goes through symlink "a"`)
+		assert.NoFileExists(t, filepath.Join(parent, "evil"))
+	})
+
+	t.Run("Overwriting a symlink", func(t *testing.T) {
+		_, err := extract(t,
+			&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "target"},
+			&tar.Header{Name: "link", Typeflag: tar.TypeReg, Mode: 0o644, Size: 4},
+		)
+		assert.ErrorContains(t, err, `path goes through symlink "link"`)
+	})
+
+	t.Run("Too large", func(t *testing.T) {
+		defer func(size int64) { MaxTarballSize = size }(MaxTarballSize)
+		MaxTarballSize = 1000
+		_, err := extract(t,
+			&tar.Header{Name: "a", Typeflag: tar.TypeReg, Mode: 0o644, Size: 600},
+			&tar.Header{Name: "b", Typeflag: tar.TypeReg, Mode: 0o644, Size: 600},
+		)
+		assert.ErrorContains(t, err, "tarball is larger than 1000 bytes")
+	})
+}
+
+func TestTarballURL(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		params := PipelineParams{TarballURL: "https://example.com/go-sample.tar.gz"}
+		assert.NoError(t, params.Validate())
+
+		params.GitURL = gitUrl
+		assert.ErrorContains(t, params.Validate(), "only one of GitURL, Repos and TarballURL")
+
+		params = PipelineParams{TarballURL: "https://example.com/go-sample.tar.gz", Ref: "main"}
+		assert.ErrorContains(t, params.Validate(), "cannot be used with TarballURL")
+	})
+
+	env := newTestWorkflowEnvironment()
+	mockAllActivitiesSuccess(env)
+	env.OnActivity(pa.FetchTarball, mock.Anything, FetchTarballParams{URL: "https://example.com/go-sample.tar.gz", StripComponents: 1}).
+		Return(&FetchTarballResult{Metadata: PipelineActivityMetadata{Workdir: "/tmp/test"}}, nil)
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{TarballURL: "https://example.com/go-sample.tar.gz", TarballStripComponents: 1})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	var result PipelineResult
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Empty(t, result.Failures)
+	assert.Equal(t, "FetchTarball", result.Steps[0].Name)
+	env.AssertNotCalled(t, "GitClone", mock.Anything, mock.Anything)
+}
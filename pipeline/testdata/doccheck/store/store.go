@@ -0,0 +1,32 @@
+// Package store is a key-value store.
+package store
+
+// Store holds values by key.
+type Store struct {
+	values map[string]string
+}
+
+func New() *Store {
+	return &Store{values: map[string]string{}}
+}
+
+// Get returns the value of key.
+func (s *Store) Get(key string) string {
+	return s.values[key]
+}
+
+func (s *Store) Set(key, value string) {
+	s.values[key] = value
+}
+
+// Errors of the store.
+const (
+	ErrNotFound = "not found"
+	ErrClosed   = "closed"
+)
+
+var DefaultSize = 16
+
+type entry struct{}
+
+func (entry) Exported() {}
@@ -0,0 +1,24 @@
+package flaky
+
+import (
+	"os"
+	"testing"
+)
+
+// TestFlaky fails the first time it runs, as recorded in the file named by FLAKY_STATE.
+func TestFlaky(t *testing.T) {
+	t.Run("case", func(t *testing.T) {
+		if _, err := os.Stat(os.Getenv("FLAKY_STATE")); err != nil {
+			if err := os.WriteFile(os.Getenv("FLAKY_STATE"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			t.Fatal("flaked")
+		}
+	})
+}
+
+func TestBroken(t *testing.T) {
+	t.Fatal("always fails")
+}
+
+func TestStable(t *testing.T) {}
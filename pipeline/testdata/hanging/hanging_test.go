@@ -0,0 +1,10 @@
+package hanging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHang(t *testing.T) {
+	time.Sleep(time.Hour)
+}
@@ -0,0 +1,3 @@
+module example.com/uncompilable
+
+go 1.22
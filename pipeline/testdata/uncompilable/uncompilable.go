@@ -0,0 +1,5 @@
+package uncompilable
+
+func Answer() int {
+	return 42
+}
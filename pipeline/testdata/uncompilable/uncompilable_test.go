@@ -0,0 +1,10 @@
+package uncompilable
+
+import "testing"
+
+func TestAnswer(t *testing.T) {
+	var s string = Answer()
+	if s != "42" {
+		t.Fail()
+	}
+}
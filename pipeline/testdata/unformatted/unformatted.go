@@ -0,0 +1,5 @@
+package unformatted
+
+func Answer()  int {
+return 42
+}
@@ -0,0 +1,3 @@
+module unparsable
+
+go 1.22
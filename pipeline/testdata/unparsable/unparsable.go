@@ -0,0 +1,4 @@
+package unparsable
+
+func Broken( {
+}
@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// git runs a git command in dir as a test user and returns its output.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runGit(context.Background(), dir, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	assert.NoError(t, err)
+	return out
+}
+
+// gitRepo returns a new repository with files committed on its main branch. Without files, the
+// commit is empty.
+func gitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	git(t, dir, "init", "-b", "main")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "--allow-empty", "-m", "Initial commit")
+	return dir
+}
+
+// writeScript writes a shell script to path.
+func writeScript(t *testing.T, path, script string) {
+	t.Helper()
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755))
+}
+
+// fakeTool puts a name on PATH running script for the rest of the test, and returns the
+// directory it is in.
+func fakeTool(t *testing.T, name, script string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeScript(t, filepath.Join(dir, name), script)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
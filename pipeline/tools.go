@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// toolsBinDir is the workdir-local directory GoInstallTools installs into. Go package
+// patterns such as ./... skip directories starting with a dot.
+const toolsBinDir = ".pipeline-bin"
+
+// ToolSpec is a Go tool installed with `go install <Package>@<Version>`.
+type ToolSpec struct {
+	Package string `json:"package" yaml:"package"`
+	Version string `json:"version" yaml:"version"`
+}
+
+// installTimeout is the StartToClose timeout of GoInstallTools. `go install` downloads and
+// compiles each tool with its dependencies, which takes minutes for golangci-lint from a cold
+// module cache.
+const installTimeout = 20 * time.Minute
+
+// GoInstallTools params and results
+type GoInstallToolsParams struct {
+	Metadata PipelineActivityMetadata
+	Tools    []ToolSpec
+}
+
+type GoInstallToolsResult struct {
+	Metadata PipelineActivityMetadata
+	// Installed and Skipped list the tools by name. A tool is skipped when the right version
+	// is already on PATH.
+	Installed []string
+	Skipped   []string
+}
+
+// GoInstallTools installs pinned tools into a bin directory in the workdir and records it in
+// the metadata, so later activities run them instead of whatever is on the worker's PATH.
+func (pa *PipelineActivity) GoInstallTools(ctx context.Context, params GoInstallToolsParams) (*GoInstallToolsResult, error) {
+	defer heartbeat(ctx, heartbeatInterval)()
+	logger := activity.GetLogger(ctx)
+	result := &GoInstallToolsResult{
+		Metadata:  params.Metadata,
+		Installed: []string{},
+		Skipped:   []string{},
+	}
+	result.Metadata.BinDir = filepath.Join(params.Metadata.Workdir, toolsBinDir)
+
+	for _, tool := range params.Tools {
+		name := toolName(tool.Package)
+		if installed, err := exec.LookPath(lookTool(result.Metadata, name)); err == nil && toolHasVersion(ctx, installed, tool) {
+			logger.Info("Tool already installed", "tool", name, "path", installed)
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+
+		env := append(goEnv(params.Metadata), "GOBIN="+result.Metadata.BinDir)
+		_, stderr, err := runCommand(ctx, params.Metadata.Workdir, commandOptions{Env: env}, "go", "install", tool.Package+"@"+tool.Version)
+		if err != nil {
+			return nil, fmt.Errorf("running go install command for %s: %w: %s", tool.Package, err, strings.TrimSpace(stderr))
+		}
+		result.Installed = append(result.Installed, name)
+	}
+
+	logger.Info("Go tools ready", "installed", result.Installed, "skipped", result.Skipped)
+	return result, nil
+}
+
+var majorVersionSuffix = regexp.MustCompile(`^v\d+$`)
+
+// toolName is the binary name `go install` gives pkg: its last path element, or the one
+// before a major version suffix such as /v2.
+func toolName(pkg string) string {
+	elems := strings.Split(pkg, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && majorVersionSuffix.MatchString(name) {
+		name = elems[len(elems)-2]
+	}
+	return name
+}
+
+// lookTool returns the path of the named tool in metadata.BinDir, or name itself to resolve
+// it from PATH when it wasn't installed there.
+func lookTool(metadata PipelineActivityMetadata, name string) string {
+	if metadata.BinDir == "" {
+		return name
+	}
+	path := filepath.Join(metadata.BinDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return name
+	}
+	return path
+}
+
+// toolHasVersion reports whether the binary at path was built from tool's package and version,
+// according to the build info `go version -m` prints.
+func toolHasVersion(ctx context.Context, path string, tool ToolSpec) bool {
+	out, err := exec.CommandContext(ctx, "go", "version", "-m", path).Output()
+	if err != nil {
+		return false
+	}
+	var pkg, version string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 2 && fields[0] == "path":
+			pkg = fields[1]
+		case len(fields) >= 3 && fields[0] == "mod":
+			version = fields[2]
+		}
+	}
+	return pkg == tool.Package && version == tool.Version
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+)
+
+func TestToolName(t *testing.T) {
+	tests := map[string]string{
+		"golang.org/x/vuln/cmd/govulncheck":                      "govulncheck",
+		"github.com/golangci/golangci-lint/cmd/golangci-lint":    "golangci-lint",
+		"github.com/jstemmer/go-junit-report/v2":                 "go-junit-report",
+		"honnef.co/go/tools/cmd/staticcheck":                     "staticcheck",
+		"github.com/golangci/golangci-lint/v2/cmd/golangci-lint": "golangci-lint",
+	}
+	for pkg, want := range tests {
+		assert.Equal(t, want, toolName(pkg), pkg)
+	}
+}
+
+func TestLookTool(t *testing.T) {
+	binDir := t.TempDir()
+	writeScript(t, filepath.Join(binDir, "golangci-lint"), "")
+	metadata := PipelineActivityMetadata{BinDir: binDir}
+
+	assert.Equal(t, filepath.Join(binDir, "golangci-lint"), lookTool(metadata, "golangci-lint"))
+	assert.Equal(t, "govulncheck", lookTool(metadata, "govulncheck"), "tools not installed fall back to PATH")
+	assert.Equal(t, "golangci-lint", lookTool(PipelineActivityMetadata{}, "golangci-lint"))
+}
+
+func TestGoInstallToolsTimeout(t *testing.T) {
+	env := newTestWorkflowEnvironment()
+	env.OnActivity(pa.GoInstallTools, mock.Anything, mock.Anything).Return(&GoInstallToolsResult{}, nil)
+	mockAllActivitiesSuccess(env)
+	var startToClose, heartbeatAfter time.Duration
+	env.SetOnActivityStartedListener(func(info *activity.Info, _ context.Context, _ converter.EncodedValues) {
+		if info.ActivityType.Name == "GoInstallTools" {
+			startToClose, heartbeatAfter = info.Deadline.Sub(info.StartedTime), info.HeartbeatTimeout
+		}
+	})
+
+	env.ExecuteWorkflow(PipelineWorkflow, PipelineParams{
+		GitURL: gitUrl,
+		Tools:  []ToolSpec{{Package: "github.com/golangci/golangci-lint/v2/cmd/golangci-lint", Version: "v2.1.6"}},
+	})
+
+	assert.NoError(t, env.GetWorkflowError())
+	assert.Equal(t, installTimeout, startToClose)
+	assert.Equal(t, heartbeatTimeout, heartbeatAfter)
+}
@@ -2,23 +2,57 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/url"
 	"os"
 	"os/signal"
+	"path"
+	"sort"
+	"strings"
+	"time"
 
 	"temporal-workflow/pipeline"
 
 	"github.com/gosimple/slug"
 	"github.com/kelseyhightower/envconfig"
 	tclient "go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
 	"gopkg.in/yaml.v3"
 )
 
 type WorkflowOptions struct {
+	// Input is the PipelineParams YAML: a file path, "-" for stdin, or an http(s):// URL.
 	Input string `required:"true"`
+	// InputFormat is the format of the input: "yaml" or "json". When empty, it is detected from
+	// the extension of Input, defaulting to YAML, e.g. for stdin. The --input-format flag
+	// overrides it.
+	InputFormat string
+	// TAPOutput is a path to write a TAP report of the test results to, or "-" for stdout.
+	TAPOutput string
+	// Cron is the cron spec the schedule command triggers the pipeline on, e.g. "0 2 * * *".
+	Cron string
+	// Format prints the result to stdout once the pipeline finishes: "text" for a table of the
+	// steps, or "json". The --format flag overrides it.
+	Format string
 }
 
+// Result formats of the pipeline command.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Input formats of the pipeline input.
+const (
+	InputFormatYAML = "yaml"
+	InputFormatJSON = "json"
+)
+
 func RunPipeline(pctx context.Context) error {
 	ctx, cancel := signal.NotifyContext(pctx, os.Interrupt, os.Kill)
 	defer cancel()
@@ -28,39 +62,247 @@ func RunPipeline(pctx context.Context) error {
 		return fmt.Errorf("failed to process environment variables: %w", err)
 	}
 
+	flags := flag.NewFlagSet("pipeline", flag.ContinueOnError)
+	flags.StringVar(&opts.Format, "format", opts.Format, "print the result as a table (text) or as json")
+	flags.StringVar(&opts.InputFormat, "input-format", opts.InputFormat, "format of the input, yaml or json, instead of detecting it from its extension")
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		return err
+	}
+	if opts.Format != "" && opts.Format != FormatText && opts.Format != FormatJSON {
+		return fmt.Errorf("unknown format %q, want %s or %s", opts.Format, FormatText, FormatJSON)
+	}
+
 	var tOpts TemporalOptions
 	if err := envconfig.Process("temporal", &tOpts); err != nil {
 		return fmt.Errorf("failed to process Temporal environment variables: %w", err)
 	}
 
-	tc, err := NewTemporalClient(ctx, tOpts)
+	params, err := readPipelineParams(ctx, opts.Input, opts.InputFormat)
 	if err != nil {
-		return fmt.Errorf("failed to connect to Temporal server %q: %w", tOpts.HostPort, err)
+		return err
 	}
-	defer tc.Close()
 
-	params := pipeline.PipelineParams{}
-	f, err := os.ReadFile(opts.Input)
+	// The input file may route this pipeline to a different queue or namespace.
+	tOpts, err = resolveTemporalOptions(tOpts, params)
 	if err != nil {
-		return fmt.Errorf("failed to read input file %q: %w", opts.Input, err)
+		return fmt.Errorf("invalid Temporal options for input file %q: %w", opts.Input, err)
+	}
+
+	var mOpts StatsDOptions
+	if err := envconfig.Process("statsd", &mOpts); err != nil {
+		return fmt.Errorf("failed to process StatsD environment variables: %w", err)
 	}
-	if err := yaml.Unmarshal(f, &params); err != nil {
-		return fmt.Errorf("failed to unmarshal input file %q: %w", opts.Input, err)
+	metrics, err := newStatsDClient(mOpts)
+	if err != nil {
+		return err
 	}
-	if err := params.Validate(); err != nil {
-		return fmt.Errorf("invalid input file %q: %w", opts.Input, err)
+	defer metrics.Close()
+
+	tc, closeClient, err := NewTemporalClient(ctx, tOpts)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Temporal server %q: %w", tOpts.HostPort, err)
 	}
+	defer closeClient()
 
-	fWorkflow, err := tc.ExecuteWorkflow(ctx, tclient.StartWorkflowOptions{
-		ID:        fmt.Sprintf("PipelineWorkflow-%s", slug.Make(params.GitURL)),
-		TaskQueue: tOpts.Queue,
-	}, "PipelineWorkflow", params)
+	start := time.Now()
+	fWorkflow, err := ExecutePipeline(ctx, tc, tOpts.Queue, params)
 	if err != nil {
-		return fmt.Errorf("failed to execute workflow: %w", err)
+		return err
 	}
 	slog.Info("Started PipelineWorkflow", "WorkflowID", fWorkflow.GetID(), "RunID", fWorkflow.GetRunID())
-	if err := fWorkflow.Get(ctx, nil); err != nil {
+	var result pipeline.PipelineResult
+	err = getPipelineResult(ctx, fWorkflow, &result)
+	metrics.recordPipeline(params, &result, err, time.Since(start))
+	if err != nil {
 		return fmt.Errorf("failed to get workflow result: %w", err)
 	}
+
+	// Print the output of the checks when something failed, so it doesn't have to be dug out
+	// of the worker's logs.
+	if len(result.Failures) > 0 {
+		writeLogs(os.Stderr, result.Logs)
+	}
+
+	if err := writeResult(os.Stdout, opts.Format, &result); err != nil {
+		return fmt.Errorf("failed to print the result: %w", err)
+	}
+
+	if opts.TAPOutput != "" && result.Tests != nil {
+		if err := writeTAPReport(opts.TAPOutput, result.Tests); err != nil {
+			return fmt.Errorf("failed to write TAP report: %w", err)
+		}
+	}
 	return nil
 }
+
+// getPipelineResult waits for the result of the pipeline run. With FailWorkflowOnChecks, a
+// pipeline with failures fails the workflow but still has a result.
+func getPipelineResult(ctx context.Context, run tclient.WorkflowRun, result *pipeline.PipelineResult) error {
+	err := run.Get(ctx, result)
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() == pipeline.PipelineFailedErrorType && appErr.Details(result) == nil {
+		return nil
+	}
+	return err
+}
+
+// writeLogs writes the captured output of each check, in step name order.
+func writeLogs(w io.Writer, logs map[string]string) {
+	names := make([]string, 0, len(logs))
+	for name := range logs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "==> %s <==\n%s\n", name, strings.TrimRight(logs[name], "\n"))
+	}
+}
+
+// writeResult prints result in format, if any. Tables are colored on a terminal.
+func writeResult(f *os.File, format string, result *pipeline.PipelineResult) error {
+	switch format {
+	case FormatText:
+		return pipeline.WriteTable(f, result, isTerminal(f))
+	case FormatJSON:
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	return nil
+}
+
+// isTerminal reports whether f is a character device, such as a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// readPipelineParams reads and validates the PipelineParams input, see openInput, in format, or
+// the format detected by inputFormat when empty. Unknown fields are rejected, so a misspelled
+// key is reported rather than silently ignored.
+func readPipelineParams(ctx context.Context, input, format string) (pipeline.PipelineParams, error) {
+	params := pipeline.PipelineParams{}
+	if format == "" {
+		format = inputFormat(input)
+	}
+	var decode func(r io.Reader) error
+	switch format {
+	case InputFormatYAML:
+		decode = func(r io.Reader) error {
+			dec := yaml.NewDecoder(r)
+			dec.KnownFields(true)
+			return dec.Decode(&params)
+		}
+	case InputFormatJSON:
+		decode = func(r io.Reader) error {
+			dec := json.NewDecoder(r)
+			dec.DisallowUnknownFields()
+			return dec.Decode(&params)
+		}
+	default:
+		return params, fmt.Errorf("unknown input format %q, want %s or %s", format, InputFormatYAML, InputFormatJSON)
+	}
+
+	f, err := openInput(ctx, input)
+	if err != nil {
+		return params, err
+	}
+	defer f.Close()
+
+	// An empty file decodes to io.EOF; leave it to Validate to report the missing fields.
+	if err := decode(f); err != nil && !errors.Is(err, io.EOF) {
+		return params, fmt.Errorf("failed to unmarshal input %q: %w", input, err)
+	}
+	if err := params.Validate(); err != nil {
+		return params, fmt.Errorf("invalid input %q: %w", input, err)
+	}
+	return params, nil
+}
+
+// inputFormat detects the format of input from its extension, of a file path or URL: JSON for
+// .json, YAML otherwise.
+func inputFormat(input string) string {
+	if u, err := url.Parse(input); err == nil && u.Scheme != "" {
+		input = u.Path
+	}
+	if strings.EqualFold(path.Ext(input), ".json") {
+		return InputFormatJSON
+	}
+	return InputFormatYAML
+}
+
+// resolveTemporalOptions overrides the environment-provided task queue and namespace
+// with the ones from params, when set.
+func resolveTemporalOptions(tOpts TemporalOptions, params pipeline.PipelineParams) (TemporalOptions, error) {
+	if params.TaskQueue != "" {
+		tOpts.Queue = params.TaskQueue
+	}
+	if params.Namespace != "" {
+		tOpts.Namespace = params.Namespace
+	}
+
+	if tOpts.Queue == "" {
+		return tOpts, fmt.Errorf("task queue is required")
+	}
+	if tOpts.Namespace == "" {
+		return tOpts, fmt.Errorf("namespace is required")
+	}
+	return tOpts, nil
+}
+
+func writeTAPReport(path string, result *pipeline.GoTestResult) error {
+	if path == "-" {
+		return pipeline.WriteTAP(os.Stdout, result)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating %q: %w", path, err)
+	}
+	if err := pipeline.WriteTAP(f, result); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ExecutePipeline starts a PipelineWorkflow for params on the given task queue. With
+// params.AutoCancelSuperseded, running pipelines of the same repository and branch are cancelled first.
+// With params.ConcurrencyGroup, running pipelines of the group are cancelled or, by default, waited
+// for, which blocks the caller until they finish.
+func ExecutePipeline(ctx context.Context, tc tclient.Client, queue string, params pipeline.PipelineParams) (tclient.WorkflowRun, error) {
+	options := tclient.StartWorkflowOptions{
+		ID:        pipelineWorkflowID(params),
+		TaskQueue: queue,
+	}
+	var attributes []temporal.SearchAttributeUpdate
+	if params.AutoCancelSuperseded {
+		if err := cancelSupersededPipelines(ctx, tc, params); err != nil {
+			return nil, err
+		}
+		attributes = append(attributes,
+			PipelineRepoSearchAttribute.ValueSet(params.RepoSpecs()[0].URL),
+			PipelineBranchSearchAttribute.ValueSet(pipelineBranch(params)),
+		)
+	}
+	if params.ConcurrencyGroup != "" {
+		if err := enterConcurrencyGroup(ctx, tc, params); err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, PipelineConcurrencyGroupSearchAttribute.ValueSet(params.ConcurrencyGroup))
+	}
+	if len(attributes) > 0 {
+		options.TypedSearchAttributes = temporal.NewSearchAttributes(attributes...)
+	}
+
+	fWorkflow, err := tc.ExecuteWorkflow(ctx, options, "PipelineWorkflow", params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute workflow: %w", err)
+	}
+	return fWorkflow, nil
+}
+
+// pipelineWorkflowID is the workflow ID of the pipeline of params' repository.
+func pipelineWorkflowID(params pipeline.PipelineParams) string {
+	return fmt.Sprintf("PipelineWorkflow-%s", slug.Make(params.RepoSpecs()[0].URL))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"temporal-workflow/pipeline"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveTemporalOptions(t *testing.T) {
+	env := TemporalOptions{HostPort: "localhost:6433", Namespace: "default", Queue: "default"}
+
+	t.Run("Config overrides env", func(t *testing.T) {
+		tOpts, err := resolveTemporalOptions(env, pipeline.PipelineParams{TaskQueue: "builds", Namespace: "ci"})
+		assert.NoError(t, err)
+		assert.Equal(t, "builds", tOpts.Queue)
+		assert.Equal(t, "ci", tOpts.Namespace)
+		assert.Equal(t, env.HostPort, tOpts.HostPort)
+	})
+
+	t.Run("Falls back to env", func(t *testing.T) {
+		tOpts, err := resolveTemporalOptions(env, pipeline.PipelineParams{})
+		assert.NoError(t, err)
+		assert.Equal(t, env, tOpts)
+	})
+
+	t.Run("Errors when unset everywhere", func(t *testing.T) {
+		_, err := resolveTemporalOptions(TemporalOptions{}, pipeline.PipelineParams{})
+		assert.Error(t, err)
+	})
+}
+
+func TestReadPipelineParams(t *testing.T) {
+	write := func(t *testing.T, name, content string) string {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		params, err := readPipelineParams(context.Background(), write(t, "input.yaml", "git_url: https://github.com/afanwang/go-sample.git\nref: main\n"), "")
+		assert.NoError(t, err)
+		assert.Equal(t, "main", params.Ref)
+	})
+
+	t.Run("Unknown field", func(t *testing.T) {
+		_, err := readPipelineParams(context.Background(), write(t, "input.yaml", "git_ur: https://github.com/afanwang/go-sample.git\n"), "")
+		assert.ErrorContains(t, err, "field git_ur not found")
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		_, err := readPipelineParams(context.Background(), write(t, "input.yaml", ""), "")
+		assert.ErrorContains(t, err, "GitURL is required")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		params, err := readPipelineParams(context.Background(), write(t, "input.json", `{"git_url": "https://github.com/afanwang/go-sample.git", "ref": "main", "test_flags": ["-short"]}`), "")
+		assert.NoError(t, err)
+		assert.Equal(t, "main", params.Ref)
+		assert.Equal(t, []string{"-short"}, params.TestFlags)
+
+		_, err = readPipelineParams(context.Background(), write(t, "input.json", `{"git_ur": "https://github.com/afanwang/go-sample.git"}`), "")
+		assert.ErrorContains(t, err, `unknown field "git_ur"`)
+	})
+
+	t.Run("Format overrides extension", func(t *testing.T) {
+		params, err := readPipelineParams(context.Background(), write(t, "input", `{"git_url": "https://github.com/afanwang/go-sample.git", "ref": "main"}`), InputFormatJSON)
+		assert.NoError(t, err)
+		assert.Equal(t, "main", params.Ref)
+
+		_, err = readPipelineParams(context.Background(), write(t, "input.json", ""), "toml")
+		assert.ErrorContains(t, err, `unknown input format "toml"`)
+	})
+}
+
+func TestInputFormat(t *testing.T) {
+	assert.Equal(t, InputFormatJSON, inputFormat("pipelines/build.json"))
+	assert.Equal(t, InputFormatJSON, inputFormat("https://ci.example.com/pipelines/build.JSON?token=abc"))
+	assert.Equal(t, InputFormatYAML, inputFormat("_examples/simple.yaml"))
+	assert.Equal(t, InputFormatYAML, inputFormat("_examples/simple.yml"))
+	assert.Equal(t, InputFormatYAML, inputFormat("-"))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"temporal-workflow/pipeline"
+
+	"github.com/gosimple/slug"
+	"github.com/kelseyhightower/envconfig"
+	tclient "go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// RunSchedule manages a Temporal Schedule that runs the pipeline of WORKFLOW_INPUT on the
+// WORKFLOW_CRON spec. The subcommand is one of create (the default, which also updates an
+// existing schedule), pause, unpause, or delete.
+func RunSchedule(pctx context.Context) error {
+	ctx, cancel := signal.NotifyContext(pctx, os.Interrupt, os.Kill)
+	defer cancel()
+
+	subcommand := "create"
+	if len(os.Args) > 2 {
+		subcommand = os.Args[2]
+	}
+
+	var opts WorkflowOptions
+	if err := envconfig.Process("workflow", &opts); err != nil {
+		return fmt.Errorf("failed to process environment variables: %w", err)
+	}
+
+	var tOpts TemporalOptions
+	if err := envconfig.Process("temporal", &tOpts); err != nil {
+		return fmt.Errorf("failed to process Temporal environment variables: %w", err)
+	}
+
+	params, err := readPipelineParams(ctx, opts.Input, opts.InputFormat)
+	if err != nil {
+		return err
+	}
+
+	tOpts, err = resolveTemporalOptions(tOpts, params)
+	if err != nil {
+		return fmt.Errorf("invalid Temporal options for input file %q: %w", opts.Input, err)
+	}
+
+	tc, closeClient, err := NewTemporalClient(ctx, tOpts)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Temporal server %q: %w", tOpts.HostPort, err)
+	}
+	defer closeClient()
+
+	scheduleID := pipelineScheduleID(params)
+	handle := tc.ScheduleClient().GetHandle(ctx, scheduleID)
+	switch subcommand {
+	case "create":
+		if opts.Cron == "" {
+			return fmt.Errorf("WORKFLOW_CRON is required to create a schedule")
+		}
+		if err := upsertPipelineSchedule(ctx, tc, scheduleID, opts.Cron, tOpts.Queue, params); err != nil {
+			return err
+		}
+	case "pause":
+		if err := handle.Pause(ctx, tclient.SchedulePauseOptions{}); err != nil {
+			return fmt.Errorf("failed to pause schedule %q: %w", scheduleID, err)
+		}
+	case "unpause":
+		if err := handle.Unpause(ctx, tclient.ScheduleUnpauseOptions{}); err != nil {
+			return fmt.Errorf("failed to unpause schedule %q: %w", scheduleID, err)
+		}
+	case "delete":
+		if err := handle.Delete(ctx); err != nil {
+			return fmt.Errorf("failed to delete schedule %q: %w", scheduleID, err)
+		}
+	default:
+		return fmt.Errorf("unknown schedule subcommand %q, expected create, pause, unpause, or delete", subcommand)
+	}
+	slog.Info("Updated pipeline schedule", "ScheduleID", scheduleID, "subcommand", subcommand)
+	return nil
+}
+
+// upsertPipelineSchedule creates the schedule, or replaces the spec and action of the existing one.
+func upsertPipelineSchedule(ctx context.Context, tc tclient.Client, scheduleID, cron, queue string, params pipeline.PipelineParams) error {
+	spec := tclient.ScheduleSpec{CronExpressions: []string{cron}}
+	action := &tclient.ScheduleWorkflowAction{
+		ID:        pipelineWorkflowID(params),
+		Workflow:  "PipelineWorkflow",
+		Args:      []any{params},
+		TaskQueue: queue,
+	}
+
+	_, err := tc.ScheduleClient().Create(ctx, tclient.ScheduleOptions{
+		ID:     scheduleID,
+		Spec:   spec,
+		Action: action,
+	})
+	if !errors.Is(err, temporal.ErrScheduleAlreadyRunning) {
+		if err != nil {
+			return fmt.Errorf("failed to create schedule %q: %w", scheduleID, err)
+		}
+		return nil
+	}
+
+	err = tc.ScheduleClient().GetHandle(ctx, scheduleID).Update(ctx, tclient.ScheduleUpdateOptions{
+		DoUpdate: func(input tclient.ScheduleUpdateInput) (*tclient.ScheduleUpdate, error) {
+			schedule := input.Description.Schedule
+			schedule.Spec = &spec
+			schedule.Action = action
+			return &tclient.ScheduleUpdate{Schedule: &schedule}, nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update schedule %q: %w", scheduleID, err)
+	}
+	return nil
+}
+
+// pipelineScheduleID is the schedule ID of the pipeline of params' repository.
+func pipelineScheduleID(params pipeline.PipelineParams) string {
+	return fmt.Sprintf("PipelineSchedule-%s", slug.Make(params.RepoSpecs()[0].URL))
+}
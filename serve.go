@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"temporal-workflow/pipeline"
+
+	"github.com/kelseyhightower/envconfig"
+	tclient "go.temporal.io/sdk/client"
+)
+
+// maxWebhookBodySize bounds how much of a webhook request body is read.
+const maxWebhookBodySize = 1 << 20
+
+type ServeOptions struct {
+	Addr string `default:":8080"`
+	// Secret is the shared secret used to verify the X-Hub-Signature-256 header.
+	Secret string `required:"true"`
+}
+
+// webhookPayload is the subset of a GitHub push event needed to start a pipeline.
+type webhookPayload struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		CloneURL string `json:"clone_url"`
+	} `json:"repository"`
+}
+
+type webhookResponse struct {
+	WorkflowID string `json:"workflow_id"`
+	RunID      string `json:"run_id"`
+}
+
+func RunServe(pctx context.Context) error {
+	ctx, cancel := signal.NotifyContext(pctx, os.Interrupt, os.Kill)
+	defer cancel()
+
+	var sOpts ServeOptions
+	if err := envconfig.Process("serve", &sOpts); err != nil {
+		return fmt.Errorf("failed to process environment variables: %w", err)
+	}
+
+	var tOpts TemporalOptions
+	if err := envconfig.Process("temporal", &tOpts); err != nil {
+		return fmt.Errorf("failed to process Temporal environment variables: %w", err)
+	}
+
+	tc, closeClient, err := NewTemporalClient(ctx, tOpts)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Temporal server %q: %w", tOpts.HostPort, err)
+	}
+	defer closeClient()
+
+	srv := &http.Server{
+		Addr: sOpts.Addr,
+		Handler: &webhookHandler{
+			tc:     tc,
+			queue:  tOpts.Queue,
+			secret: []byte(sOpts.Secret),
+		},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer shutdownCancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to shut down webhook server", "error", err)
+		}
+	}()
+
+	slog.Info("Listening for webhooks", "addr", sOpts.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve webhooks: %w", err)
+	}
+	return nil
+}
+
+// webhookHandler starts a PipelineWorkflow for each signed push event it receives.
+type webhookHandler struct {
+	tc     tclient.Client
+	queue  string
+	secret []byte
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if !validSignature(h.secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		slog.Warn("Rejected webhook with invalid signature", "remote", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	params := pipeline.PipelineParams{
+		GitURL: payload.Repository.CloneURL,
+		Ref:    payload.Ref,
+	}
+	if err := params.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fWorkflow, err := ExecutePipeline(r.Context(), h.tc, h.queue, params)
+	if err != nil {
+		slog.Error("Failed to start PipelineWorkflow from webhook", "error", err)
+		http.Error(w, "failed to start pipeline", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Started PipelineWorkflow", "WorkflowID", fWorkflow.GetID(), "RunID", fWorkflow.GetRunID())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(webhookResponse{
+		WorkflowID: fWorkflow.GetID(),
+		RunID:      fWorkflow.GetRunID(),
+	})
+}
+
+// validSignature reports whether header is a valid "sha256=<hex>" HMAC of body using secret.
+func validSignature(secret, body []byte, header string) bool {
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
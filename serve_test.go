@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"temporal-workflow/pipeline"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/mocks"
+)
+
+// sign returns the X-Hub-Signature-256 header of body for secret.
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidSignature(t *testing.T) {
+	body := []byte(`{"ref": "refs/heads/main"}`)
+	secret := []byte("secret")
+
+	assert.True(t, validSignature(secret, body, sign("secret", string(body))))
+	assert.False(t, validSignature(secret, body, sign("other", string(body))), "signed with another secret")
+	assert.False(t, validSignature(secret, []byte(`{"ref": "refs/heads/evil"}`), sign("secret", string(body))), "body changed")
+	assert.False(t, validSignature(secret, body, ""), "missing")
+	assert.False(t, validSignature(secret, body, strings.TrimPrefix(sign("secret", string(body)), "sha256=")), "without the sha256= prefix")
+	assert.False(t, validSignature(secret, body, "sha256=not-hex"), "malformed")
+}
+
+func TestWebhookHandler(t *testing.T) {
+	const body = `{"ref": "refs/heads/main", "repository": {"clone_url": "https://github.com/afanwang/go-sample.git"}}`
+	params := pipeline.PipelineParams{GitURL: "https://github.com/afanwang/go-sample.git", Ref: "refs/heads/main"}
+
+	serve := func(h *webhookHandler, method, body, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		if signature != "" {
+			req.Header.Set("X-Hub-Signature-256", signature)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Starts a pipeline for a signed push", func(t *testing.T) {
+		tc := &mocks.Client{}
+		run := &mocks.WorkflowRun{}
+		run.On("GetID").Return("PipelineWorkflow-1")
+		run.On("GetRunID").Return("run-1")
+		tc.On("ExecuteWorkflow", mock.Anything, mock.Anything, "PipelineWorkflow", params).Return(run, nil)
+
+		rec := serve(&webhookHandler{tc: tc, queue: "pipeline", secret: []byte("secret")}, http.MethodPost, body, sign("secret", body))
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		var resp webhookResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, webhookResponse{WorkflowID: "PipelineWorkflow-1", RunID: "run-1"}, resp)
+		tc.AssertExpectations(t)
+	})
+
+	for _, tt := range []struct {
+		name      string
+		method    string
+		body      string
+		signature string
+		status    int
+	}{
+		{"Not a POST", http.MethodGet, "", "", http.StatusMethodNotAllowed},
+		{"Missing signature", http.MethodPost, body, "", http.StatusUnauthorized},
+		{"Invalid signature", http.MethodPost, body, sign("other", body), http.StatusUnauthorized},
+		{"Invalid payload", http.MethodPost, "{", sign("secret", "{"), http.StatusBadRequest},
+		{"No repository", http.MethodPost, `{"ref": "refs/heads/main"}`, sign("secret", `{"ref": "refs/heads/main"}`), http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := &mocks.Client{}
+			rec := serve(&webhookHandler{tc: tc, queue: "pipeline", secret: []byte("secret")}, tt.method, tt.body, tt.signature)
+			assert.Equal(t, tt.status, rec.Code)
+			tc.AssertNotCalled(t, "ExecuteWorkflow", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("Failing to start the pipeline", func(t *testing.T) {
+		tc := &mocks.Client{}
+		tc.On("ExecuteWorkflow", mock.Anything, mock.Anything, "PipelineWorkflow", params).Return(nil, errors.New("unavailable"))
+
+		rec := serve(&webhookHandler{tc: tc, queue: "pipeline", secret: []byte("secret")}, http.MethodPost, body, sign("secret", body))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"temporal-workflow/pipeline"
+)
+
+// StatsDOptions configures the pipeline outcome metrics pushed by RunPipeline. Without an
+// address, no metrics are sent.
+type StatsDOptions struct {
+	// Address is the host:port of a StatsD or DogStatsD agent, e.g. "localhost:8125".
+	Address string
+	// Prefix is prepended to every metric name. Defaults to "pipeline".
+	Prefix string `default:"pipeline"`
+}
+
+// statsdClient writes metrics in the DogStatsD line format, with tags. Sending is best effort:
+// metrics must never fail a pipeline.
+type statsdClient struct {
+	w      io.WriteCloser
+	prefix string
+}
+
+// newStatsDClient returns a client sending over UDP to opts.Address, or nil when it is empty. A
+// nil client discards metrics.
+func newStatsDClient(opts StatsDOptions) (*statsdClient, error) {
+	if opts.Address == "" {
+		return nil, nil
+	}
+	conn, err := net.Dial("udp", opts.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to StatsD %q: %w", opts.Address, err)
+	}
+	return &statsdClient{w: conn, prefix: opts.Prefix}, nil
+}
+
+func (c *statsdClient) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.w.Close()
+}
+
+func (c *statsdClient) count(name string, value int, tags ...string) {
+	c.send(fmt.Sprintf("%d|c", value), name, tags)
+}
+
+func (c *statsdClient) timing(name string, d time.Duration, tags ...string) {
+	c.send(fmt.Sprintf("%d|ms", d.Milliseconds()), name, tags)
+}
+
+func (c *statsdClient) send(value, name string, tags []string) {
+	if c.prefix != "" {
+		name = c.prefix + "." + name
+	}
+	line := name + ":" + value
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	_, _ = io.WriteString(c.w, line+"\n")
+}
+
+// pipelineStatus is the outcome of a pipeline run as reported in metrics: pass, fail, or error
+// when the workflow itself failed.
+func pipelineStatus(result *pipeline.PipelineResult, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case len(result.Failures) > 0:
+		return "fail"
+	}
+	return "pass"
+}
+
+// recordPipeline emits the outcome and duration of a pipeline run, tagged by repository and status.
+func (c *statsdClient) recordPipeline(params pipeline.PipelineParams, result *pipeline.PipelineResult, err error, d time.Duration) {
+	if c == nil {
+		return
+	}
+	tags := []string{"repo:" + statsdTagValue(params.RepoSpecs()[0].URL), "status:" + pipelineStatus(result, err)}
+	c.count("runs", 1, tags...)
+	c.timing("duration", d, tags...)
+	if err == nil {
+		c.count("failures", len(result.Failures), tags...)
+	}
+}
+
+// statsdTagValue replaces the characters that separate DogStatsD fields and tags.
+func statsdTagValue(value string) string {
+	return strings.NewReplacer("|", "_", ",", "_", "#", "_").Replace(value)
+}
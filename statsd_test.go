@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"temporal-workflow/pipeline"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestRecordPipeline(t *testing.T) {
+	params := pipeline.PipelineParams{GitURL: "https://github.com/afanwang/go-sample.git"}
+
+	tests := []struct {
+		name   string
+		result pipeline.PipelineResult
+		err    error
+		want   string
+	}{
+		{
+			name:   "Pass",
+			result: pipeline.PipelineResult{Failures: []pipeline.PipelineFailure{}},
+			want: "pipeline.runs:1|c|#repo:https://github.com/afanwang/go-sample.git,status:pass\n" +
+				"pipeline.duration:1500|ms|#repo:https://github.com/afanwang/go-sample.git,status:pass\n" +
+				"pipeline.failures:0|c|#repo:https://github.com/afanwang/go-sample.git,status:pass\n",
+		},
+		{
+			name:   "Fail",
+			result: pipeline.PipelineResult{Failures: []pipeline.PipelineFailure{{Activity: "GoTest"}, {Activity: "GoBuild"}}},
+			want: "pipeline.runs:1|c|#repo:https://github.com/afanwang/go-sample.git,status:fail\n" +
+				"pipeline.duration:1500|ms|#repo:https://github.com/afanwang/go-sample.git,status:fail\n" +
+				"pipeline.failures:2|c|#repo:https://github.com/afanwang/go-sample.git,status:fail\n",
+		},
+		{
+			name: "Error",
+			err:  io.ErrUnexpectedEOF,
+			want: "pipeline.runs:1|c|#repo:https://github.com/afanwang/go-sample.git,status:error\n" +
+				"pipeline.duration:1500|ms|#repo:https://github.com/afanwang/go-sample.git,status:error\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			metrics := &statsdClient{w: nopWriteCloser{&buf}, prefix: "pipeline"}
+
+			metrics.recordPipeline(params, &tt.result, tt.err, 1500*time.Millisecond)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		metrics, err := newStatsDClient(StatsDOptions{})
+		assert.NoError(t, err)
+		assert.Nil(t, metrics)
+		// A nil client discards metrics.
+		metrics.recordPipeline(params, &pipeline.PipelineResult{}, nil, time.Second)
+		assert.NoError(t, metrics.Close())
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"temporal-workflow/pipeline"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	tclient "go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// The repository and branch of a pipeline, set when PipelineParams.AutoCancelSuperseded is, so
+// later runs can find it. They must be registered on the server, e.g. `temporal operator
+// search-attribute create --name PipelineRepo --type Keyword`, and likewise for PipelineBranch.
+var (
+	PipelineRepoSearchAttribute   = temporal.NewSearchAttributeKeyKeyword("PipelineRepo")
+	PipelineBranchSearchAttribute = temporal.NewSearchAttributeKeyKeyword("PipelineBranch")
+)
+
+// pipelineBranch is the branch params runs on, "HEAD" for the default branch.
+func pipelineBranch(params pipeline.PipelineParams) string {
+	if branch := strings.TrimPrefix(params.Ref, "refs/heads/"); branch != "" {
+		return branch
+	}
+	return "HEAD"
+}
+
+// cancelSupersededPipelines cancels the running pipelines of the same repository and branch as
+// params, and waits for them to close so the new run can reuse the workflow ID.
+func cancelSupersededPipelines(ctx context.Context, tc tclient.Client, params pipeline.PipelineParams) error {
+	return cancelRunningPipelines(ctx, tc, fmt.Sprintf("%s = '%s' AND %s = '%s'",
+		PipelineRepoSearchAttribute.GetName(), escapeQueryValue(params.RepoSpecs()[0].URL),
+		PipelineBranchSearchAttribute.GetName(), escapeQueryValue(pipelineBranch(params))))
+}
+
+// cancelRunningPipelines cancels the running pipelines matching the visibility query condition,
+// and waits for them to close.
+func cancelRunningPipelines(ctx context.Context, tc tclient.Client, condition string) error {
+	executions, err := runningPipelines(ctx, tc, condition)
+	if err != nil {
+		return err
+	}
+	for _, execution := range executions {
+		workflowID, runID := execution.GetWorkflowId(), execution.GetRunId()
+		slog.Info("Cancelling superseded PipelineWorkflow", "WorkflowID", workflowID, "RunID", runID)
+		if err := tc.CancelWorkflow(ctx, workflowID, runID); err != nil {
+			return fmt.Errorf("failed to cancel superseded pipeline %s: %w", workflowID, err)
+		}
+		var canceledErr *temporal.CanceledError
+		if err := tc.GetWorkflow(ctx, workflowID, runID).Get(ctx, nil); err != nil && !errors.As(err, &canceledErr) {
+			slog.Warn("Superseded PipelineWorkflow did not close cleanly", "WorkflowID", workflowID, "error", err)
+		}
+	}
+	return nil
+}
+
+// runningPipelines lists the running pipelines matching the visibility query condition.
+func runningPipelines(ctx context.Context, tc tclient.Client, condition string) ([]*commonpb.WorkflowExecution, error) {
+	query := "WorkflowType = 'PipelineWorkflow' AND ExecutionStatus = 'Running' AND " + condition
+
+	var executions []*commonpb.WorkflowExecution
+	var token []byte
+	for {
+		resp, err := tc.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{Query: query, NextPageToken: token})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list running pipelines: %w", err)
+		}
+		for _, execution := range resp.GetExecutions() {
+			executions = append(executions, execution.GetExecution())
+		}
+		token = resp.GetNextPageToken()
+		if len(token) == 0 {
+			return executions, nil
+		}
+	}
+}
+
+// escapeQueryValue escapes value for a single-quoted string of a visibility query.
+func escapeQueryValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"temporal-workflow/pipeline"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	commonpb "go.temporal.io/api/common/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	tclient "go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestAutoCancelSuperseded(t *testing.T) {
+	params := pipeline.PipelineParams{
+		GitURL:               "https://github.com/afanwang/go-sample.git",
+		Ref:                  "refs/heads/main",
+		AutoCancelSuperseded: true,
+	}
+
+	tc := &mocks.Client{}
+	tc.On("ListWorkflow", mock.Anything, &workflowservice.ListWorkflowExecutionsRequest{
+		Query: "WorkflowType = 'PipelineWorkflow' AND ExecutionStatus = 'Running' AND PipelineRepo = 'https://github.com/afanwang/go-sample.git' AND PipelineBranch = 'main'",
+	}).Return(&workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{
+			{Execution: &commonpb.WorkflowExecution{WorkflowId: "PipelineWorkflow-old", RunId: "run-1"}},
+		},
+	}, nil)
+	tc.On("CancelWorkflow", mock.Anything, "PipelineWorkflow-old", "run-1").Return(nil)
+	oldRun := &mocks.WorkflowRun{}
+	oldRun.On("Get", mock.Anything, nil).Return(temporal.NewCanceledError())
+	tc.On("GetWorkflow", mock.Anything, "PipelineWorkflow-old", "run-1").Return(oldRun)
+
+	newRun := &mocks.WorkflowRun{}
+	tc.On("ExecuteWorkflow", mock.Anything, mock.MatchedBy(func(options tclient.StartWorkflowOptions) bool {
+		repo, _ := options.TypedSearchAttributes.GetKeyword(PipelineRepoSearchAttribute)
+		branch, _ := options.TypedSearchAttributes.GetKeyword(PipelineBranchSearchAttribute)
+		return repo == params.GitURL && branch == "main"
+	}), "PipelineWorkflow", params).Return(newRun, nil)
+
+	run, err := ExecutePipeline(context.Background(), tc, "pipeline", params)
+	assert.NoError(t, err)
+	assert.Equal(t, newRun, run)
+	tc.AssertExpectations(t)
+	oldRun.AssertExpectations(t)
+}
+
+func TestEscapeQueryValue(t *testing.T) {
+	assert.Equal(t, `it\'s a \\ path`, escapeQueryValue(`it's a \ path`))
+}
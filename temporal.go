@@ -2,19 +2,68 @@ package main
 
 import (
 	"context"
+	"log/slog"
+	"time"
 
 	tclient "go.temporal.io/sdk/client"
+	tlog "go.temporal.io/sdk/log"
 )
 
 type TemporalOptions struct {
 	HostPort  string `required:"true"`
 	Namespace string `required:"true"`
 	Queue     string `required:"true"`
+	// MaxConcurrentHeavyActivities caps how many build/test/lint activities a worker runs at once,
+	// separately from TEMPORAL_MAXCONCURRENTACTIVITYEXECUTIONSIZE. Defaults to half the usable CPUs.
+	MaxConcurrentHeavyActivities int
+	// GracefulStopTimeout is how long a stopping worker waits for in-flight activities to finish
+	// before cancelling them, which kills their go commands. Sets the worker's WorkerStopTimeout.
+	GracefulStopTimeout time.Duration
+	// MaxConcurrentClones caps how many git clones a worker runs at once against each host;
+	// further clones wait for one to finish. Zero doesn't limit them.
+	MaxConcurrentClones int
+	// OTELEndpoint is the URL of an OpenTelemetry collector receiving OTLP over gRPC, e.g.
+	// "http://otel-collector:4317". When set, every workflow and activity, and the commands
+	// they run, produce a span. Empty disables tracing.
+	OTELEndpoint string
 }
 
-func NewTemporalClient(ctx context.Context, opts TemporalOptions) (tclient.Client, error) {
-	return tclient.DialContext(ctx, tclient.Options{
+// NewTemporalClient connects to the Temporal server of opts. closeClient closes the client and,
+// when tracing, exports the spans still buffered; the client itself is the dialed one, as
+// workers can only be created with those.
+func NewTemporalClient(ctx context.Context, opts TemporalOptions) (tc tclient.Client, closeClient func(), err error) {
+	cOpts, shutdown, err := temporalClientOptions(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	tc, err = tclient.DialContext(ctx, cOpts)
+	if err != nil {
+		shutdown()
+		return nil, nil, err
+	}
+	return tc, func() {
+		tc.Close()
+		shutdown()
+	}, nil
+}
+
+// temporalClientOptions returns the client options of opts, with the tracing interceptors when
+// OTELEndpoint is set. shutdown stops the tracer provider, if any.
+func temporalClientOptions(ctx context.Context, opts TemporalOptions) (cOpts tclient.Options, shutdown func(), err error) {
+	cOpts = tclient.Options{
 		HostPort:  opts.HostPort,
 		Namespace: opts.Namespace,
-	})
+		Logger:    tlog.NewStructuredLogger(slog.Default()),
+	}
+	if opts.OTELEndpoint == "" {
+		return cOpts, func() {}, nil
+	}
+
+	provider, err := newTracerProvider(ctx, opts.OTELEndpoint)
+	if err != nil {
+		return cOpts, nil, err
+	}
+	// Workers created with the client inherit its interceptors.
+	cOpts.Interceptors = tracingInterceptors(provider.Tracer("temporal-workflow"))
+	return cOpts, func() { shutdownTracerProvider(provider) }, nil
 }
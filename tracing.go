@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"temporal-workflow/pipeline"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// gitURLAttribute is set on the spans of a pipeline's workflows and activities.
+	gitURLAttribute = "pipeline.git_url"
+	// stepAttribute is set on the spans of activities to the pipeline step, e.g. GoTest.
+	stepAttribute = "pipeline.step"
+	// gitURLHeader carries the git URL of a span to its children started by another worker.
+	gitURLHeader = "pipeline-git-url"
+)
+
+// newTracerProvider returns a tracer provider exporting spans over OTLP/gRPC to endpoint, e.g.
+// "http://otel-collector:4317", and sets it as the global one so the activities' command
+// spans are exported too.
+func newTracerProvider(ctx context.Context, endpoint string) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter for %q: %w", endpoint, err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", "temporal-workflow")))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider, nil
+}
+
+// tracingInterceptors returns the interceptors creating a span for each workflow and activity
+// with tracer, with the pipeline's git URL.
+func tracingInterceptors(tracer trace.Tracer) []interceptor.ClientInterceptor {
+	return []interceptor.ClientInterceptor{
+		interceptor.NewTracingInterceptor(&otelTracer{tracer: tracer}),
+		// Runs inside the tracing interceptor, so the workflow's span is already started.
+		&gitURLInterceptor{},
+	}
+}
+
+// shutdownTracerProvider shuts provider down, exporting the spans still buffered.
+func shutdownTracerProvider(provider *sdktrace.TracerProvider) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := provider.Shutdown(ctx); err != nil {
+		slog.Warn("Failed to export traces", "error", err)
+	}
+}
+
+// otelTracer implements the Temporal SDK's tracing interceptor with OpenTelemetry, propagating
+// spans in the same header format as the SDK's contrib/opentelemetry package. Spans also carry
+// the git URL of the pipeline to their children, so activity spans have it as well.
+type otelTracer struct {
+	interceptor.BaseTracer
+	tracer trace.Tracer
+}
+
+// otelSpanContextKey is the key of the current *otelSpan in contexts.
+type otelSpanContextKey struct{}
+
+type otelSpan struct {
+	trace.Span
+	gitURL string
+}
+
+func (s *otelSpan) Finish(opts *interceptor.TracerFinishSpanOptions) {
+	if opts.Error != nil {
+		s.SetStatus(codes.Error, opts.Error.Error())
+	}
+	s.End()
+}
+
+// setGitURL sets the git URL of s and of the spans started as its children from now on.
+func (s *otelSpan) setGitURL(gitURL string) {
+	s.gitURL = gitURL
+	s.SetAttributes(attribute.String(gitURLAttribute, gitURL))
+}
+
+// otelSpanRef is a parent span read from a Temporal header.
+type otelSpanRef struct {
+	spanContext trace.SpanContext
+	gitURL      string
+}
+
+func (t *otelTracer) Options() interceptor.TracerOptions {
+	return interceptor.TracerOptions{
+		SpanContextKey: otelSpanContextKey{},
+		HeaderKey:      "_tracer-data",
+	}
+}
+
+func (t *otelTracer) UnmarshalSpan(m map[string]string) (interceptor.TracerSpanRef, error) {
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier(m))
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return nil, errors.New("failed to extract span context from header")
+	}
+	return &otelSpanRef{spanContext: spanContext, gitURL: m[gitURLHeader]}, nil
+}
+
+func (t *otelTracer) MarshalSpan(span interceptor.TracerSpan) (map[string]string, error) {
+	s := span.(*otelSpan)
+	m := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(trace.ContextWithSpan(context.Background(), s.Span), m)
+	if s.gitURL != "" {
+		m[gitURLHeader] = s.gitURL
+	}
+	return m, nil
+}
+
+func (t *otelTracer) SpanFromContext(ctx context.Context) interceptor.TracerSpan {
+	if s, ok := ctx.Value(otelSpanContextKey{}).(*otelSpan); ok && s.Span == trace.SpanFromContext(ctx) {
+		return s
+	}
+	// A span started outside of Temporal, e.g. by the caller of a client.
+	if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() {
+		return &otelSpan{Span: span}
+	}
+	return nil
+}
+
+func (t *otelTracer) ContextWithSpan(ctx context.Context, span interceptor.TracerSpan) context.Context {
+	s := span.(*otelSpan)
+	return trace.ContextWithSpan(context.WithValue(ctx, otelSpanContextKey{}, s), s.Span)
+}
+
+func (t *otelTracer) StartSpan(opts *interceptor.TracerStartSpanOptions) (interceptor.TracerSpan, error) {
+	ctx := context.Background()
+	var gitURL string
+	switch parent := opts.Parent.(type) {
+	case *otelSpan:
+		ctx = trace.ContextWithSpan(ctx, parent.Span)
+		gitURL = parent.gitURL
+	case *otelSpanRef:
+		ctx = trace.ContextWithRemoteSpanContext(ctx, parent.spanContext)
+		gitURL = parent.gitURL
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(opts.Tags)+2)
+	for k, v := range opts.Tags {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	if gitURL != "" {
+		attrs = append(attrs, attribute.String(gitURLAttribute, gitURL))
+	}
+	if opts.Operation == "StartActivity" || opts.Operation == "RunActivity" {
+		attrs = append(attrs, attribute.String(stepAttribute, opts.Name))
+	}
+
+	_, span := t.tracer.Start(ctx, t.SpanName(opts), trace.WithTimestamp(opts.Time), trace.WithAttributes(attrs...))
+	return &otelSpan{Span: span, gitURL: gitURL}, nil
+}
+
+// gitURLInterceptor sets the git URL of a pipeline on the span of its workflow, which passes
+// it on to the spans of its activities and child workflows.
+type gitURLInterceptor struct {
+	interceptor.InterceptorBase
+}
+
+func (i *gitURLInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	w := &gitURLWorkflowInbound{}
+	w.Next = next
+	return w
+}
+
+type gitURLWorkflowInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+}
+
+func (w *gitURLWorkflowInbound) ExecuteWorkflow(ctx workflow.Context, in *interceptor.ExecuteWorkflowInput) (interface{}, error) {
+	span, ok := ctx.Value(otelSpanContextKey{}).(*otelSpan)
+	if ok && len(in.Args) > 0 {
+		if params, ok := in.Args[0].(pipeline.PipelineParams); ok {
+			span.setGitURL(params.RepoSpecs()[0].URL)
+		}
+	}
+	return w.Next.ExecuteWorkflow(ctx, in)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"temporal-workflow/pipeline"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.temporal.io/sdk/activity"
+	tclient "go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	tworker "go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestTracingInterceptors(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	var interceptors []interceptor.WorkerInterceptor
+	for _, i := range tracingInterceptors(provider.Tracer("test")) {
+		interceptors = append(interceptors, i.(interceptor.WorkerInterceptor))
+	}
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(tworker.Options{Interceptors: interceptors})
+	env.RegisterActivityWithOptions(func(ctx context.Context) error { return nil }, activity.RegisterOptions{Name: "GoTest"})
+	env.RegisterWorkflowWithOptions(func(ctx workflow.Context, params pipeline.PipelineParams) error {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+		return workflow.ExecuteActivity(ctx, "GoTest").Get(ctx, nil)
+	}, workflow.RegisterOptions{Name: "Pipeline"})
+
+	env.ExecuteWorkflow("Pipeline", pipeline.PipelineParams{GitURL: "https://github.com/afanwang/go-sample"})
+	assert.NoError(t, env.GetWorkflowError())
+
+	attrs := map[string]map[attribute.Key]string{}
+	for _, span := range recorder.Ended() {
+		attrs[span.Name()] = map[attribute.Key]string{}
+		for _, kv := range span.Attributes() {
+			attrs[span.Name()][kv.Key] = kv.Value.Emit()
+		}
+	}
+	for _, name := range []string{"RunWorkflow:Pipeline", "StartActivity:GoTest", "RunActivity:GoTest"} {
+		assert.Equal(t, "https://github.com/afanwang/go-sample", attrs[name][gitURLAttribute], name)
+	}
+	assert.Equal(t, "GoTest", attrs["RunActivity:GoTest"][stepAttribute])
+	assert.NotContains(t, attrs["RunWorkflow:Pipeline"], attribute.Key(stepAttribute))
+}
+
+func TestTracingClientOptions(t *testing.T) {
+	cOpts, shutdown, err := temporalClientOptions(context.Background(), TemporalOptions{
+		HostPort:     "localhost:7233",
+		Namespace:    "default",
+		OTELEndpoint: "http://localhost:4317",
+	})
+	assert.NoError(t, err)
+	defer shutdown()
+	assert.NotEmpty(t, cOpts.Interceptors)
+
+	// The SDK panics creating a worker with a client it didn't create, such as a wrapper.
+	tc, err := tclient.NewLazyClient(cOpts)
+	assert.NoError(t, err)
+	defer tc.Close()
+	assert.NotPanics(t, func() { tworker.New(tc, "pipeline", tworker.Options{}) })
+}
@@ -4,13 +4,57 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"runtime"
+	"sync/atomic"
+	"time"
 
 	"temporal-workflow/pipeline"
 
 	"github.com/kelseyhightower/envconfig"
+	// Registers the "postgres" driver ArchiveResult opens PipelineParams.ArchiveDSN with.
+	_ "github.com/lib/pq"
+	"go.temporal.io/sdk/interceptor"
 	tworker "go.temporal.io/sdk/worker"
 )
 
+// SMTPOptions holds the credentials for email notifications. They are deliberately kept out of
+// the logged worker options.
+type SMTPOptions struct {
+	Username string
+	Password string
+}
+
+// GitHubOptions holds the token for GitHub commit statuses. Like SMTPOptions, it is kept out of
+// the logged worker options.
+type GitHubOptions struct {
+	Token string
+}
+
+// WorkdirOptions configures where pipelines clone repositories, e.g. WORKDIR_ROOT=/data on hosts
+// with a small /tmp.
+type WorkdirOptions struct {
+	Root string
+}
+
+// GitOptions configures how GitClone rides out network errors, e.g. GIT_CLONEATTEMPTS=5 on
+// flaky networks.
+type GitOptions struct {
+	CloneAttempts int           `default:"3"`
+	CloneBackoff  time.Duration `default:"2s"`
+}
+
+// ArtifactOptions configures where details too large for a workflow result are stored, e.g. a
+// volume shared by the workers.
+type ArtifactOptions struct {
+	Dir string
+}
+
+// CommandOptions configures the commands the activities run, e.g. COMMAND_MAXOUTPUT=52428800
+// to keep up to 50MiB of each command's stdout and stderr.
+type CommandOptions struct {
+	MaxOutput int `default:"10485760"`
+}
+
 func RunWorker(ctx context.Context) error {
 	var tOpts TemporalOptions
 	if err := envconfig.Process("temporal", &tOpts); err != nil {
@@ -21,32 +65,149 @@ func RunWorker(ctx context.Context) error {
 		return fmt.Errorf("failed to process Temporal environment variables: %w", err)
 	}
 
+	if err := validateWorkerOptions(&tOpts, &wOpts); err != nil {
+		return fmt.Errorf("invalid Temporal worker options: %w", err)
+	}
+
 	slog.Info(
 		"Temporal worker options",
 		"server", fmt.Sprintf("%+v", tOpts),
 		"worker", fmt.Sprintf("%+v", wOpts),
 	)
 
-	tc, err := NewTemporalClient(ctx, tOpts)
+	tc, closeClient, err := NewTemporalClient(ctx, tOpts)
 	if err != nil {
 		return fmt.Errorf("failed to connect to Temporal server %q: %w", tOpts.HostPort, err)
 	}
-	defer tc.Close()
+	defer closeClient()
 
+	tracker := &activityTracker{}
+	wOpts.Interceptors = append(wOpts.Interceptors, tracker)
 	worker := tworker.New(tc, tOpts.Queue, wOpts)
 
 	worker.RegisterWorkflow(pipeline.PipelineWorkflow)
+	worker.RegisterWorkflow(pipeline.DeployWorkflow)
+
+	var sOpts SMTPOptions
+	if err := envconfig.Process("smtp", &sOpts); err != nil {
+		return fmt.Errorf("failed to process SMTP environment variables: %w", err)
+	}
+
+	var ghOpts GitHubOptions
+	if err := envconfig.Process("github", &ghOpts); err != nil {
+		return fmt.Errorf("failed to process GitHub environment variables: %w", err)
+	}
+
+	var wdOpts WorkdirOptions
+	if err := envconfig.Process("workdir", &wdOpts); err != nil {
+		return fmt.Errorf("failed to process workdir environment variables: %w", err)
+	}
+
+	var gOpts GitOptions
+	if err := envconfig.Process("git", &gOpts); err != nil {
+		return fmt.Errorf("failed to process git environment variables: %w", err)
+	}
+
+	var aOpts ArtifactOptions
+	if err := envconfig.Process("artifact", &aOpts); err != nil {
+		return fmt.Errorf("failed to process artifact environment variables: %w", err)
+	}
+
+	var cOpts CommandOptions
+	if err := envconfig.Process("command", &cOpts); err != nil {
+		return fmt.Errorf("failed to process command environment variables: %w", err)
+	}
+	if cOpts.MaxOutput <= 0 {
+		return fmt.Errorf("COMMAND_MAXOUTPUT must be positive, got %d", cOpts.MaxOutput)
+	}
+	pipeline.MaxCommandOutput = cOpts.MaxOutput
+
+	pa := pipeline.PipelineActivity{
+		SMTPUsername:  sOpts.Username,
+		SMTPPassword:  sOpts.Password,
+		GitHubToken:   ghOpts.Token,
+		ArtifactDir:   aOpts.Dir,
+		WorkdirRoot:   wdOpts.Root,
+		CloneAttempts: gOpts.CloneAttempts,
+		CloneBackoff:  gOpts.CloneBackoff,
+		CloneLimiter:  pipeline.NewCloneLimiter(tOpts.MaxConcurrentClones),
+	}
+
+	// Resource-intensive activities get their own queue and slot count, so a burst of
+	// pipelines can't run dozens of builds at once. Both workers share this host's workdirs.
+	heavyOpts := wOpts
+	heavyOpts.MaxConcurrentActivityExecutionSize = tOpts.MaxConcurrentHeavyActivities
+	heavyWorker := tworker.New(tc, pipeline.HeavyTaskQueue(tOpts.Queue), heavyOpts)
+	if err := pa.RegisterAll(worker, heavyWorker); err != nil {
+		return fmt.Errorf("activity registration check failed: %w", err)
+	}
+
+	if err := heavyWorker.Start(); err != nil {
+		return fmt.Errorf("failed to start heavy activity worker: %w", err)
+	}
+	defer heavyWorker.Stop()
+
+	stop := make(chan interface{})
+	go func() {
+		sig := <-tworker.InterruptCh()
+		slog.Info("Stopping worker, draining in-flight activities", "signal", sig, "activities", tracker.running.Load(), "timeout", wOpts.WorkerStopTimeout)
+		close(stop)
+	}()
+	return worker.Run(stop)
+}
+
+// activityTracker counts the activities running on the workers it intercepts, so a stopping
+// worker can report how many it is waiting for.
+type activityTracker struct {
+	interceptor.WorkerInterceptorBase
+	running atomic.Int64
+}
+
+func (t *activityTracker) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	i := &activityTrackerInbound{tracker: t}
+	i.Next = next
+	return i
+}
 
-	pa := pipeline.PipelineActivity{}
-	worker.RegisterActivity(pa.GitClone)
-	worker.RegisterActivity(pa.GoTest)
-	worker.RegisterActivity(pa.GoFmt)
-	worker.RegisterActivity(pa.GoGenerate)
-	worker.RegisterActivity(pa.GoModTidy)
-	worker.RegisterActivity(pa.GolangCILint)
-	worker.RegisterActivity(pa.GoBuild)
-	worker.RegisterActivity(pa.GoDeploy)
-	worker.RegisterActivity(pa.DeleteWorkdir)
-
-	return worker.Run(tworker.InterruptCh())
+type activityTrackerInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+	tracker *activityTracker
+}
+
+func (i *activityTrackerInbound) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	i.tracker.running.Add(1)
+	defer i.tracker.running.Add(-1)
+	return i.Next.ExecuteActivity(ctx, in)
+}
+
+// validateWorkerOptions rejects negative concurrency limits and fills in defaults sized to the
+// host, instead of the SDK's default of 1000 concurrent activities.
+func validateWorkerOptions(tOpts *TemporalOptions, wOpts *tworker.Options) error {
+	if wOpts.MaxConcurrentActivityExecutionSize < 0 {
+		return fmt.Errorf("MaxConcurrentActivityExecutionSize must not be negative, got %d", wOpts.MaxConcurrentActivityExecutionSize)
+	}
+	if wOpts.MaxConcurrentWorkflowTaskExecutionSize < 0 {
+		return fmt.Errorf("MaxConcurrentWorkflowTaskExecutionSize must not be negative, got %d", wOpts.MaxConcurrentWorkflowTaskExecutionSize)
+	}
+	if tOpts.MaxConcurrentHeavyActivities < 0 {
+		return fmt.Errorf("MaxConcurrentHeavyActivities must not be negative, got %d", tOpts.MaxConcurrentHeavyActivities)
+	}
+	if tOpts.MaxConcurrentClones < 0 {
+		return fmt.Errorf("MaxConcurrentClones must not be negative, got %d", tOpts.MaxConcurrentClones)
+	}
+	if tOpts.GracefulStopTimeout < 0 {
+		return fmt.Errorf("GracefulStopTimeout must not be negative, got %s", tOpts.GracefulStopTimeout)
+	}
+	if tOpts.GracefulStopTimeout > 0 {
+		wOpts.WorkerStopTimeout = tOpts.GracefulStopTimeout
+	}
+
+	procs := runtime.GOMAXPROCS(0)
+	if wOpts.MaxConcurrentActivityExecutionSize == 0 {
+		wOpts.MaxConcurrentActivityExecutionSize = 2 * procs
+	}
+	if tOpts.MaxConcurrentHeavyActivities == 0 {
+		tOpts.MaxConcurrentHeavyActivities = max(1, procs/2)
+	}
+	return nil
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	tworker "go.temporal.io/sdk/worker"
+)
+
+func TestValidateWorkerOptions(t *testing.T) {
+	t.Run("Fills in defaults", func(t *testing.T) {
+		tOpts := TemporalOptions{}
+		wOpts := tworker.Options{}
+		assert.NoError(t, validateWorkerOptions(&tOpts, &wOpts))
+		assert.Equal(t, 2*runtime.GOMAXPROCS(0), wOpts.MaxConcurrentActivityExecutionSize)
+		assert.Equal(t, max(1, runtime.GOMAXPROCS(0)/2), tOpts.MaxConcurrentHeavyActivities)
+	})
+
+	t.Run("Keeps configured limits", func(t *testing.T) {
+		tOpts := TemporalOptions{MaxConcurrentHeavyActivities: 3}
+		wOpts := tworker.Options{MaxConcurrentActivityExecutionSize: 8}
+		assert.NoError(t, validateWorkerOptions(&tOpts, &wOpts))
+		assert.Equal(t, 8, wOpts.MaxConcurrentActivityExecutionSize)
+		assert.Equal(t, 3, tOpts.MaxConcurrentHeavyActivities)
+	})
+
+	t.Run("Graceful stop timeout", func(t *testing.T) {
+		wOpts := tworker.Options{WorkerStopTimeout: time.Second}
+		assert.NoError(t, validateWorkerOptions(&TemporalOptions{GracefulStopTimeout: 5 * time.Minute}, &wOpts))
+		assert.Equal(t, 5*time.Minute, wOpts.WorkerStopTimeout)
+
+		// Unset keeps TEMPORAL_WORKERSTOPTIMEOUT.
+		wOpts = tworker.Options{WorkerStopTimeout: time.Second}
+		assert.NoError(t, validateWorkerOptions(&TemporalOptions{}, &wOpts))
+		assert.Equal(t, time.Second, wOpts.WorkerStopTimeout)
+
+		assert.Error(t, validateWorkerOptions(&TemporalOptions{GracefulStopTimeout: -time.Second}, &tworker.Options{}))
+	})
+
+	t.Run("Rejects negative limits", func(t *testing.T) {
+		assert.Error(t, validateWorkerOptions(&TemporalOptions{MaxConcurrentHeavyActivities: -1}, &tworker.Options{}))
+		assert.Error(t, validateWorkerOptions(&TemporalOptions{MaxConcurrentClones: -1}, &tworker.Options{}))
+		assert.Error(t, validateWorkerOptions(&TemporalOptions{}, &tworker.Options{MaxConcurrentActivityExecutionSize: -1}))
+	})
+}
+
+func TestActivityTracker(t *testing.T) {
+	tracker := &activityTracker{}
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	env.SetWorkerOptions(tworker.Options{Interceptors: []interceptor.WorkerInterceptor{tracker}})
+
+	var running int64
+	env.RegisterActivityWithOptions(func(ctx context.Context) error {
+		running = tracker.running.Load()
+		return nil
+	}, activity.RegisterOptions{Name: "Track"})
+
+	_, err := env.ExecuteActivity("Track")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), running)
+	assert.Equal(t, int64(0), tracker.running.Load())
+}